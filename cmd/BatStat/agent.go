@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/MrBrooks89/BatStat/internal/agent"
+)
+
+func runAgent(args []string) error {
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	listen := fs.String("listen", ":9878", "address to serve the agent API on")
+	token := fs.String("token", "", "access token clients must present (default $"+agent.TokenEnv+")")
+	cert := fs.String("tls-cert", "", "TLS certificate file")
+	key := fs.String("tls-key", "", "TLS private key file")
+	allowActions := fs.Bool("allow-actions", false, "allow clients to kill processes and run diagnostics")
+	insecure := fs.Bool("insecure", false, "serve plain HTTP when no certificate is given")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: BatStat agent [flags]\n\nServe connection snapshots to remote BatStat TUIs.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if *token == "" {
+		*token = os.Getenv(agent.TokenEnv)
+	}
+
+	server, err := agent.NewServer(agent.ServerOptions{
+		Addr:         *listen,
+		Token:        *token,
+		CertFile:     *cert,
+		KeyFile:      *key,
+		AllowActions: *allowActions,
+		Insecure:     *insecure,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("agent listening on %s (actions enabled: %t)", *listen, *allowActions)
+	return server.ListenAndServe(ctx)
+}
@@ -1,14 +1,151 @@
 package main
 
 import (
+	"cmp"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/agent"
+	"github.com/MrBrooks89/BatStat/internal/alerts"
+	"github.com/MrBrooks89/BatStat/internal/config"
+	"github.com/MrBrooks89/BatStat/internal/history"
+	"github.com/MrBrooks89/BatStat/internal/intel"
 	"github.com/MrBrooks89/BatStat/internal/tui"
 )
 
 func main() {
-	app := tui.NewApp()
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "BatStat %s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	opts, err := parseTUIFlags(os.Args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "BatStat: %v\n", err)
+		os.Exit(2)
+	}
+
+	app := tui.NewApp(opts)
 	if err := app.Run(); err != nil {
 		log.Fatalf("failed to start app: %v", err)
 	}
 }
+
+var commands = map[string]func(args []string) error{
+	"watch": runWatch,
+	"serve": runServe,
+	"agent": runAgent,
+}
+
+// stringList collects a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func parseTUIFlags(args []string) (tui.Options, error) {
+	fs := flag.NewFlagSet("BatStat", flag.ContinueOnError)
+	var agents stringList
+	fs.Var(&agents, "agent", "remote agent as [name=]https://host:port (repeatable)")
+	token := fs.String("agent-token", "", "agent access token (default $"+agent.TokenEnv+")")
+	caFile := fs.String("agent-ca", "", "PEM file with the CA that signed the agents' certificates")
+	insecure := fs.Bool("agent-insecure", false, "skip TLS certificate verification for agents")
+	noLocal := fs.Bool("no-local", false, "only show connections from remote agents")
+	var iocFiles stringList
+	fs.Var(&iocFiles, "ioc", "indicator list (text, CSV or STIX JSON) to flag matching remote addresses (repeatable)")
+	configPath := fs.String("config", "", "config file (default "+config.DefaultPath()+")")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: BatStat [flags]\n       BatStat watch|serve|agent [flags]\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return tui.Options{}, err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return tui.Options{}, err
+	}
+	engine, err := alerts.NewEngine(cfg.Alerts)
+	if err != nil {
+		return tui.Options{}, err
+	}
+
+	opts := tui.Options{NoLocal: *noLocal, Alerts: engine}
+	opts.Kill.Escalate, opts.KillTree = cfg.Kill.Escalate, cfg.Kill.Tree
+	if cfg.Kill.Grace != "" {
+		if opts.Kill.Grace, err = time.ParseDuration(cfg.Kill.Grace); err != nil {
+			return opts, fmt.Errorf("kill.grace: %w", err)
+		}
+	}
+
+	if cfg.Stats.Window != "" {
+		if opts.StatsWindow, err = time.ParseDuration(cfg.Stats.Window); err != nil {
+			return opts, fmt.Errorf("stats.window: %w", err)
+		}
+	}
+	opts.StatsTop = cfg.Stats.Top
+
+	if opts.Diagnostics, err = actions.NewDiagnostics(cfg.Diagnostics); err != nil {
+		return opts, err
+	}
+	if err := tui.CheckDiagnosticKeys(opts.Diagnostics); err != nil {
+		return opts, err
+	}
+
+	if !cfg.History.Disabled {
+		if dir := cmp.Or(cfg.History.Dir, history.DefaultDir()); dir != "" {
+			if opts.History, err = history.Open(dir, cfg.History.Keep); err != nil {
+				return opts, fmt.Errorf("history: %w", err)
+			}
+		}
+	}
+
+	cfg.Intel.Blocklists = append(cfg.Intel.Blocklists, iocFiles...)
+	if len(cfg.Intel.Blocklists) > 0 {
+		// The watcher keeps the lists that did load; the TUI shows the
+		// error again once it starts.
+		if opts.Intel, err = intel.NewWatcher(cfg.Intel); err != nil {
+			fmt.Fprintf(os.Stderr, "BatStat: warning: %v\n", err)
+		}
+	}
+
+	if *token == "" {
+		*token = os.Getenv(agent.TokenEnv)
+	}
+	for _, spec := range agents {
+		name, url, ok := strings.Cut(spec, "=")
+		if !ok {
+			name, url = "", spec
+		}
+		client, err := agent.NewClient(name, url, agent.ClientOptions{
+			Token:              *token,
+			CAFile:             *caFile,
+			InsecureSkipVerify: *insecure,
+		})
+		if err != nil {
+			return opts, err
+		}
+		opts.Agents = append(opts.Agents, client)
+	}
+	if opts.NoLocal && len(opts.Agents) == 0 {
+		return opts, fmt.Errorf("-no-local requires at least one -agent")
+	}
+	if err := tui.CheckAgentNames(opts.Agents, opts.NoLocal); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
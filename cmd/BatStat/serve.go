@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/metrics"
+)
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("metrics", ":9877", "address to serve Prometheus metrics on")
+	processes := fs.String("process-allowlist", "", "comma-separated process names to label individually (others become \"other\")")
+	maxProcesses := fs.Int("max-processes", 20, "without an allowlist, label only this many of the busiest processes")
+	states := fs.String("state-allowlist", "", "comma-separated connection states to label individually")
+	processIO := fs.Bool("process-io", false, "export per-process TCP rx/tx bytes (Linux)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: BatStat serve [flags]\n\nExpose connection statistics at /metrics for Prometheus.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	collector := metrics.NewCollector(metrics.Options{
+		ProcessAllowlist: splitList(*processes),
+		MaxProcesses:     *maxProcesses,
+		StateAllowlist:   splitList(*states),
+		ProcessIO:        *processIO,
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", collector)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, `<html><body><h1>BatStat</h1><a href="/metrics">Metrics</a></body></html>`)
+	})
+
+	server := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("serving metrics on %s/metrics", *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/filter"
+	"github.com/MrBrooks89/BatStat/internal/watch"
+)
+
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", 2*time.Second, "poll interval")
+	query := fs.String("filter", "", "only emit events for connections matching this filter query")
+	output := fs.String("output", "", "write events to this file instead of stdout")
+	maxSize := fs.Int64("max-size", 100, "rotate the output file after this many MiB (0 disables rotation)")
+	backups := fs.Int("max-backups", 5, "number of rotated output files to keep")
+	initial := fs.Bool("initial", false, "emit a snapshot event for every connection open at start")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: BatStat watch [flags]\n\nEmit one NDJSON event per connection open, close or state change.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := watch.OpenRotatingFile(*output, *maxSize<<20, *backups)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return watch.New(watch.Options{
+		Interval: *interval,
+		Filter:   filter.Parse(*query),
+		Initial:  *initial,
+		Output:   out,
+	}).Run(ctx)
+}
@@ -0,0 +1,238 @@
+package actions
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+// DefaultDiagTimeout stops user-defined diagnostics that set no timeout.
+const DefaultDiagTimeout = time.Minute
+
+// DiagnosticSpec is a user-defined diagnostic from the config file.
+//
+//	{"name": "http", "key": "u", "command": ["curl", "-sSI", "http://{remote}/"], "timeout": "15s"}
+type DiagnosticSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Key runs the diagnostic on the selected or marked connections. It is
+	// optional; every diagnostic is also in the diagnostics menu.
+	Key string `json:"key"`
+	// Command is an argv list run without a shell. Placeholders such as
+	// {raddr} are substituted in each argument, never in the program.
+	Command []string `json:"command"`
+	// Timeout stops the command, e.g. "30s"; "0" disables it. Default 1m.
+	Timeout string `json:"timeout"`
+}
+
+// Diagnostic is a tool that runs against a connection and streams its
+// output line by line. Built-ins have Func set; user-defined ones a Command.
+type Diagnostic struct {
+	Name        string
+	Description string
+	Key         rune          // 0 when only reachable from the menu
+	Timeout     time.Duration // 0 means none
+	Command     []string
+	// Func runs a built-in against the remote address and closes
+	// outputChan when done. Only built-ins can run on agents.
+	Func func(ctx context.Context, host string, outputChan chan<- string)
+}
+
+// BuiltinDiagnostics back the p, n and t keys when their native views are
+// not available, that is on agents and for marked rows.
+var BuiltinDiagnostics = []Diagnostic{
+	{Name: "ping", Description: "Four ICMP echo requests", Key: 'p', Func: Ping},
+	{Name: "nslookup", Description: "Reverse lookup with forward confirmation", Key: 'n', Func: Nslookup},
+	{Name: "traceroute", Description: "Route with three probes per hop", Key: 't', Func: Traceroute},
+}
+
+// Placeholders lists what the arguments of a command may contain.
+var Placeholders = map[string]func(c models.Connection) string{
+	"{raddr}":   func(c models.Connection) string { return c.RemoteIP() },
+	"{rport}":   func(c models.Connection) string { return strconv.Itoa(int(c.RemotePort())) },
+	"{remote}":  func(c models.Connection) string { return hostPort(c.RemoteIP(), c.RemotePort()) },
+	"{laddr}":   func(c models.Connection) string { return c.LocalIP() },
+	"{lport}":   func(c models.Connection) string { return strconv.Itoa(int(c.LocalPort())) },
+	"{local}":   func(c models.Connection) string { return hostPort(c.LocalIP(), c.LocalPort()) },
+	"{pid}":     func(c models.Connection) string { return strconv.Itoa(int(c.Pid)) },
+	"{process}": func(c models.Connection) string { return c.ProcessName },
+	"{proto}":   func(c models.Connection) string { return strings.ToLower(c.Type) },
+}
+
+var placeholderPattern = regexp.MustCompile(`\{[a-z]+\}`)
+
+func hostPort(ip string, port uint32) string {
+	return net.JoinHostPort(ip, strconv.Itoa(int(port)))
+}
+
+// NewDiagnostics checks the user-defined diagnostics of the config file.
+func NewDiagnostics(specs []DiagnosticSpec) ([]Diagnostic, error) {
+	names := make(map[string]bool)
+	keys := make(map[rune]string)
+	for _, d := range BuiltinDiagnostics {
+		names[d.Name] = true
+	}
+
+	var diags []Diagnostic
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, errors.New("diagnostic without a name")
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("diagnostic %q: name already in use", spec.Name)
+		}
+		names[spec.Name] = true
+
+		d := Diagnostic{Name: spec.Name, Description: spec.Description, Command: spec.Command, Timeout: DefaultDiagTimeout}
+		if len(spec.Command) == 0 || spec.Command[0] == "" {
+			return nil, fmt.Errorf("diagnostic %q: no command", spec.Name)
+		}
+		if placeholderPattern.MatchString(spec.Command[0]) {
+			return nil, fmt.Errorf("diagnostic %q: the program cannot contain placeholders", spec.Name)
+		}
+		for _, arg := range spec.Command[1:] {
+			for _, p := range placeholderPattern.FindAllString(arg, -1) {
+				if Placeholders[p] == nil {
+					return nil, fmt.Errorf("diagnostic %q: unknown placeholder %s", spec.Name, p)
+				}
+			}
+		}
+
+		if spec.Key != "" {
+			key, size := utf8.DecodeRuneInString(spec.Key)
+			if size != len(spec.Key) {
+				return nil, fmt.Errorf("diagnostic %q: key must be a single character", spec.Name)
+			}
+			if other, ok := keys[key]; ok {
+				return nil, fmt.Errorf("diagnostic %q: key %q is already used by %q", spec.Name, key, other)
+			}
+			keys[key] = spec.Name
+			d.Key = key
+		}
+
+		if spec.Timeout != "" {
+			timeout, err := time.ParseDuration(spec.Timeout)
+			if err != nil || timeout < 0 {
+				return nil, fmt.Errorf("diagnostic %q: invalid timeout %q", spec.Name, spec.Timeout)
+			}
+			d.Timeout = timeout
+		}
+		diags = append(diags, d)
+	}
+	return diags, nil
+}
+
+// NeedsRemote reports whether d only makes sense for connections with a peer.
+func (d Diagnostic) NeedsRemote() bool {
+	if d.Func != nil {
+		return true
+	}
+	for _, arg := range d.Command[1:] {
+		if strings.Contains(arg, "{raddr}") || strings.Contains(arg, "{rport}") || strings.Contains(arg, "{remote}") {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand substitutes the placeholders of the command with c's values.
+func (d Diagnostic) Expand(c models.Connection) ([]string, error) {
+	if d.NeedsRemote() && !c.HasRemote() {
+		return nil, fmt.Errorf("%s needs a connection with a remote address", d.Name)
+	}
+	argv := []string{d.Command[0]}
+	for _, arg := range d.Command[1:] {
+		if strings.Contains(arg, "{pid}") && c.Pid <= 0 {
+			return nil, fmt.Errorf("%s needs a connection with a known process", d.Name)
+		}
+		argv = append(argv, placeholderPattern.ReplaceAllStringFunc(arg, func(p string) string {
+			if value, ok := Placeholders[p]; ok {
+				return value(c)
+			}
+			return p
+		}))
+	}
+	return argv, nil
+}
+
+// Run runs d against c, streaming its output to outputChan, which is closed
+// when done. The error reports a timeout, a failure to start or a non-zero
+// exit status.
+func (d Diagnostic) Run(ctx context.Context, c models.Connection, outputChan chan<- string) error {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	var err error
+	if d.Func != nil {
+		if !c.HasRemote() {
+			close(outputChan)
+			return fmt.Errorf("%s needs a connection with a remote address", d.Name)
+		}
+		d.Func(ctx, c.RemoteIP(), outputChan)
+	} else {
+		err = func() error {
+			defer close(outputChan)
+			argv, err := d.Expand(c)
+			if err != nil {
+				return err
+			}
+			return RunCommand(ctx, argv, outputChan)
+		}()
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", d.Timeout)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// RunCommand runs argv without a shell and sends its combined stdout and
+// stderr line by line. It returns once the process has exited.
+func RunCommand(ctx context.Context, argv []string, outputChan chan<- string) error {
+	if len(argv) == 0 {
+		return errors.New("empty command")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdout, cmd.Stderr = w, w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return err
+	}
+	// Children that inherited the pipe could otherwise keep the read open.
+	stop := context.AfterFunc(ctx, func() { r.Close() })
+	defer stop()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+		case outputChan <- scanner.Text():
+		}
+	}
+	return cmd.Wait()
+}
@@ -2,41 +2,473 @@ package actions
 
 import (
 	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
-	"strconv"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/version"
 )
 
-func ExportToCSV(connections []models.Connection, filename string) (string, error) {
+type ExportMeta struct {
+	Hostname  string    `json:"hostname"`
+	Timestamp time.Time `json:"timestamp"`
+	Version   string    `json:"version"`
+	Filter    string    `json:"filter"`
+	Sort      string    `json:"sort"`
+}
+
+type Column struct {
+	Key   string
+	Title string
+}
+
+type SummaryCount struct {
+	Label string
+	Count int
+}
+
+type SummaryGroup struct {
+	Title  string
+	Counts []SummaryCount
+}
+
+// Dataset is the format-neutral table handed to an Exporter. Rows hold one
+// value per column so JSON output keeps numbers as numbers.
+type Dataset struct {
+	Name    string
+	Title   string
+	Columns []Column
+	Rows    [][]any
+	Summary []SummaryGroup
+}
+
+type Exporter interface {
+	Format() string
+	Extension() string
+	Export(w io.Writer, ds Dataset, meta ExportMeta) error
+}
+
+var exporters = []Exporter{
+	csvExporter{},
+	jsonExporter{},
+	ndjsonExporter{},
+	markdownExporter{},
+	htmlExporter{},
+}
+
+var extensionAliases = map[string]string{
+	".csv":      "csv",
+	".json":     "json",
+	".ndjson":   "ndjson",
+	".jsonl":    "ndjson",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".html":     "html",
+	".htm":      "html",
+}
+
+func Exporters() []Exporter {
+	return exporters
+}
+
+func ExporterFor(format string) (Exporter, bool) {
+	for _, e := range exporters {
+		if e.Format() == format {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func ExporterForPath(path string) (Exporter, bool) {
+	format, ok := extensionAliases[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, false
+	}
+	return ExporterFor(format)
+}
+
+func NewExportMeta(filter, sort string) ExportMeta {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return ExportMeta{
+		Hostname:  hostname,
+		Timestamp: time.Now(),
+		Version:   version.String(),
+		Filter:    filter,
+		Sort:      sort,
+	}
+}
+
+// Export writes ds to filename. An empty format is inferred from the file
+// extension, and a filename without an extension gets the format's one. A
+// format that contradicts the extension, such as json for "out.html", is
+// refused.
+func Export(ds Dataset, meta ExportMeta, filename, format string) (string, error) {
+	exporter, known := ExporterForPath(filename)
+	if format == "" {
+		if !known {
+			exporter = exporters[0]
+		}
+	} else {
+		e, ok := ExporterFor(format)
+		if !ok {
+			return "", fmt.Errorf("unknown export format %q", format)
+		}
+		if known && exporter.Format() != format {
+			return "", fmt.Errorf("the extension of %s is for %s, not %s", filepath.Base(filename), exporter.Format(), format)
+		}
+		exporter = e
+	}
+
+	if filepath.Ext(filename) == "" {
+		filename += exporter.Extension()
+	}
+
 	file, err := os.Create(filename)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
 
-	headers := []string{"ProcessName", "PID", "Status", "Family", "Type", "LocalAddr", "RemoteAddr"}
-	if err := writer.Write(headers); err != nil {
+	if err := exporter.Export(file, ds, meta); err != nil {
+		file.Close()
 		return "", err
 	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	// CSV has no room for the metadata without breaking readers that expect
+	// the header row first, so it goes into a sidecar file.
+	if exporter.Format() == "csv" {
+		if err := writeMetaSidecar(MetaPath(filename), meta); err != nil {
+			return "", err
+		}
+	}
+	return filename, nil
+}
+
+// MetaPath is the sidecar holding the metadata of the CSV export filename:
+// "conns.csv" gets "conns.meta.json".
+func MetaPath(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".meta.json"
+}
+
+func writeMetaSidecar(path string, meta ExportMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ConnectionsDataset builds the export table for connections. details
+// resolves user and command line; nil looks the PID up on this machine.
+func ConnectionsDataset(connections []models.Connection, details func(models.Connection) models.DetailedInfo) Dataset {
+	if details == nil {
+		details = func(c models.Connection) models.DetailedInfo { return models.GetDetailedInfo(c.Pid) }
+	}
 
+	multiHost, hasIOC := false, false
 	for _, c := range connections {
-		row := []string{
+		multiHost = multiHost || c.Host != ""
+		hasIOC = hasIOC || c.IOC != ""
+	}
+
+	ds := Dataset{
+		Name:  "connections",
+		Title: "BatStat connections",
+		Columns: []Column{
+			{"process", "ProcessName"},
+			{"pid", "PID"},
+			{"fd", "Fd"},
+			{"user", "User"},
+			{"status", "Status"},
+			{"family", "Family"},
+			{"type", "Type"},
+			{"laddr", "LocalAddr"},
+			{"raddr", "RemoteAddr"},
+			{"cmdline", "Cmdline"},
+		},
+	}
+
+	if multiHost {
+		ds.Columns = append([]Column{{"host", "Host"}}, ds.Columns...)
+	}
+	if hasIOC {
+		ds.Columns = append(ds.Columns, Column{"ioc", "IOC"})
+	}
+
+	type procKey struct {
+		host string
+		pid  int32
+	}
+	cache := make(map[procKey]models.DetailedInfo)
+	byStatus := make(map[string]int)
+	byType := make(map[string]int)
+	byFamily := make(map[string]int)
+
+	for _, c := range connections {
+		key := procKey{c.Host, c.Pid}
+		info, ok := cache[key]
+		if !ok {
+			info = details(c)
+			cache[key] = info
+		}
+		row := []any{
 			c.ProcessName,
-			strconv.Itoa(int(c.Pid)),
+			c.Pid,
+			c.Fd,
+			info.Username,
 			c.Status,
 			c.Family,
 			c.Type,
 			c.Laddr,
 			c.Raddr,
+			info.Cmdline,
 		}
-		if err := writer.Write(row); err != nil {
-			return "", err
+		if multiHost {
+			row = append([]any{c.Host}, row...)
+		}
+		if hasIOC {
+			row = append(row, c.IOC)
+		}
+		ds.Rows = append(ds.Rows, row)
+
+		status := c.Status
+		if status == "" {
+			status = "NONE"
 		}
+		byStatus[status]++
+		byType[c.Type]++
+		byFamily[c.Family]++
 	}
 
-	return filename, nil
+	ds.Summary = []SummaryGroup{
+		{Title: "Total", Counts: []SummaryCount{{"Connections", len(connections)}, {"Processes", len(cache)}}},
+		{Title: "By status", Counts: SortedCounts(byStatus)},
+		{Title: "By type", Counts: SortedCounts(byType)},
+		{Title: "By family", Counts: SortedCounts(byFamily)},
+	}
+	return ds
+}
+
+// SortedCounts turns a tally into summary counts, largest first.
+func SortedCounts(m map[string]int) []SummaryCount {
+	counts := make([]SummaryCount, 0, len(m))
+	for label, n := range m {
+		counts = append(counts, SummaryCount{label, n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Label < counts[j].Label
+	})
+	return counts
+}
+
+func metaPairs(meta ExportMeta) [][2]string {
+	return [][2]string{
+		{"hostname", meta.Hostname},
+		{"timestamp", meta.Timestamp.Format(time.RFC3339)},
+		{"version", meta.Version},
+		{"filter", meta.Filter},
+		{"sort", meta.Sort},
+	}
 }
+
+func cellString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+type csvExporter struct{}
+
+func (csvExporter) Format() string    { return "csv" }
+func (csvExporter) Extension() string { return ".csv" }
+
+// Export writes the header row and the rows only, so spreadsheets and
+// header-first readers load it as is; Export puts meta into a sidecar.
+func (csvExporter) Export(w io.Writer, ds Dataset, _ ExportMeta) error {
+	writer := csv.NewWriter(w)
+	headers := make([]string, len(ds.Columns))
+	for i, col := range ds.Columns {
+		headers[i] = col.Title
+	}
+	if err := writer.Write(headers); err != nil {
+		return err
+	}
+
+	for _, row := range ds.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = cellString(v)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// jsonRow marshals a row as an object whose keys follow the column order.
+type jsonRow struct {
+	columns []Column
+	values  []any
+}
+
+func (r jsonRow) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, col := range r.columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(col.Key)
+		if err != nil {
+			return nil, err
+		}
+		var v any
+		if i < len(r.values) {
+			v = r.values[i]
+		}
+		val, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+func jsonRows(ds Dataset) []jsonRow {
+	rows := make([]jsonRow, len(ds.Rows))
+	for i, values := range ds.Rows {
+		rows[i] = jsonRow{columns: ds.Columns, values: values}
+	}
+	return rows
+}
+
+type jsonExporter struct{}
+
+func (jsonExporter) Format() string    { return "json" }
+func (jsonExporter) Extension() string { return ".json" }
+
+func (jsonExporter) Export(w io.Writer, ds Dataset, meta ExportMeta) error {
+	summary := make(map[string]map[string]int, len(ds.Summary))
+	for _, group := range ds.Summary {
+		counts := make(map[string]int, len(group.Counts))
+		for _, c := range group.Counts {
+			counts[c.Label] = c.Count
+		}
+		summary[group.Title] = counts
+	}
+
+	doc := struct {
+		Meta    ExportMeta                `json:"meta"`
+		Summary map[string]map[string]int `json:"summary,omitempty"`
+		Rows    []jsonRow                 `json:"rows"`
+	}{meta, summary, jsonRows(ds)}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+type ndjsonExporter struct{}
+
+func (ndjsonExporter) Format() string    { return "ndjson" }
+func (ndjsonExporter) Extension() string { return ".ndjson" }
+
+// Export writes a single {"meta": ...} line followed by one object per row.
+func (ndjsonExporter) Export(w io.Writer, ds Dataset, meta ExportMeta) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(struct {
+		Meta ExportMeta `json:"meta"`
+	}{meta}); err != nil {
+		return err
+	}
+	for _, row := range jsonRows(ds) {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type markdownExporter struct{}
+
+func (markdownExporter) Format() string    { return "markdown" }
+func (markdownExporter) Extension() string { return ".md" }
+
+func (markdownExporter) Export(w io.Writer, ds Dataset, meta ExportMeta) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", ds.Title)
+	for _, kv := range metaPairs(meta) {
+		value := kv[1]
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(&b, "- **%s:** %s\n", kv[0], markdownEscape(value))
+	}
+	b.WriteString("\n")
+
+	for _, group := range ds.Summary {
+		parts := make([]string, len(group.Counts))
+		for i, c := range group.Counts {
+			parts[i] = fmt.Sprintf("%s %d", markdownEscape(c.Label), c.Count)
+		}
+		fmt.Fprintf(&b, "**%s:** %s  \n", group.Title, strings.Join(parts, ", "))
+	}
+	if len(ds.Summary) > 0 {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("|")
+	for _, col := range ds.Columns {
+		fmt.Fprintf(&b, " %s |", markdownEscape(col.Title))
+	}
+	b.WriteString("\n|")
+	for range ds.Columns {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+
+	for _, row := range ds.Rows {
+		b.WriteString("|")
+		for _, v := range row {
+			fmt.Fprintf(&b, " %s |", markdownEscape(cellString(v)))
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscape keeps a cell on one line and stops its text being read as
+// a column break or as inline HTML.
+var markdownEscape = strings.NewReplacer(
+	"\\", "\\\\",
+	"|", "\\|",
+	"<", "\\<",
+	">", "\\>",
+	"&", "\\&",
+	"\r", " ",
+	"\n", " ",
+).Replace
@@ -0,0 +1,104 @@
+package actions
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+type htmlExporter struct{}
+
+func (htmlExporter) Format() string    { return "html" }
+func (htmlExporter) Extension() string { return ".html" }
+
+func (htmlExporter) Export(w io.Writer, ds Dataset, meta ExportMeta) error {
+	rows := make([][]string, len(ds.Rows))
+	for i, row := range ds.Rows {
+		cells := make([]string, len(row))
+		for j, v := range row {
+			cells[j] = cellString(v)
+		}
+		rows[i] = cells
+	}
+
+	return htmlReport.Execute(w, struct {
+		Title     string
+		Meta      ExportMeta
+		Generated string
+		Columns   []Column
+		Rows      [][]string
+		Summary   []SummaryGroup
+	}{ds.Title, meta, meta.Timestamp.Format(time.RFC1123), ds.Columns, rows, ds.Summary})
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - {{.Meta.Hostname}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+dl.meta { display: grid; grid-template-columns: max-content auto; gap: 0.2em 1em; color: #555; }
+dl.meta dt { font-weight: bold; }
+.summary { display: flex; flex-wrap: wrap; gap: 1em; margin: 1.5em 0; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: 0.6em 1em; min-width: 10em; }
+.card h2 { font-size: 0.9em; margin: 0 0 0.4em; text-transform: uppercase; color: #666; }
+.card td { padding: 0 0.8em 0 0; }
+table.data { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+table.data th, table.data td { border: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; }
+table.data th { background: #f4f4f4; cursor: pointer; user-select: none; position: sticky; top: 0; }
+table.data th.asc::after { content: " \25B2"; }
+table.data th.desc::after { content: " \25BC"; }
+table.data tr:nth-child(even) td { background: #fafafa; }
+td.wrap { word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl class="meta">
+<dt>Host</dt><dd>{{.Meta.Hostname}}</dd>
+<dt>Generated</dt><dd>{{.Generated}}</dd>
+<dt>BatStat</dt><dd>{{.Meta.Version}}</dd>
+<dt>Filter</dt><dd>{{if .Meta.Filter}}{{.Meta.Filter}}{{else}}none{{end}}</dd>
+<dt>Sort</dt><dd>{{if .Meta.Sort}}{{.Meta.Sort}}{{else}}none{{end}}</dd>
+</dl>
+<div class="summary">
+{{range .Summary}}<div class="card"><h2>{{.Title}}</h2><table>{{range .Counts}}<tr><td>{{.Label}}</td><td>{{.Count}}</td></tr>{{end}}</table></div>
+{{end}}</div>
+<table class="data" id="data">
+<thead><tr>{{range .Columns}}<th>{{.Title}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td class="wrap">{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+<script>
+(function () {
+  var table = document.getElementById("data");
+  var headers = table.tHead.rows[0].cells;
+  function key(row, i) {
+    var text = row.cells[i].textContent;
+    var n = Number(text);
+    return text !== "" && !isNaN(n) ? n : text.toLowerCase();
+  }
+  Array.prototype.forEach.call(headers, function (th, i) {
+    th.addEventListener("click", function () {
+      var asc = !th.classList.contains("asc");
+      Array.prototype.forEach.call(headers, function (h) { h.classList.remove("asc", "desc"); });
+      th.classList.add(asc ? "asc" : "desc");
+      var body = table.tBodies[0];
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = key(a, i), y = key(b, i);
+        if (x < y) return asc ? -1 : 1;
+        if (x > y) return asc ? 1 : -1;
+        return 0;
+      });
+      rows.forEach(function (r) { body.appendChild(r); });
+    });
+  });
+})();
+</script>
+</body>
+</html>
+`))
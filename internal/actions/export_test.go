@@ -0,0 +1,117 @@
+package actions
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func testDataset() Dataset {
+	conns := []models.Connection{
+		{Family: "IPv4", Type: "TCP", Pid: 812, Fd: 6, ProcessName: "nginx",
+			Laddr: "0.0.0.0:80", Raddr: ":0", Status: "LISTEN"},
+		{Family: "IPv4", Type: "TCP", Pid: 812, Fd: 9, ProcessName: "nginx",
+			Laddr: "10.0.0.1:80", Raddr: "10.0.0.9:51000", Status: "ESTABLISHED", IOC: "10.0.0.9"},
+		// Characters each format has to escape.
+		{Family: "IPv6", Type: "UDP", Pid: 90, Fd: 12, ProcessName: `odd, "name" | <b>&`,
+			Laddr: "::1:53", Raddr: ":::0"},
+	}
+	details := func(c models.Connection) models.DetailedInfo {
+		if c.Pid == 812 {
+			return models.DetailedInfo{Username: "www-data", Cmdline: "nginx: master process"}
+		}
+		return models.DetailedInfo{Username: "root", Cmdline: "resolver --listen \"::1\""}
+	}
+	return ConnectionsDataset(conns, details)
+}
+
+var testMeta = ExportMeta{
+	Hostname:  "web1",
+	Timestamp: time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC),
+	Version:   "v1.2.3",
+	Filter:    "nginx !state:listen",
+	Sort:      "PID ascending",
+}
+
+// checkGolden compares the file at path with testdata/name, or rewrites the
+// latter with -update.
+func checkGolden(t *testing.T, path, name string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s differs from %s:\n%s", filepath.Base(path), golden, got)
+	}
+}
+
+func TestExportGolden(t *testing.T) {
+	dir := t.TempDir()
+	for _, e := range Exporters() {
+		t.Run(e.Format(), func(t *testing.T) {
+			filename, err := Export(testDataset(), testMeta, filepath.Join(dir, "conns"), e.Format())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, "conns"+e.Extension()); filename != want {
+				t.Errorf("filename = %q, want %q", filename, want)
+			}
+			checkGolden(t, filename, "golden"+e.Extension())
+		})
+	}
+	checkGolden(t, filepath.Join(dir, "conns.meta.json"), "golden.meta.json")
+}
+
+func TestExportFormatFromPath(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		path, format string
+		want         string // Written file, relative to dir, or the error
+	}{
+		{"out.html", "", "out.html"},
+		{"out.JSONL", "", "out.JSONL"},
+		{"out", "", "out.csv"},
+		{"out.txt", "", "out.txt"},
+		{"out.txt", "markdown", "out.txt"},
+		{"out.htm", "html", "out.htm"},
+		{"out.html", "json", "the extension of out.html is for html, not json"},
+		{"out", "yaml", `unknown export format "yaml"`},
+	}
+	for _, tt := range tests {
+		filename, err := Export(testDataset(), testMeta, filepath.Join(dir, tt.path), tt.format)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got, _ = filepath.Rel(dir, filename)
+		}
+		if got != tt.want {
+			t.Errorf("Export(%q, %q) = %q, want %q", tt.path, tt.format, got, tt.want)
+		}
+	}
+
+	// Only CSV gets a sidecar.
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.meta.json"))
+	if len(matches) != 1 || !strings.HasSuffix(matches[0], "out.meta.json") {
+		t.Errorf("sidecars = %q", matches)
+	}
+}
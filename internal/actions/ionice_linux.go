@@ -0,0 +1,38 @@
+package actions
+
+import (
+	"fmt"
+	"slices"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassIdle  = 3
+)
+
+// IOClasses are the scheduling classes accepted by Ionice, in the order of
+// the kernel's IOPRIO_CLASS_* values, which start at 1.
+var IOClasses = []string{"realtime", "best-effort", "idle"}
+
+// Ionice sets the I/O scheduling class ("realtime", "best-effort" or
+// "idle") and level (0-7, lower is higher priority) with ioprio_set.
+func Ionice(pid int32, class string, level int) error {
+	c := slices.Index(IOClasses, class) + 1
+	if c == 0 {
+		return fmt.Errorf("unknown I/O class %q", class)
+	}
+	if level < 0 || level > 7 {
+		return fmt.Errorf("I/O priority level must be 0-7, not %d", level)
+	}
+	if c == ioprioClassIdle {
+		level = 0
+	}
+	prio := c<<ioprioClassShift | level
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+		return errno
+	}
+	return nil
+}
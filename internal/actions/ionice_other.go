@@ -0,0 +1,13 @@
+//go:build !linux
+
+package actions
+
+import "errors"
+
+// IOClasses are the Linux scheduling classes, listed so the picker looks the
+// same everywhere; Ionice accepts none of them here.
+var IOClasses = []string{"realtime", "best-effort", "idle"}
+
+func Ionice(pid int32, class string, level int) error {
+	return errors.New("ionice is only supported on Linux")
+}
@@ -0,0 +1,253 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	DefaultKillGrace = 5 * time.Second
+	killPoll         = 100 * time.Millisecond
+	// killWait is how long a SIGKILL gets to take effect.
+	killWait = 2 * time.Second
+)
+
+type KillOptions struct {
+	// Grace is how long processes get to exit after SIGTERM.
+	Grace time.Duration
+	// Escalate sends SIGKILL to whatever is left when Grace runs out.
+	Escalate bool
+	// Force skips SIGTERM and sends SIGKILL straight away.
+	Force bool
+}
+
+// KillTarget is a process identified by PID and start time, so a PID that
+// was reused by an unrelated process is never signalled by mistake.
+type KillTarget struct {
+	Pid     int32
+	Name    string
+	created int64
+}
+
+type KillProgress struct {
+	Stage   string // "SIGTERM" or "SIGKILL"
+	Alive   int
+	Total   int
+	Elapsed time.Duration
+	Limit   time.Duration
+}
+
+type KillReport struct {
+	Targets []KillTarget
+	Exited  []KillTarget // Gone after SIGTERM
+	Killed  []KillTarget // Gone after SIGKILL
+	Reused  []KillTarget // PID now belongs to another process
+	Alive   []KillTarget // Still running
+	Errors  []error
+}
+
+func (r KillReport) Err() error {
+	return errors.Join(r.Errors...)
+}
+
+// Merge combines r with the report of a later kill of its survivors, such
+// as a SIGKILL sent after the grace period.
+func (r KillReport) Merge(next KillReport) KillReport {
+	return KillReport{
+		Targets: r.Targets,
+		Exited:  append(slices.Clip(r.Exited), next.Exited...),
+		Killed:  append(slices.Clip(r.Killed), next.Killed...),
+		Reused:  append(slices.Clip(r.Reused), next.Reused...),
+		Alive:   next.Alive,
+		Errors:  append(slices.Clip(r.Errors), next.Errors...),
+	}
+}
+
+func (r KillReport) Summary() string {
+	s := fmt.Sprintf("%d exited", len(r.Exited))
+	if len(r.Killed) > 0 {
+		s += fmt.Sprintf(", %d killed", len(r.Killed))
+	}
+	if len(r.Reused) > 0 {
+		s += fmt.Sprintf(", %d PID reused", len(r.Reused))
+	}
+	if len(r.Alive) > 0 {
+		s += fmt.Sprintf(", %d still running", len(r.Alive))
+	}
+	return s
+}
+
+// KillTargets resolves pid, and with tree its descendants. Descendants come
+// first, deepest first, so children are signalled before their parents.
+func KillTargets(pid int32, tree bool) ([]KillTarget, error) {
+	root, err := newKillTarget(pid)
+	if err != nil {
+		return nil, err
+	}
+	if !tree {
+		return []KillTarget{root}, nil
+	}
+
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int32][]int32)
+	for _, p := range procs {
+		if ppid, err := p.Ppid(); err == nil && ppid != p.Pid {
+			children[ppid] = append(children[ppid], p.Pid)
+		}
+	}
+
+	var targets []KillTarget
+	// Never signal ourselves, even when BatStat runs inside the tree.
+	seen := map[int32]bool{pid: true, int32(os.Getpid()): true}
+	var walk func(int32)
+	walk = func(parent int32) {
+		for _, child := range children[parent] {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			walk(child)
+			if t, err := newKillTarget(child); err == nil {
+				targets = append(targets, t)
+			}
+		}
+	}
+	walk(pid)
+	return append(targets, root), nil
+}
+
+func newKillTarget(pid int32) (KillTarget, error) {
+	if pid == 0 {
+		return KillTarget{}, errors.New("refusing to signal PID 0")
+	}
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return KillTarget{}, err
+	}
+	created, err := p.CreateTime()
+	if err != nil {
+		return KillTarget{}, err
+	}
+	name, _ := p.Name()
+	return KillTarget{Pid: pid, Name: name, created: created}, nil
+}
+
+// state reports whether t still runs. reused is set when the PID now
+// belongs to a process that started later.
+func (t KillTarget) state() (alive, reused bool) {
+	p, err := process.NewProcess(t.Pid)
+	if err != nil {
+		return false, false
+	}
+	created, err := p.CreateTime()
+	if err != nil {
+		return false, false
+	}
+	if created != t.created {
+		return false, true
+	}
+	if status, err := p.Status(); err == nil && len(status) > 0 && status[0] == process.Zombie {
+		return false, false
+	}
+	return true, false
+}
+
+func (t KillTarget) signal(force bool) error {
+	if alive, _ := t.state(); !alive {
+		return nil
+	}
+	p, err := process.NewProcess(t.Pid)
+	if err != nil {
+		return nil
+	}
+	if force {
+		err = p.Kill()
+	} else {
+		err = p.Terminate()
+	}
+	if err != nil {
+		return fmt.Errorf("PID %d (%s): %w", t.Pid, t.Name, err)
+	}
+	return nil
+}
+
+// GracefulKill sends SIGTERM to every target, waits up to Grace for them
+// to exit and, with Escalate, SIGKILLs the rest. progress is called on every
+// poll and may be nil.
+func GracefulKill(ctx context.Context, targets []KillTarget, opts KillOptions, progress func(KillProgress)) KillReport {
+	report := KillReport{Targets: targets}
+	if opts.Grace <= 0 {
+		opts.Grace = DefaultKillGrace
+	}
+
+	pending := targets
+	if !opts.Force {
+		pending = killStage(ctx, pending, false, opts.Grace, &report, &report.Exited, progress)
+		// Cancelling stops waiting; it must not escalate to SIGKILL.
+		if len(pending) == 0 || !opts.Escalate || ctx.Err() != nil {
+			report.Alive = pending
+			return report
+		}
+	}
+	report.Alive = killStage(ctx, pending, true, killWait, &report, &report.Killed, progress)
+	return report
+}
+
+func killStage(ctx context.Context, targets []KillTarget, force bool, limit time.Duration, report *KillReport, gone *[]KillTarget, progress func(KillProgress)) []KillTarget {
+	stage := "SIGTERM"
+	if force {
+		stage = "SIGKILL"
+	}
+
+	var alive, failed []KillTarget
+	for _, t := range targets {
+		if err := t.signal(force); err != nil {
+			report.Errors = append(report.Errors, err)
+			failed = append(failed, t)
+			continue
+		}
+		alive = append(alive, t)
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(killPoll)
+	defer ticker.Stop()
+	for {
+		var still []KillTarget
+		for _, t := range alive {
+			running, reused := t.state()
+			switch {
+			case reused:
+				report.Reused = append(report.Reused, t)
+			case running:
+				still = append(still, t)
+			default:
+				*gone = append(*gone, t)
+			}
+		}
+		alive = still
+
+		elapsed := time.Since(start)
+		if progress != nil {
+			progress(KillProgress{Stage: stage, Alive: len(alive), Total: len(targets), Elapsed: elapsed, Limit: limit})
+		}
+		if len(alive) == 0 || elapsed >= limit {
+			return append(alive, failed...)
+		}
+
+		select {
+		case <-ctx.Done():
+			return append(alive, failed...)
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,14 +1,19 @@
 package actions
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"runtime"
 	"strings"
+
+	"github.com/MrBrooks89/BatStat/internal/probe"
 )
 
+// Ping sends four echo requests and streams ping(8)-style lines. It backs
+// the agent and batch diagnostics; when ICMP sockets are not permitted it
+// falls back to the system ping binary.
 func Ping(ctx context.Context, ip string, outputChan chan<- string) {
 	defer close(outputChan)
 
@@ -17,42 +22,40 @@ func Ping(ctx context.Context, ip string, outputChan chan<- string) {
 		return
 	}
 
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.CommandContext(ctx, "ping", "-n", "4", ip)
-	default: 
-		cmd = exec.CommandContext(ctx, "ping", "-c", "4", ip)
+	send := func(line string) {
+		select {
+		case <-ctx.Done():
+		case outputChan <- line:
+		}
 	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		outputChan <- fmt.Sprintf("Error creating stdout pipe: %v", err)
+	stats, err := probe.Ping(ctx, ip, probe.PingOptions{Count: 4, Size: probe.DefaultPingSize}, func(r probe.PingReply, _ probe.PingStats) {
+		send(r.String())
+	})
+	if errors.Is(err, probe.ErrNotPermitted) {
+		systemPing(ctx, ip, outputChan)
 		return
 	}
-
-	if err := cmd.Start(); err != nil {
-		outputChan <- fmt.Sprintf("Error starting ping command: %v", err)
+	if err != nil {
+		send(fmt.Sprintf("Ping failed: %v", err))
 		return
 	}
+	send("")
+	send(fmt.Sprintf("--- %s ping statistics ---", ip))
+	send(stats.String())
+	send(stats.RTTSummary())
+}
 
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			_ = cmd.Process.Kill()
-			return
-		case outputChan <- scanner.Text():
-		}
-	}
-
-	if err := cmd.Wait(); err != nil {
-		if _, ok := err.(*exec.ExitError); !ok {
-			outputChan <- fmt.Sprintf("Ping command finished with error: %v", err)
-		}
+func systemPing(ctx context.Context, ip string, outputChan chan<- string) {
+	argv := []string{"ping", "-c", "4", ip}
+	if runtime.GOOS == "windows" {
+		argv = []string{"ping", "-n", "4", ip}
 	}
+	runSystemTool(ctx, argv, outputChan)
 }
 
+// Nslookup resolves the PTR names of an address and forward-confirms each
+// of them with the built-in DNS client, one aligned line per record.
 func Nslookup(ctx context.Context, host string, outputChan chan<- string) {
 	defer close(outputChan)
 
@@ -61,42 +64,37 @@ func Nslookup(ctx context.Context, host string, outputChan chan<- string) {
 		return
 	}
 
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.CommandContext(ctx, "nslookup", host)
-	default: 
-		cmd = exec.CommandContext(ctx, "nslookup", host)
+	send := func(line string) {
+		select {
+		case <-ctx.Done():
+		case outputChan <- line:
+		}
 	}
 
-	stdout, err := cmd.StdoutPipe()
+	report, err := probe.Resolver{}.Inspect(ctx, host)
+	server := report.Server
+	if server == "" {
+		server = "system resolver"
+	}
+	send("Server: " + server)
+	send("")
 	if err != nil {
-		outputChan <- fmt.Sprintf("Error creating stdout pipe: %v", err)
+		send(fmt.Sprintf("Lookup failed: %v", err))
 		return
 	}
-
-	if err := cmd.Start(); err != nil {
-		outputChan <- fmt.Sprintf("Error starting nslookup command: %v", err)
+	if len(report.PTR) == 0 {
+		send(fmt.Sprintf("No PTR record for %s", host))
 		return
 	}
 
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			_ = cmd.Process.Kill()
-			return
-		case outputChan <- scanner.Text():
-		}
-	}
-
-	if err := cmd.Wait(); err != nil {
-		if _, ok := err.(*exec.ExitError); !ok {
-			outputChan <- fmt.Sprintf("Nslookup command finished with error: %v", err)
-		}
+	for _, line := range report.Lines() {
+		send(line)
 	}
 }
 
+// Traceroute probes every hop three times over ICMP and prints the hop
+// table. Without raw socket rights, or on Windows, it falls back to the
+// system traceroute tool.
 func Traceroute(ctx context.Context, host string, outputChan chan<- string) {
 	defer close(outputChan)
 
@@ -105,42 +103,56 @@ func Traceroute(ctx context.Context, host string, outputChan chan<- string) {
 		return
 	}
 
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		cmd = exec.CommandContext(ctx, "tracert", host)
-	case "macos":
-		cmd = exec.CommandContext(ctx, "traceroute", host)
-	case "linux":
-	  cmd = exec.CommandContext(ctx, "tracepath", host)
-	default: 
-		cmd = exec.CommandContext(ctx, "traceroute", host)
+	send := func(line string) {
+		select {
+		case <-ctx.Done():
+		case outputChan <- line:
+		}
 	}
 
-	stdout, err := cmd.StdoutPipe()
+	started := false
+	trace, err := probe.Traceroute(ctx, host, probe.TraceOptions{Rounds: 3}, func(t probe.Trace) {
+		if !started {
+			started = true
+			send(fmt.Sprintf("traceroute to %s (%s), %d hops max, 3 ICMP probes per hop", host, t.Addr, probe.DefaultMaxHops))
+		}
+	})
+	if errors.Is(err, probe.ErrNotPermitted) || errors.Is(err, probe.ErrTraceUnsupported) {
+		systemTraceroute(ctx, host, outputChan)
+		return
+	}
 	if err != nil {
-		outputChan <- fmt.Sprintf("Error creating stdout pipe: %v", err)
+		send(fmt.Sprintf("Traceroute failed: %v", err))
 		return
 	}
 
-	if err := cmd.Start(); err != nil {
-		outputChan <- fmt.Sprintf("Error starting traceroute command: %v", err)
-		return
+	for _, line := range trace.Lines() {
+		send(line)
+	}
+	if !trace.Reached {
+		send(fmt.Sprintf("%s not reached", host))
 	}
+}
 
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			_ = cmd.Process.Kill()
-			return
-		case outputChan <- scanner.Text():
-		}
+func systemTraceroute(ctx context.Context, host string, outputChan chan<- string) {
+	var argv []string
+	switch runtime.GOOS {
+	case "windows":
+		argv = []string{"tracert", host}
+	case "linux":
+		argv = []string{"tracepath", host}
+	default:
+		argv = []string{"traceroute", host}
 	}
+	runSystemTool(ctx, argv, outputChan)
+}
 
-	if err := cmd.Wait(); err != nil {
-		if _, ok := err.(*exec.ExitError); !ok {
-			outputChan <- fmt.Sprintf("Traceroute command finished with error: %v", err)
-		}
+// runSystemTool streams a fallback tool's output. Its exit status is not
+// reported, since ping and traceroute fail when the target does not answer.
+func runSystemTool(ctx context.Context, argv []string, outputChan chan<- string) {
+	err := RunCommand(ctx, argv, outputChan)
+	var exitErr *exec.ExitError
+	if err != nil && ctx.Err() == nil && !errors.As(err, &exitErr) {
+		outputChan <- fmt.Sprintf("Error running %s: %v", argv[0], err)
 	}
 }
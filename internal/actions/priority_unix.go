@@ -0,0 +1,27 @@
+//go:build !windows
+
+package actions
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+func Nice(pid int32) (int, error) {
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, int(pid))
+	if err != nil {
+		return 0, err
+	}
+	if runtime.GOOS == "linux" {
+		// The raw Linux syscall returns 20 - nice so it is never negative.
+		return 20 - prio, nil
+	}
+	return prio, nil
+}
+
+// Renice sets the scheduling priority of pid; lower values run first.
+// Raising priority (a negative change) needs root.
+func Renice(pid int32, nice int) error {
+	return unix.Setpriority(unix.PRIO_PROCESS, int(pid), nice)
+}
@@ -0,0 +1,11 @@
+package actions
+
+import "errors"
+
+func Nice(pid int32) (int, error) {
+	return 0, errors.New("nice values are not supported on Windows")
+}
+
+func Renice(pid int32, nice int) error {
+	return errors.New("renice is not supported on Windows")
+}
@@ -23,4 +23,3 @@ func ForceKillProcess(pid int32) error {
 	}
 	return p.Kill()
 }
-
@@ -0,0 +1,34 @@
+package actions
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+type Signal struct {
+	Name        string
+	Signal      syscall.Signal
+	Description string
+}
+
+func (s Signal) String() string {
+	return fmt.Sprintf("SIG%s (%d)", s.Name, int(s.Signal))
+}
+
+// Signals lists the signals this platform can deliver, in numeric order.
+func Signals() []Signal {
+	return signalTable
+}
+
+func SendSignal(pid int32, sig syscall.Signal) error {
+	if pid == 0 {
+		return nil
+	}
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.SendSignal(sig)
+}
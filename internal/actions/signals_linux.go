@@ -0,0 +1,44 @@
+package actions
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// The real-time signals as kill -l numbers them: glibc and musl keep the
+// kernel's first two, 32 and 33, for threading. MIPS has more above 64,
+// which are not listed.
+const (
+	sigRTMin = 34
+	sigRTMax = 64
+)
+
+// sigStkflt is SIGSTKFLT, which the syscall package lacks on MIPS. There 16
+// is SIGUSR1, so the entry is dropped as a duplicate.
+const sigStkflt = syscall.Signal(16)
+
+// platformSignals are the Linux signals other Unixes do not have.
+var platformSignals = func() []Signal {
+	table := []Signal{
+		{"STKFLT", sigStkflt, "Coprocessor stack fault (unused by the kernel)"},
+		{"PWR", syscall.SIGPWR, "Power failure"},
+	}
+	for n := sigRTMin; n <= sigRTMax; n++ {
+		table = append(table, Signal{rtName(n), syscall.Signal(n), "Real-time signal (app-specific)"})
+	}
+	return table
+}()
+
+// rtName names real-time signal n the way kill -l does: from RTMIN up for
+// the lower half and from RTMAX down for the upper.
+func rtName(n int) string {
+	switch {
+	case n == sigRTMin:
+		return "RTMIN"
+	case n == sigRTMax:
+		return "RTMAX"
+	case n-sigRTMin <= (sigRTMax-sigRTMin)/2:
+		return fmt.Sprintf("RTMIN+%d", n-sigRTMin)
+	}
+	return fmt.Sprintf("RTMAX-%d", sigRTMax-n)
+}
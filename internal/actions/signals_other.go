@@ -0,0 +1,7 @@
+//go:build !linux && !windows
+
+package actions
+
+// platformSignals are the signals beyond the common Unix set; there are none
+// worth listing here.
+var platformSignals []Signal
@@ -0,0 +1,48 @@
+//go:build !windows
+
+package actions
+
+import (
+	"slices"
+	"sort"
+	"syscall"
+)
+
+var signalTable = func() []Signal {
+	table := []Signal{
+		{"HUP", syscall.SIGHUP, "Hangup; many daemons reload their config"},
+		{"INT", syscall.SIGINT, "Interrupt, as with Ctrl-C"},
+		{"QUIT", syscall.SIGQUIT, "Quit and dump core (Go programs print all goroutines)"},
+		{"ILL", syscall.SIGILL, "Illegal instruction"},
+		{"TRAP", syscall.SIGTRAP, "Trace/breakpoint trap"},
+		{"ABRT", syscall.SIGABRT, "Abort and dump core"},
+		{"BUS", syscall.SIGBUS, "Bus error"},
+		{"FPE", syscall.SIGFPE, "Floating point exception"},
+		{"KILL", syscall.SIGKILL, "Kill immediately; cannot be caught"},
+		{"USR1", syscall.SIGUSR1, "User-defined 1 (log reopen, status dump, ...)"},
+		{"SEGV", syscall.SIGSEGV, "Segmentation fault"},
+		{"USR2", syscall.SIGUSR2, "User-defined 2 (app-specific)"},
+		{"PIPE", syscall.SIGPIPE, "Broken pipe"},
+		{"ALRM", syscall.SIGALRM, "Alarm clock"},
+		{"TERM", syscall.SIGTERM, "Terminate gracefully"},
+		{"CHLD", syscall.SIGCHLD, "Child status changed"},
+		{"CONT", syscall.SIGCONT, "Continue a stopped process"},
+		{"STOP", syscall.SIGSTOP, "Stop (freeze) the process; cannot be caught"},
+		{"TSTP", syscall.SIGTSTP, "Terminal stop, as with Ctrl-Z"},
+		{"TTIN", syscall.SIGTTIN, "Background read from terminal"},
+		{"TTOU", syscall.SIGTTOU, "Background write to terminal"},
+		{"URG", syscall.SIGURG, "Urgent data on socket"},
+		{"XCPU", syscall.SIGXCPU, "CPU time limit exceeded"},
+		{"XFSZ", syscall.SIGXFSZ, "File size limit exceeded"},
+		{"VTALRM", syscall.SIGVTALRM, "Virtual timer expired"},
+		{"PROF", syscall.SIGPROF, "Profiling timer expired"},
+		{"WINCH", syscall.SIGWINCH, "Window size changed"},
+		{"IO", syscall.SIGIO, "I/O possible"},
+		{"SYS", syscall.SIGSYS, "Bad system call"},
+	}
+	table = append(table, platformSignals...)
+	sort.SliceStable(table, func(i, j int) bool { return table[i].Signal < table[j].Signal })
+	// Where a platform signal shares its number with a common one, the
+	// common name wins.
+	return slices.CompactFunc(table, func(a, b Signal) bool { return a.Signal == b.Signal })
+}()
@@ -0,0 +1,8 @@
+package actions
+
+import "syscall"
+
+// Windows has no signals; only termination can be delivered.
+var signalTable = []Signal{
+	{"KILL", syscall.SIGKILL, "Terminate the process"},
+}
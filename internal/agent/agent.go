@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+const (
+	snapshotPath = "/v1/snapshot"
+	killPath     = "/v1/kill"
+	diagPath     = "/v1/diag/"
+
+	TokenEnv = "BATSTAT_AGENT_TOKEN"
+
+	// Timeout bounds a snapshot request so one slow agent cannot stall a
+	// refresh.
+	Timeout = 5 * time.Second
+)
+
+type Snapshot struct {
+	Host        string                        `json:"host"`
+	Time        time.Time                     `json:"time"`
+	Connections []models.Connection           `json:"connections"`
+	Details     map[int32]models.DetailedInfo `json:"details"`
+}
+
+type KillRequest struct {
+	Pid   int32 `json:"pid"`
+	Force bool  `json:"force"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type ClientOptions struct {
+	Token string
+	// CAFile is a PEM bundle used instead of the system roots.
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+type Client struct {
+	Name    string
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient connects to the agent at rawURL. An empty name defaults to the
+// URL's host.
+func NewClient(name, rawURL string, opts ClientOptions) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid agent URL %q", rawURL)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("agent URL %q must use http or https", rawURL)
+	}
+	if name == "" {
+		name = u.Hostname()
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &Client{
+		Name:    name,
+		baseURL: strings.TrimRight(u.String(), "/"),
+		token:   opts.Token,
+		http:    &http.Client{Transport: transport},
+	}, nil
+}
+
+func (c *Client) Snapshot(ctx context.Context) (Snapshot, error) {
+	var snap Snapshot
+	resp, err := c.do(ctx, http.MethodGet, snapshotPath, nil)
+	if err != nil {
+		return snap, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return snap, fmt.Errorf("decoding snapshot from %s: %w", c.Name, err)
+	}
+	for i := range snap.Connections {
+		snap.Connections[i].Host = c.Name
+	}
+	return snap, nil
+}
+
+func (c *Client) Kill(ctx context.Context, pid int32, force bool) error {
+	body, err := json.Marshal(KillRequest{Pid: pid, Force: force})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, killPath, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Diag runs a diagnostic tool on the agent and forwards its output line by
+// line, matching the signature of the local actions.
+func (c *Client) Diag(ctx context.Context, tool, target string, outputChan chan<- string) {
+	defer close(outputChan)
+
+	resp, err := c.do(ctx, http.MethodGet, diagPath+tool+"?target="+url.QueryEscape(target), nil)
+	if err != nil {
+		outputChan <- err.Error()
+		return
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return
+		case outputChan <- scanner.Text():
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		outputChan <- fmt.Sprintf("Connection to %s lost: %v", c.Name, err)
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var e errorResponse
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e) == nil && e.Error != "" {
+			return nil, fmt.Errorf("%s: %s", c.Name, e.Error)
+		}
+		return nil, fmt.Errorf("%s: %s", c.Name, resp.Status)
+	}
+	return resp, nil
+}
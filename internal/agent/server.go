@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/conn"
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+type ServerOptions struct {
+	Addr     string
+	Token    string
+	CertFile string
+	KeyFile  string
+	// AllowActions enables the kill and diagnostic endpoints.
+	AllowActions bool
+	// Insecure permits serving plain HTTP when no certificate is given.
+	Insecure bool
+}
+
+type Server struct {
+	opts     ServerOptions
+	hostname string
+	fetch    func() ([]models.Connection, error)
+	mux      *http.ServeMux
+}
+
+func NewServer(opts ServerOptions) (*Server, error) {
+	if opts.Token == "" {
+		return nil, errors.New("an access token is required")
+	}
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("both a TLS certificate and key are required")
+	}
+	if opts.CertFile == "" && !opts.Insecure {
+		return nil, errors.New("a TLS certificate is required (pass -insecure to serve plain HTTP on a trusted network)")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	s := &Server{
+		opts:     opts,
+		hostname: hostname,
+		fetch:    conn.FetchConnections,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc(snapshotPath, s.handleSnapshot)
+	s.mux.HandleFunc(killPath, s.handleKill)
+	s.mux.HandleFunc(diagPath, s.handleDiag)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe blocks until ctx is canceled or the listener fails.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	addr := s.opts.Addr
+	if addr == "" {
+		addr = ":http"
+		if s.opts.CertFile != "" {
+			addr = ":https"
+		}
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve is ListenAndServe on an existing listener, which it closes.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	server := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if s.opts.CertFile != "" {
+		err = server.ServeTLS(ln, s.opts.CertFile, s.opts.KeyFile)
+	} else {
+		err = server.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) == 1
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	conns, err := s.fetch()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	details := make(map[int32]models.DetailedInfo)
+	for _, c := range conns {
+		if _, ok := details[c.Pid]; !ok && c.Pid != 0 {
+			details[c.Pid] = models.GetDetailedInfo(c.Pid)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, Snapshot{
+		Host:        s.hostname,
+		Time:        time.Now(),
+		Connections: conns,
+		Details:     details,
+	})
+}
+
+func (s *Server) handleKill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.opts.AllowActions {
+		writeError(w, http.StatusForbidden, "actions are disabled on this agent")
+		return
+	}
+
+	var req KillRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Pid <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid pid")
+		return
+	}
+
+	kill := actions.KillProcess
+	if req.Force {
+		kill = actions.ForceKillProcess
+	}
+	if err := kill(req.Pid); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var diagTools = map[string]func(ctx context.Context, host string, outputChan chan<- string){
+	"ping":       actions.Ping,
+	"nslookup":   actions.Nslookup,
+	"traceroute": actions.Traceroute,
+}
+
+// handleDiag streams the tool's output as plain text, one line per flush.
+func (s *Server) handleDiag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.opts.AllowActions {
+		writeError(w, http.StatusForbidden, "actions are disabled on this agent")
+		return
+	}
+
+	tool, ok := diagTools[strings.TrimPrefix(r.URL.Path, diagPath)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown diagnostic")
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if net.ParseIP(target) == nil {
+		writeError(w, http.StatusBadRequest, "target must be an IP address")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	out := bufio.NewWriter(w)
+
+	lines := make(chan string)
+	go tool(r.Context(), target, lines)
+	for line := range lines {
+		fmt.Fprintln(out, line)
+		out.Flush()
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
@@ -0,0 +1,313 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/filter"
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+const (
+	ActionHighlight = "highlight"
+	ActionPane      = "pane"
+	ActionBell      = "bell"
+	ActionExec      = "exec"
+	ActionWebhook   = "webhook"
+
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+
+	maxSample = 10
+)
+
+// Rule is one alert definition from the config file.
+//
+//	{"name": "close_wait leak", "filter": "state:close_wait", "group_by": "process",
+//	 "threshold": 200, "for": "1m", "actions": ["pane", "bell"]}
+type Rule struct {
+	Name   string `json:"name"`
+	Filter string `json:"filter"`
+	// Threshold is the number of matching connections (per group) needed to
+	// fire. Zero means 1.
+	Threshold int `json:"threshold"`
+	// GroupBy splits the count by process, pid, host, laddr, raddr, lport,
+	// rport or state.
+	GroupBy string `json:"group_by"`
+	// For is how long the threshold must be exceeded, e.g. "30s".
+	For string `json:"for"`
+	// New only counts connections that were not present on the previous
+	// refresh. For is ignored for such rules.
+	New     bool     `json:"new"`
+	Actions []string `json:"actions"`
+	// Exec is an argv list run without a shell. {rule}, {group}, {count},
+	// {threshold}, {state} and {message} are substituted in each argument.
+	Exec    []string `json:"exec"`
+	Webhook string   `json:"webhook"`
+}
+
+type Alert struct {
+	Rule        string              `json:"rule"`
+	Group       string              `json:"group,omitempty"`
+	State       string              `json:"state"`
+	Count       int                 `json:"count"`
+	Threshold   int                 `json:"threshold"`
+	Since       time.Time           `json:"since"`
+	Time        time.Time           `json:"time"`
+	Message     string              `json:"message"`
+	Connections []models.Connection `json:"connections,omitempty"`
+
+	rule *rule
+}
+
+func (a Alert) Has(action string) bool {
+	return a.rule != nil && a.rule.actions[action]
+}
+
+type Result struct {
+	// Events are the alerts that fired or resolved during this evaluation.
+	Events []Alert
+	Active []Alert
+	// Highlighted maps connection keys to the rule that wants them marked.
+	Highlighted map[string]string
+}
+
+type rule struct {
+	Rule
+	query   filter.Query
+	hold    time.Duration
+	group   func(models.Connection) string
+	actions map[string]bool
+}
+
+type Engine struct {
+	mu      sync.Mutex
+	rules   []*rule
+	pending map[string]time.Time
+	active  map[string]Alert
+	seen    map[string]bool
+	primed  bool
+	notify  *notifier
+}
+
+var groupers = map[string]func(models.Connection) string{
+	"":        func(models.Connection) string { return "" },
+	"process": func(c models.Connection) string { return c.ProcessName },
+	"pid":     func(c models.Connection) string { return strconv.Itoa(int(c.Pid)) },
+	"host":    func(c models.Connection) string { return c.Host },
+	"laddr":   func(c models.Connection) string { return c.LocalIP() },
+	"raddr":   func(c models.Connection) string { return c.RemoteIP() },
+	"lport":   func(c models.Connection) string { return strconv.Itoa(int(c.LocalPort())) },
+	"rport":   func(c models.Connection) string { return strconv.Itoa(int(c.RemotePort())) },
+	"state":   func(c models.Connection) string { return c.Status },
+}
+
+func NewEngine(rules []Rule) (*Engine, error) {
+	e := &Engine{
+		pending: make(map[string]time.Time),
+		active:  make(map[string]Alert),
+		notify:  newNotifier(),
+	}
+
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if r.Threshold <= 0 {
+			r.Threshold = 1
+		}
+		compiled := &rule{Rule: r, query: filter.Parse(r.Filter), actions: make(map[string]bool)}
+
+		if r.For != "" {
+			d, err := time.ParseDuration(r.For)
+			if err != nil {
+				return nil, fmt.Errorf("alert %q: invalid duration %q", r.Name, r.For)
+			}
+			compiled.hold = d
+		}
+
+		group, ok := groupers[r.GroupBy]
+		if !ok {
+			return nil, fmt.Errorf("alert %q: cannot group by %q", r.Name, r.GroupBy)
+		}
+		compiled.group = group
+
+		for _, action := range r.Actions {
+			switch action {
+			case ActionHighlight, ActionPane, ActionBell:
+			case ActionExec:
+				if len(r.Exec) == 0 {
+					return nil, fmt.Errorf("alert %q: exec action without an exec command", r.Name)
+				}
+			case ActionWebhook:
+				if r.Webhook == "" {
+					return nil, fmt.Errorf("alert %q: webhook action without a webhook URL", r.Name)
+				}
+			default:
+				return nil, fmt.Errorf("alert %q: unknown action %q", r.Name, action)
+			}
+			compiled.actions[action] = true
+		}
+		if len(compiled.actions) == 0 {
+			compiled.actions[ActionPane] = true
+		}
+
+		e.rules = append(e.rules, compiled)
+	}
+	return e, nil
+}
+
+func (e *Engine) Empty() bool {
+	return e == nil || len(e.rules) == 0
+}
+
+// Evaluate checks every rule against a full snapshot and returns the state
+// transitions since the previous call.
+func (e *Engine) Evaluate(conns []models.Connection, now time.Time) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	res := Result{Highlighted: make(map[string]string)}
+
+	seen := make(map[string]bool, len(conns))
+	for _, c := range conns {
+		seen[c.Key()] = true
+	}
+
+	for _, r := range e.rules {
+		counts := make(map[string]int)
+		samples := make(map[string][]models.Connection)
+		for _, c := range conns {
+			if r.New && (!e.primed || e.seen[c.Key()]) {
+				continue
+			}
+			if !r.query.Match(c) {
+				continue
+			}
+			g := r.group(c)
+			counts[g]++
+			samples[g] = append(samples[g], c)
+		}
+
+		for g, n := range counts {
+			id := r.Name + "\x00" + g
+			if n < r.Threshold {
+				delete(e.pending, id)
+				continue
+			}
+			since, ok := e.pending[id]
+			if !ok {
+				since = now
+				e.pending[id] = now
+			}
+
+			alert, firing := e.active[id]
+			if !firing {
+				if !r.New && now.Sub(since) < r.hold {
+					continue
+				}
+				alert = Alert{Rule: r.Name, Group: g, State: StateFiring, Threshold: r.Threshold, Since: since, Time: now, rule: r}
+				alert.Count = n
+				alert.Message = message(r, g, n)
+				alert.Connections = sample(samples[g])
+				e.active[id] = alert
+				res.Events = append(res.Events, alert)
+			} else {
+				alert.Count = n
+				alert.Message = message(r, g, n)
+				alert.Connections = sample(samples[g])
+				e.active[id] = alert
+			}
+
+			if r.actions[ActionHighlight] {
+				for _, c := range samples[g] {
+					res.Highlighted[c.Key()] = r.Name
+				}
+			}
+		}
+
+		prefix := r.Name + "\x00"
+		for id := range e.pending {
+			if g, ok := strings.CutPrefix(id, prefix); ok && counts[g] < r.Threshold {
+				delete(e.pending, id)
+			}
+		}
+
+		for id, alert := range e.active {
+			if alert.rule != r {
+				continue
+			}
+			if n := counts[alert.Group]; n >= r.Threshold {
+				continue
+			}
+			delete(e.active, id)
+			delete(e.pending, id)
+			alert.State = StateResolved
+			alert.Count = counts[alert.Group]
+			alert.Time = now
+			alert.Connections = nil
+			alert.Message = fmt.Sprintf("%s resolved", alertLabel(r, alert.Group))
+			res.Events = append(res.Events, alert)
+		}
+	}
+
+	e.seen = seen
+	e.primed = true
+
+	for _, a := range e.active {
+		res.Active = append(res.Active, a)
+	}
+	sort.Slice(res.Active, func(i, j int) bool {
+		if !res.Active[i].Since.Equal(res.Active[j].Since) {
+			return res.Active[i].Since.Before(res.Active[j].Since)
+		}
+		return res.Active[i].Rule+res.Active[i].Group < res.Active[j].Rule+res.Active[j].Group
+	})
+	return res
+}
+
+// Dispatch runs the exec and webhook actions for an alert event. It blocks,
+// so callers usually run it in a goroutine.
+func (e *Engine) Dispatch(a Alert) error {
+	if a.rule == nil {
+		return nil
+	}
+	var errs []error
+	if a.rule.actions[ActionExec] && a.State == StateFiring {
+		if err := e.notify.exec(a.rule.Exec, a); err != nil {
+			errs = append(errs, fmt.Errorf("alert %q exec: %w", a.Rule, err))
+		}
+	}
+	if a.rule.actions[ActionWebhook] {
+		if err := e.notify.webhook(a.rule.Webhook, a); err != nil {
+			errs = append(errs, fmt.Errorf("alert %q webhook: %w", a.Rule, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func alertLabel(r *rule, group string) string {
+	if group == "" || r.GroupBy == "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s [%s=%s]", r.Name, r.GroupBy, group)
+}
+
+func message(r *rule, group string, n int) string {
+	if r.New {
+		return fmt.Sprintf("%s: %d new connection(s)", alertLabel(r, group), n)
+	}
+	return fmt.Sprintf("%s: %d connections (threshold %d)", alertLabel(r, group), n, r.Threshold)
+}
+
+func sample(conns []models.Connection) []models.Connection {
+	if len(conns) > maxSample {
+		conns = conns[:maxSample]
+	}
+	return append([]models.Connection(nil), conns...)
+}
@@ -0,0 +1,199 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+func closeWaits(process string, n int) []models.Connection {
+	conns := make([]models.Connection, n)
+	for i := range conns {
+		conns[i] = models.Connection{
+			Family: "IPv4", Type: "TCP", Status: "CLOSE_WAIT", ProcessName: process, Pid: 100,
+			Laddr: "10.0.0.1:8080", Raddr: fmt.Sprintf("10.0.0.2:%d", 40000+i),
+		}
+	}
+	return conns
+}
+
+func newEngine(t *testing.T, rules ...Rule) *Engine {
+	t.Helper()
+	e, err := NewEngine(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestThresholdPerGroup(t *testing.T) {
+	e := newEngine(t, Rule{Name: "leak", Filter: "state:close_wait", GroupBy: "process", Threshold: 3})
+	now := time.Now()
+
+	res := e.Evaluate(append(closeWaits("api", 3), closeWaits("worker", 2)...), now)
+	if len(res.Events) != 1 {
+		t.Fatalf("got %d events, want 1: %+v", len(res.Events), res.Events)
+	}
+	a := res.Events[0]
+	if a.State != StateFiring || a.Group != "api" || a.Count != 3 || a.Threshold != 3 {
+		t.Errorf("event = %+v", a)
+	}
+	if want := "leak [process=api]: 3 connections (threshold 3)"; a.Message != want {
+		t.Errorf("Message = %q, want %q", a.Message, want)
+	}
+	if !a.Has(ActionPane) {
+		t.Error("rules without actions should default to the pane")
+	}
+}
+
+func TestHoldAndRefiring(t *testing.T) {
+	e := newEngine(t, Rule{Name: "leak", Filter: "state:close_wait", Threshold: 2, For: "1m"})
+	start := time.Now()
+	conns := closeWaits("api", 2)
+
+	// Over the threshold, but not for long enough.
+	for _, d := range []time.Duration{0, 30 * time.Second, 59 * time.Second} {
+		if res := e.Evaluate(conns, start.Add(d)); len(res.Events) != 0 {
+			t.Fatalf("at %s: fired early: %+v", d, res.Events)
+		}
+	}
+	res := e.Evaluate(conns, start.Add(time.Minute))
+	if len(res.Events) != 1 || res.Events[0].State != StateFiring || !res.Events[0].Since.Equal(start) {
+		t.Fatalf("at 1m: events = %+v", res.Events)
+	}
+
+	// While it stays active it is reported, but not fired again.
+	res = e.Evaluate(closeWaits("api", 5), start.Add(2*time.Minute))
+	if len(res.Events) != 0 {
+		t.Errorf("fired again while active: %+v", res.Events)
+	}
+	if len(res.Active) != 1 || res.Active[0].Count != 5 {
+		t.Errorf("active = %+v", res.Active)
+	}
+
+	res = e.Evaluate(closeWaits("api", 1), start.Add(3*time.Minute))
+	if len(res.Events) != 1 || res.Events[0].State != StateResolved || len(res.Active) != 0 {
+		t.Fatalf("after dropping below: events = %+v, active = %+v", res.Events, res.Active)
+	}
+
+	// Once resolved, the hold starts over.
+	if res := e.Evaluate(conns, start.Add(4*time.Minute)); len(res.Events) != 0 {
+		t.Errorf("refired without waiting: %+v", res.Events)
+	}
+	if res := e.Evaluate(conns, start.Add(5*time.Minute)); len(res.Events) != 1 {
+		t.Errorf("did not refire after the hold: %+v", res.Events)
+	}
+}
+
+func TestHoldResetsWhenBelowThreshold(t *testing.T) {
+	e := newEngine(t, Rule{Name: "leak", Filter: "state:close_wait", Threshold: 2, For: "1m"})
+	start := time.Now()
+
+	e.Evaluate(closeWaits("api", 2), start)
+	e.Evaluate(closeWaits("api", 1), start.Add(30*time.Second))
+	if res := e.Evaluate(closeWaits("api", 2), start.Add(70*time.Second)); len(res.Events) != 0 {
+		t.Errorf("fired although the count dipped within the hold: %+v", res.Events)
+	}
+}
+
+func TestNewConnections(t *testing.T) {
+	e := newEngine(t, Rule{Name: "outbound", Filter: "proc:postgres", New: true, Actions: []string{ActionHighlight}})
+	now := time.Now()
+	old := models.Connection{Type: "TCP", ProcessName: "postgres", Laddr: "10.0.0.1:5432", Raddr: "10.0.0.5:40000", Status: "ESTABLISHED"}
+	fresh := models.Connection{Type: "TCP", ProcessName: "postgres", Laddr: "10.0.0.1:51000", Raddr: "203.0.113.7:443", Status: "ESTABLISHED"}
+
+	// The first snapshot only primes what counts as known.
+	if res := e.Evaluate([]models.Connection{old}, now); len(res.Events) != 0 {
+		t.Fatalf("fired on the first snapshot: %+v", res.Events)
+	}
+	res := e.Evaluate([]models.Connection{old, fresh}, now.Add(time.Second))
+	if len(res.Events) != 1 || res.Events[0].Count != 1 {
+		t.Fatalf("events = %+v", res.Events)
+	}
+	if res.Highlighted[fresh.Key()] != "outbound" || res.Highlighted[old.Key()] != "" {
+		t.Errorf("highlighted = %v", res.Highlighted)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, r := range []Rule{
+		{Name: "a", For: "soon"},
+		{Name: "b", GroupBy: "colour"},
+		{Name: "c", Actions: []string{"email"}},
+		{Name: "d", Actions: []string{ActionExec}},
+		{Name: "e", Actions: []string{ActionWebhook}},
+	} {
+		if _, err := NewEngine([]Rule{r}); err == nil {
+			t.Errorf("rule %+v: no error", r)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	type request struct {
+		method, contentType string
+		alert               Alert
+	}
+	received := make(chan request, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var a Alert
+		if err := json.Unmarshal(body, &a); err != nil {
+			t.Errorf("webhook body %q: %v", body, err)
+		}
+		received <- request{r.Method, r.Header.Get("Content-Type"), a}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	e := newEngine(t, Rule{Name: "leak", Filter: "state:close_wait", GroupBy: "process", Threshold: 2,
+		Actions: []string{ActionWebhook}, Webhook: srv.URL})
+	now := time.Now()
+
+	res := e.Evaluate(closeWaits("api", 2), now)
+	if len(res.Events) != 1 {
+		t.Fatalf("events = %+v", res.Events)
+	}
+	if err := e.Dispatch(res.Events[0]); err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	if got.method != http.MethodPost || got.contentType != "application/json" {
+		t.Errorf("request = %s with %q", got.method, got.contentType)
+	}
+	a := got.alert
+	if a.Rule != "leak" || a.Group != "api" || a.State != StateFiring || a.Count != 2 || a.Threshold != 2 {
+		t.Errorf("payload = %+v", a)
+	}
+	if len(a.Connections) != 2 || a.Connections[0].Status != "CLOSE_WAIT" {
+		t.Errorf("payload connections = %+v", a.Connections)
+	}
+
+	// Resolutions are posted too.
+	res = e.Evaluate(nil, now.Add(time.Second))
+	if err := e.Dispatch(res.Events[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got.alert.State != StateResolved || got.alert.Count != 0 {
+		t.Errorf("resolved payload = %+v", got.alert)
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	e := newEngine(t, Rule{Name: "any", Actions: []string{ActionWebhook}, Webhook: srv.URL})
+	res := e.Evaluate(closeWaits("api", 1), time.Now())
+	if err := e.Dispatch(res.Events[0]); err == nil {
+		t.Error("no error for a 500 response")
+	}
+}
@@ -0,0 +1,65 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const notifyTimeout = 30 * time.Second
+
+type notifier struct {
+	client *http.Client
+}
+
+func newNotifier() *notifier {
+	return &notifier{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *notifier) exec(argv []string, a Alert) error {
+	replacer := strings.NewReplacer(
+		"{rule}", a.Rule,
+		"{group}", a.Group,
+		"{count}", strconv.Itoa(a.Count),
+		"{threshold}", strconv.Itoa(a.Threshold),
+		"{state}", a.State,
+		"{message}", a.Message,
+	)
+	args := make([]string, len(argv))
+	for i, arg := range argv {
+		args[i] = replacer.Replace(arg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+func (n *notifier) webhook(url string, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/alerts"
+	"github.com/MrBrooks89/BatStat/internal/intel"
+)
+
+type Config struct {
+	Alerts      []alerts.Rule            `json:"alerts"`
+	Intel       intel.Options            `json:"intel"`
+	Kill        Kill                     `json:"kill"`
+	Diagnostics []actions.DiagnosticSpec `json:"diagnostics"`
+	History     History                  `json:"history"`
+	Stats       Stats                    `json:"stats"`
+}
+
+// Stats sizes the in-memory connection trends.
+type Stats struct {
+	Window string `json:"window"` // e.g. "1h", default 30m
+	Top    int    `json:"top"`    // Processes and remote hosts shown, default 5
+}
+
+// History sets where past diagnostic runs are kept.
+type History struct {
+	Dir      string `json:"dir"`  // Default: batstat/history in the user cache directory
+	Keep     int    `json:"keep"` // Runs per remote address, default 100
+	Disabled bool   `json:"disabled"`
+}
+
+// Kill sets the defaults of the kill dialog.
+type Kill struct {
+	Grace    string `json:"grace"` // e.g. "10s"
+	Escalate bool   `json:"escalate"`
+	Tree     bool   `json:"tree"`
+}
+
+// DefaultPath is $XDG_CONFIG_HOME/batstat/config.json or the platform
+// equivalent.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "batstat", "config.json")
+}
+
+// Load reads the config at path. A missing file is only an error when the
+// path was given explicitly.
+func Load(path string) (Config, error) {
+	var cfg Config
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+		if path == "" {
+			return cfg, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return cfg, nil
+}
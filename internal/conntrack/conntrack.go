@@ -0,0 +1,76 @@
+// Package conntrack reads the kernel's connection tracking table, which
+// also holds forwarded and NATed flows that have no local socket.
+package conntrack
+
+import (
+	"errors"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+var ErrUnsupported = errors.New("connection tracking is only available on Linux")
+
+// Tuple is one direction of a flow. Ports are zero for protocols without
+// them, such as ICMP.
+type Tuple struct {
+	Src, Dst         netip.Addr
+	SrcPort, DstPort uint16
+}
+
+// SrcAddr formats the source the way models.Connection formats addresses.
+func (t Tuple) SrcAddr() string {
+	return t.Src.String() + ":" + strconv.Itoa(int(t.SrcPort))
+}
+
+func (t Tuple) DstAddr() string {
+	return t.Dst.String() + ":" + strconv.Itoa(int(t.DstPort))
+}
+
+type Counters struct {
+	Packets, Bytes uint64
+}
+
+// Flow is one conntrack entry. Reply differs from the inverse of Orig when
+// the flow is NATed.
+type Flow struct {
+	ID       uint32 // Zero when read from /proc
+	Family   string // IPv4 or IPv6
+	Protocol string // tcp, udp, icmp, ...
+	Orig     Tuple
+	Reply    Tuple
+	State    string // TCP state, e.g. ESTABLISHED or TIME_WAIT; empty otherwise
+	Timeout  time.Duration
+	Mark     uint32
+	Zone     uint16
+	Assured  bool // Seen traffic both ways long enough to be kept under pressure
+	Replied  bool
+	SNAT     bool
+	DNAT     bool
+
+	// Byte and packet counters need net.netfilter.nf_conntrack_acct=1.
+	HasCounters   bool
+	OrigCounters  Counters
+	ReplyCounters Counters
+}
+
+// NAT describes the translation applied to the flow, if any.
+func (f Flow) NAT() string {
+	switch {
+	case f.SNAT && f.DNAT:
+		return "SNAT+DNAT"
+	case f.SNAT:
+		return "SNAT"
+	case f.DNAT:
+		return "DNAT"
+	}
+	return ""
+}
+
+// inferNAT sets SNAT and DNAT from the tuples: a reply that does not go back
+// to the original source means the source was rewritten, and one that does
+// not come from the original destination means the destination was.
+func (f *Flow) inferNAT() {
+	f.SNAT = f.SNAT || f.Reply.Dst != f.Orig.Src || f.Reply.DstPort != f.Orig.SrcPort
+	f.DNAT = f.DNAT || f.Reply.Src != f.Orig.Dst || f.Reply.SrcPort != f.Orig.DstPort
+}
@@ -0,0 +1,335 @@
+package conntrack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// ctnetlink message and attribute types (linux/netfilter/nfnetlink_conntrack.h).
+const (
+	ctMsgNew = 0
+	ctMsgGet = 1
+
+	sizeofNfgenmsg = 4
+
+	ctaTupleOrig     = 1
+	ctaTupleReply    = 2
+	ctaStatus        = 3
+	ctaProtoinfo     = 4
+	ctaTimeout       = 7
+	ctaMark          = 8
+	ctaCountersOrig  = 9
+	ctaCountersReply = 10
+	ctaID            = 12
+	ctaZone          = 18
+
+	ctaTupleIP    = 1
+	ctaTupleProto = 2
+
+	ctaIPv4Src = 1
+	ctaIPv4Dst = 2
+	ctaIPv6Src = 3
+	ctaIPv6Dst = 4
+
+	ctaProtoNum     = 1
+	ctaProtoSrcPort = 2
+	ctaProtoDstPort = 3
+
+	ctaProtoinfoTCP      = 1
+	ctaProtoinfoTCPState = 1
+
+	ctaCountersPackets = 1
+	ctaCountersBytes   = 2
+)
+
+// Status bits (linux/netfilter/nf_conntrack_common.h).
+const (
+	ipsSeenReply = 1 << 1
+	ipsAssured   = 1 << 2
+	ipsSrcNAT    = 1 << 4
+	ipsDstNAT    = 1 << 5
+)
+
+var tcpStates = []string{"NONE", "SYN_SENT", "SYN_RECV", "ESTABLISHED", "FIN_WAIT",
+	"CLOSE_WAIT", "LAST_ACK", "TIME_WAIT", "CLOSE", "SYN_SENT2"}
+
+// List dumps the IPv4 and IPv6 conntrack tables over ctnetlink, which needs
+// CAP_NET_ADMIN. Where ctnetlink is unavailable it falls back to the legacy
+// /proc/net/nf_conntrack.
+func List() ([]Flow, error) {
+	flows, err := dump()
+	if err == nil {
+		return flows, nil
+	}
+	if flows, procErr := readProc("/proc/net/nf_conntrack"); procErr == nil {
+		return flows, nil
+	}
+	return nil, err
+}
+
+// AccountingEnabled reports whether the kernel keeps per-flow byte and
+// packet counters.
+func AccountingEnabled() bool {
+	b, err := os.ReadFile("/proc/sys/net/netfilter/nf_conntrack_acct")
+	return err == nil && strings.TrimSpace(string(b)) == "1"
+}
+
+func dump() ([]Flow, error) {
+	var flows []Flow
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		req := []byte{family, unix.NFNETLINK_V0, 0, 0}
+		msgs, err := netlink.Request(unix.NETLINK_NETFILTER, unix.NFNL_SUBSYS_CTNETLINK<<8|ctMsgGet, unix.NLM_F_DUMP, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Header.Type != unix.NFNL_SUBSYS_CTNETLINK<<8|ctMsgNew {
+				continue
+			}
+			if f, ok := parseFlow(m.Data, family); ok {
+				flows = append(flows, f)
+			}
+		}
+	}
+	return flows, nil
+}
+
+// parseFlow reads a struct nfgenmsg and its attributes. Unlike rtnetlink,
+// ctnetlink sends integers in network byte order.
+func parseFlow(b []byte, family uint8) (Flow, bool) {
+	if len(b) < sizeofNfgenmsg {
+		return Flow{}, false
+	}
+	if b[0] != unix.AF_UNSPEC {
+		family = b[0]
+	}
+	f := Flow{Family: "IPv4"}
+	if family == unix.AF_INET6 {
+		f.Family = "IPv6"
+	}
+	var proto uint8
+	for _, attr := range netlink.ParseAttrs(b[sizeofNfgenmsg:]) {
+		switch attr.Type {
+		case ctaTupleOrig:
+			f.Orig, proto = parseTuple(attr.Value)
+		case ctaTupleReply:
+			f.Reply, _ = parseTuple(attr.Value)
+		case ctaStatus:
+			status := be32(attr.Value)
+			f.Replied = status&ipsSeenReply != 0
+			f.Assured = status&ipsAssured != 0
+			f.SNAT = status&ipsSrcNAT != 0
+			f.DNAT = status&ipsDstNAT != 0
+		case ctaProtoinfo:
+			f.State = parseProtoinfo(attr.Value)
+		case ctaTimeout:
+			f.Timeout = time.Duration(be32(attr.Value)) * time.Second
+		case ctaMark:
+			f.Mark = be32(attr.Value)
+		case ctaCountersOrig:
+			f.OrigCounters = parseCounters(attr.Value)
+			f.HasCounters = true
+		case ctaCountersReply:
+			f.ReplyCounters = parseCounters(attr.Value)
+			f.HasCounters = true
+		case ctaID:
+			f.ID = be32(attr.Value)
+		case ctaZone:
+			if len(attr.Value) >= 2 {
+				f.Zone = binary.BigEndian.Uint16(attr.Value)
+			}
+		}
+	}
+	f.Protocol = protocolName(proto)
+	// The status bits are missing for entries created over ctnetlink.
+	f.inferNAT()
+	return f, f.Orig.Src.IsValid()
+}
+
+func parseTuple(b []byte) (t Tuple, proto uint8) {
+	for _, attr := range netlink.ParseAttrs(b) {
+		switch attr.Type {
+		case ctaTupleIP:
+			for _, ip := range netlink.ParseAttrs(attr.Value) {
+				addr, _ := netip.AddrFromSlice(ip.Value)
+				switch ip.Type {
+				case ctaIPv4Src, ctaIPv6Src:
+					t.Src = addr
+				case ctaIPv4Dst, ctaIPv6Dst:
+					t.Dst = addr
+				}
+			}
+		case ctaTupleProto:
+			for _, p := range netlink.ParseAttrs(attr.Value) {
+				switch p.Type {
+				case ctaProtoNum:
+					if len(p.Value) > 0 {
+						proto = p.Value[0]
+					}
+				case ctaProtoSrcPort:
+					t.SrcPort = be16(p.Value)
+				case ctaProtoDstPort:
+					t.DstPort = be16(p.Value)
+				}
+			}
+		}
+	}
+	return t, proto
+}
+
+func parseProtoinfo(b []byte) string {
+	for _, attr := range netlink.ParseAttrs(b) {
+		if attr.Type != ctaProtoinfoTCP {
+			continue
+		}
+		for _, info := range netlink.ParseAttrs(attr.Value) {
+			if info.Type == ctaProtoinfoTCPState && len(info.Value) > 0 && int(info.Value[0]) < len(tcpStates) {
+				return tcpStates[info.Value[0]]
+			}
+		}
+	}
+	return ""
+}
+
+func parseCounters(b []byte) Counters {
+	var c Counters
+	for _, attr := range netlink.ParseAttrs(b) {
+		if len(attr.Value) < 8 {
+			continue
+		}
+		switch attr.Type {
+		case ctaCountersPackets:
+			c.Packets = binary.BigEndian.Uint64(attr.Value)
+		case ctaCountersBytes:
+			c.Bytes = binary.BigEndian.Uint64(attr.Value)
+		}
+	}
+	return c
+}
+
+func be16(b []byte) uint16 {
+	if len(b) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func be32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func protocolName(proto uint8) string {
+	switch proto {
+	case unix.IPPROTO_TCP:
+		return "tcp"
+	case unix.IPPROTO_UDP:
+		return "udp"
+	case unix.IPPROTO_ICMP:
+		return "icmp"
+	case unix.IPPROTO_ICMPV6:
+		return "icmpv6"
+	case unix.IPPROTO_SCTP:
+		return "sctp"
+	case unix.IPPROTO_DCCP:
+		return "dccp"
+	case unix.IPPROTO_UDPLITE:
+		return "udplite"
+	case unix.IPPROTO_GRE:
+		return "gre"
+	}
+	return strconv.Itoa(int(proto))
+}
+
+// readProc parses /proc/net/nf_conntrack, where each line holds the family,
+// protocol, timeout and, for TCP, the state, followed by key=value pairs.
+// The first src= starts the original tuple and the second the reply tuple.
+// The file has no NAT status bits.
+func readProc(path string) ([]Flow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var flows []Flow
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if flow, ok := parseProcLine(scanner.Text()); ok {
+			flows = append(flows, flow)
+		}
+	}
+	return flows, scanner.Err()
+}
+
+func parseProcLine(line string) (Flow, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return Flow{}, false
+	}
+	flow := Flow{Family: "IPv4", Protocol: fields[2], Replied: true}
+	if fields[0] == "ipv6" {
+		flow.Family = "IPv6"
+	}
+	if secs, err := strconv.Atoi(fields[4]); err == nil {
+		flow.Timeout = time.Duration(secs) * time.Second
+	}
+
+	tuples := 0
+	tuple, counters := &flow.Orig, &flow.OrigCounters
+	for _, field := range fields[5:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			switch field {
+			case "[ASSURED]":
+				flow.Assured = true
+			case "[UNREPLIED]":
+				flow.Replied = false
+			default:
+				if tuples == 0 && flow.Protocol == "tcp" {
+					flow.State = field
+				}
+			}
+			continue
+		}
+		switch key {
+		case "src":
+			tuples++
+			if tuples == 2 {
+				tuple, counters = &flow.Reply, &flow.ReplyCounters
+			}
+			tuple.Src, _ = netip.ParseAddr(value)
+		case "dst":
+			tuple.Dst, _ = netip.ParseAddr(value)
+		case "sport":
+			port, _ := strconv.ParseUint(value, 10, 16)
+			tuple.SrcPort = uint16(port)
+		case "dport":
+			port, _ := strconv.ParseUint(value, 10, 16)
+			tuple.DstPort = uint16(port)
+		case "packets":
+			counters.Packets, _ = strconv.ParseUint(value, 10, 64)
+			flow.HasCounters = true
+		case "bytes":
+			counters.Bytes, _ = strconv.ParseUint(value, 10, 64)
+		case "mark":
+			mark, _ := strconv.ParseUint(value, 10, 32)
+			flow.Mark = uint32(mark)
+		case "zone":
+			zone, _ := strconv.ParseUint(value, 10, 16)
+			flow.Zone = uint16(zone)
+		}
+	}
+	flow.inferNAT()
+	return flow, flow.Orig.Src.IsValid() && flow.Reply.Src.IsValid()
+}
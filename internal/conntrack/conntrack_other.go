@@ -0,0 +1,11 @@
+//go:build !linux
+
+package conntrack
+
+func List() ([]Flow, error) {
+	return nil, ErrUnsupported
+}
+
+func AccountingEnabled() bool {
+	return false
+}
@@ -0,0 +1,220 @@
+package filter
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+// Query is a parsed filter expression. Whitespace-separated terms must all
+// match. A term is either free text, matched as a substring of the process,
+// status, family and addresses, or key:value with comma-separated
+// alternatives. A leading '!' negates a term.
+//
+//	nginx state:established,time_wait !rport:443 raddr:10.0.0.0/8
+type Query struct {
+	raw   string
+	terms []term
+}
+
+type term struct {
+	key    string
+	values []string
+	negate bool
+	ifaces map[string][]net.IP // Addresses of each iface: value, read at parse time
+}
+
+var keyAliases = map[string]string{
+	"proc":    "proc",
+	"process": "proc",
+	"pid":     "pid",
+	"state":   "state",
+	"status":  "state",
+	"family":  "family",
+	"fam":     "family",
+	"type":    "type",
+	"proto":   "type",
+	"laddr":   "laddr",
+	"raddr":   "raddr",
+	"lport":   "lport",
+	"rport":   "rport",
+	"port":    "port",
+	"host":    "host",
+	"ioc":     "ioc",
+	"iface":   "iface",
+	"if":      "iface",
+}
+
+func Parse(s string) Query {
+	q := Query{raw: s}
+	for _, field := range strings.Fields(s) {
+		t := term{}
+		if strings.HasPrefix(field, "!") && len(field) > 1 {
+			t.negate = true
+			field = field[1:]
+		}
+
+		if k, v, ok := strings.Cut(field, ":"); ok {
+			if key, known := keyAliases[strings.ToLower(k)]; known && v != "" {
+				t.key = key
+				for _, alt := range strings.Split(v, ",") {
+					if alt != "" {
+						t.values = append(t.values, strings.ToLower(alt))
+					}
+				}
+				if key == "iface" {
+					t.ifaces = interfaceIPs(t.values)
+				}
+				q.terms = append(q.terms, t)
+				continue
+			}
+		}
+
+		t.values = []string{strings.ToLower(field)}
+		q.terms = append(q.terms, t)
+	}
+	return q
+}
+
+func (q Query) String() string {
+	return q.raw
+}
+
+func (q Query) Empty() bool {
+	return len(q.terms) == 0
+}
+
+func (q Query) Match(c models.Connection) bool {
+	for _, t := range q.terms {
+		if t.match(c) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (q Query) Apply(conns []models.Connection) []models.Connection {
+	if q.Empty() {
+		return conns
+	}
+	var result []models.Connection
+	for _, c := range conns {
+		if q.Match(c) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (t term) match(c models.Connection) bool {
+	for _, v := range t.values {
+		if t.matchValue(c, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t term) matchValue(c models.Connection, v string) bool {
+	switch t.key {
+	case "":
+		searchable := strings.ToLower(
+			c.ProcessName + " " +
+				strconv.Itoa(int(c.Pid)) + " " +
+				c.Status + " " +
+				c.Family + " " +
+				c.Laddr + " " +
+				c.Raddr,
+		)
+		return strings.Contains(searchable, v)
+	case "proc":
+		return strings.Contains(strings.ToLower(c.ProcessName), v)
+	case "pid":
+		return strconv.Itoa(int(c.Pid)) == v
+	case "state":
+		return strings.EqualFold(c.Status, v)
+	case "family":
+		return strings.EqualFold(c.Family, v) || strings.EqualFold(c.Family, "ipv"+v)
+	case "type":
+		return strings.EqualFold(c.Type, v)
+	case "laddr":
+		return matchAddr(c.Laddr, v)
+	case "raddr":
+		return matchAddr(c.Raddr, v)
+	case "lport":
+		return matchPort(c.LocalPort(), v)
+	case "rport":
+		return matchPort(c.RemotePort(), v)
+	case "port":
+		return matchPort(c.LocalPort(), v) || matchPort(c.RemotePort(), v)
+	case "host":
+		return strings.Contains(strings.ToLower(c.Host), v)
+	case "iface":
+		ip := net.ParseIP(c.LocalIP())
+		return ip != nil && slices.ContainsFunc(t.ifaces[v], ip.Equal)
+	case "ioc":
+		switch v {
+		case "true", "yes", "1":
+			return c.IOC != ""
+		case "false", "no", "0":
+			return c.IOC == ""
+		}
+		return strings.Contains(strings.ToLower(c.IOC), v)
+	}
+	return false
+}
+
+// interfaceIPs looks up the addresses of the named interfaces of this
+// machine, ignoring case. Unknown names get none, so they match nothing.
+func interfaceIPs(names []string) map[string][]net.IP {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	ips := make(map[string][]net.IP)
+	for _, iface := range ifaces {
+		name := strings.ToLower(iface.Name)
+		if !slices.Contains(names, name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok {
+				ips[name] = append(ips[name], ipNet.IP)
+			}
+		}
+	}
+	return ips
+}
+
+// matchAddr treats v as a CIDR or exact IP when it parses as one, and as a
+// substring of "ip:port" otherwise.
+func matchAddr(addr, v string) bool {
+	ipStr, _ := models.SplitAddr(addr)
+	if _, network, err := net.ParseCIDR(v); err == nil {
+		ip := net.ParseIP(ipStr)
+		return ip != nil && network.Contains(ip)
+	}
+	if want := net.ParseIP(v); want != nil {
+		ip := net.ParseIP(ipStr)
+		return ip != nil && ip.Equal(want)
+	}
+	return strings.Contains(strings.ToLower(addr), v)
+}
+
+// matchPort accepts a single port or an inclusive "low-high" range.
+func matchPort(port uint32, v string) bool {
+	if lo, hi, ok := strings.Cut(v, "-"); ok {
+		l, err1 := strconv.ParseUint(lo, 10, 32)
+		h, err2 := strconv.ParseUint(hi, 10, 32)
+		return err1 == nil && err2 == nil && uint64(port) >= l && uint64(port) <= h
+	}
+	p, err := strconv.ParseUint(v, 10, 32)
+	return err == nil && uint64(port) == p
+}
@@ -0,0 +1,80 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+func TestMatch(t *testing.T) {
+	nginx := models.Connection{Family: "IPv4", Type: "TCP", Pid: 812, ProcessName: "nginx",
+		Laddr: "192.168.1.5:8080", Raddr: "10.1.2.3:51000", Status: "ESTABLISHED", Host: "web1"}
+	dns := models.Connection{Family: "IPv6", Type: "UDP", Pid: 90, ProcessName: "systemd-resolved",
+		Laddr: "::1:53", Raddr: "2001:db8::7:53", Status: "NONE", IOC: "bad.example"}
+
+	tests := []struct {
+		query    string
+		nginx    bool
+		resolved bool
+	}{
+		{"", true, true},
+		{"NGINX", true, false},
+		{"812", true, false},
+		{"!nginx", false, true},
+		{"nginx udp", false, false}, // Free text does not search the type
+		{"proc:nginx,resolved", true, true},
+		{"pid:90", false, true},
+		{"pid:9", false, false},
+		{"state:established,time_wait", true, false},
+		{"status:none", false, true},
+		{"family:4", true, false},
+		{"fam:ipv6", false, true},
+		{"proto:udp", false, true},
+		{"raddr:10.0.0.0/8", true, false},
+		{"!raddr:10.0.0.0/8", false, true},
+		{"raddr:2001:db8::/32", false, true},
+		{"raddr:10.1.2.3", true, false},
+		{"raddr:10.1.2", true, false}, // Not an IP, so a substring
+		{"laddr:10.1.2.3", false, false},
+		{"lport:8000-8100", true, false},
+		{"rport:53", false, true},
+		{"rport:50000-52000,53", true, true},
+		{"port:51000", true, false},
+		{"port:1-100", false, true},
+		{"port:x-100", false, false},
+		{"host:WEB", true, false},
+		{"ioc:true", false, true},
+		{"ioc:no", true, false},
+		{"ioc:bad", false, true},
+		{"nginx state:established !rport:443", true, false},
+		{"nginx !state:established", false, false},
+		{"color:red", false, false}, // Unknown key: free text "color:red"
+		{"proc:", false, false},     // Empty value: free text "proc:"
+		{"!", false, false},         // A lone '!' is free text
+	}
+	for _, tt := range tests {
+		q := Parse(tt.query)
+		if got := q.Match(nginx); got != tt.nginx {
+			t.Errorf("Parse(%q).Match(nginx) = %v, want %v", tt.query, got, tt.nginx)
+		}
+		if got := q.Match(dns); got != tt.resolved {
+			t.Errorf("Parse(%q).Match(resolved) = %v, want %v", tt.query, got, tt.resolved)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	conns := []models.Connection{{Pid: 1, Status: "LISTEN"}, {Pid: 2, Status: "ESTABLISHED"}}
+
+	q := Parse("  ")
+	if !q.Empty() || len(q.Apply(conns)) != 2 {
+		t.Errorf("blank query: Empty = %v, Apply kept %d", q.Empty(), len(q.Apply(conns)))
+	}
+	q = Parse("state:listen")
+	if got := q.Apply(conns); len(got) != 1 || got[0].Pid != 1 {
+		t.Errorf("Apply = %+v", got)
+	}
+	if q.String() != "state:listen" {
+		t.Errorf("String = %q", q.String())
+	}
+}
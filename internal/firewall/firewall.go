@@ -0,0 +1,232 @@
+// Package firewall blocks remote addresses with nftables, or iptables when
+// nft is not installed. Every rule BatStat creates carries a comment that
+// records the block, so the firewall itself is the source of truth and
+// blocks survive a restart of BatStat.
+package firewall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnavailable = errors.New("neither nft nor iptables is available")
+
+// Runner executes a command and returns its combined output. Tests replace
+// it to record commands instead of touching the firewall.
+type Runner interface {
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+type ExecRunner struct{}
+
+func (ExecRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return out, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return out, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+// Block drops traffic to and from IP, optionally only for one port.
+type Block struct {
+	IP      string
+	Proto   string // "tcp" or "udp" when Port is set
+	Port    uint32 // 0 blocks every port
+	Created time.Time
+	Expires time.Time // Zero never expires
+}
+
+func (b Block) Target() string {
+	if b.Port == 0 {
+		return b.IP
+	}
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(b.IP, strconv.Itoa(int(b.Port))), b.Proto)
+}
+
+func (b Block) Expired(now time.Time) bool {
+	return !b.Expires.IsZero() && !now.Before(b.Expires)
+}
+
+func (b Block) ipv6() bool {
+	ip := net.ParseIP(b.IP)
+	return ip != nil && ip.To4() == nil
+}
+
+const commentPrefix = "batstat"
+
+// comment encodes a block as "batstat ip=... proto=... port=... created=...
+// expires=..."; it stays well under the 128 byte limit of nft comments.
+func (b Block) comment() string {
+	var expires int64
+	if !b.Expires.IsZero() {
+		expires = b.Expires.Unix()
+	}
+	proto := b.Proto
+	if proto == "" {
+		proto = "any"
+	}
+	return fmt.Sprintf("%s ip=%s proto=%s port=%d created=%d expires=%d",
+		commentPrefix, b.IP, proto, b.Port, b.Created.Unix(), expires)
+}
+
+func parseComment(s string) (Block, bool) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || fields[0] != commentPrefix {
+		return Block{}, false
+	}
+	var b Block
+	for _, f := range fields[1:] {
+		k, v, _ := strings.Cut(f, "=")
+		switch k {
+		case "ip":
+			b.IP = v
+		case "proto":
+			if v != "any" {
+				b.Proto = v
+			}
+		case "port":
+			port, _ := strconv.ParseUint(v, 10, 16)
+			b.Port = uint32(port)
+		case "created":
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				b.Created = time.Unix(n, 0)
+			}
+		case "expires":
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+				b.Expires = time.Unix(n, 0)
+			}
+		}
+	}
+	return b, net.ParseIP(b.IP) != nil
+}
+
+type backend interface {
+	name() string
+	detect(ctx context.Context) bool
+	add(ctx context.Context, b Block) error
+	remove(ctx context.Context, b Block) error
+	list(ctx context.Context) ([]Block, error)
+}
+
+type Firewall struct {
+	mu       sync.Mutex
+	backends []backend
+	active   backend
+}
+
+// New returns a Firewall that runs its commands through r.
+func New(r Runner) *Firewall {
+	return &Firewall{backends: []backend{&nftables{run: r}, &iptables{run: r}}}
+}
+
+func (f *Firewall) backend(ctx context.Context) (backend, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active != nil {
+		return f.active, nil
+	}
+	for _, b := range f.backends {
+		if b.detect(ctx) {
+			f.active = b
+			return b, nil
+		}
+	}
+	return nil, ErrUnavailable
+}
+
+// Backend names the tool in use, "nftables" or "iptables".
+func (f *Firewall) Backend(ctx context.Context) (string, error) {
+	b, err := f.backend(ctx)
+	if err != nil {
+		return "", err
+	}
+	return b.name(), nil
+}
+
+// Block installs b. Blocking an address that is already blocked with the
+// same port replaces the old rule, which is how an expiry gets changed.
+func (f *Firewall) Block(ctx context.Context, b Block) error {
+	if net.ParseIP(b.IP) == nil {
+		return fmt.Errorf("invalid IP address %q", b.IP)
+	}
+	if b.Port != 0 && b.Proto != "tcp" && b.Proto != "udp" {
+		return fmt.Errorf("blocking a port needs tcp or udp, not %q", b.Proto)
+	}
+	if b.Port == 0 {
+		b.Proto = ""
+	}
+	if b.Created.IsZero() {
+		b.Created = time.Now()
+	}
+
+	be, err := f.backend(ctx)
+	if err != nil {
+		return err
+	}
+	existing, err := be.list(ctx)
+	if err != nil {
+		return err
+	}
+	for _, old := range existing {
+		if old.IP == b.IP && old.Port == b.Port && old.Proto == b.Proto {
+			if err := be.remove(ctx, old); err != nil {
+				return err
+			}
+		}
+	}
+	return be.add(ctx, b)
+}
+
+func (f *Firewall) Unblock(ctx context.Context, b Block) error {
+	be, err := f.backend(ctx)
+	if err != nil {
+		return err
+	}
+	return be.remove(ctx, b)
+}
+
+// List returns the blocks BatStat created, oldest first.
+func (f *Firewall) List(ctx context.Context) ([]Block, error) {
+	be, err := f.backend(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := be.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Created.Before(blocks[j].Created) })
+	return blocks, nil
+}
+
+// Expire removes every block whose expiry has passed and returns them.
+func (f *Firewall) Expire(ctx context.Context, now time.Time) ([]Block, error) {
+	blocks, err := f.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var expired []Block
+	var errs []error
+	for _, b := range blocks {
+		if !b.Expired(now) {
+			continue
+		}
+		if err := f.Unblock(ctx, b); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		expired = append(expired, b)
+	}
+	return expired, errors.Join(errs...)
+}
@@ -0,0 +1,306 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRunner records every command as one space-separated line and answers
+// with respond, which sees the same line.
+type fakeRunner struct {
+	calls   []string
+	respond func(cmd string) (string, error)
+}
+
+func (f *fakeRunner) Run(_ context.Context, name string, args ...string) ([]byte, error) {
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, cmd)
+	if f.respond == nil {
+		return nil, nil
+	}
+	out, err := f.respond(cmd)
+	return []byte(out), err
+}
+
+// since drops the calls before the first one starting with prefix.
+func (f *fakeRunner) since(prefix string) []string {
+	for i, c := range f.calls {
+		if strings.HasPrefix(c, prefix) {
+			return f.calls[i:]
+		}
+	}
+	return nil
+}
+
+var (
+	created   = time.Unix(1700000000, 0)
+	errNoNft  = errors.New("nft: executable file not found in $PATH")
+	errNoRule = errors.New("iptables: exit status 1: No chain/target/match by that name.")
+)
+
+// nftRunner is an nft host without a batstat table yet.
+func nftRunner() *fakeRunner {
+	return &fakeRunner{respond: func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "nft -a list table") {
+			return "", errors.New("nft: exit status 1: Error: No such file or directory")
+		}
+		return "", nil
+	}}
+}
+
+// iptRunner is a host without nft and without a BATSTAT chain yet.
+func iptRunner() *fakeRunner {
+	return &fakeRunner{respond: func(cmd string) (string, error) {
+		switch {
+		case strings.HasPrefix(cmd, "nft "):
+			return "", errNoNft
+		case strings.Contains(cmd, " -S BATSTAT"), strings.Contains(cmd, " -C "):
+			return "", errNoRule
+		}
+		return "", nil
+	}}
+}
+
+func TestNftBlock(t *testing.T) {
+	tests := []struct {
+		block Block
+		rules []string
+	}{
+		{
+			Block{IP: "192.0.2.1", Created: created},
+			[]string{
+				`nft add rule inet batstat input ip saddr 192.0.2.1 counter drop comment "batstat ip=192.0.2.1 proto=any port=0 created=1700000000 expires=0"`,
+				`nft add rule inet batstat output ip daddr 192.0.2.1 counter drop comment "batstat ip=192.0.2.1 proto=any port=0 created=1700000000 expires=0"`,
+			},
+		},
+		{
+			Block{IP: "2001:db8::7", Proto: "tcp", Port: 443, Created: created, Expires: created.Add(time.Hour)},
+			[]string{
+				`nft add rule inet batstat input ip6 saddr 2001:db8::7 tcp sport 443 counter drop comment "batstat ip=2001:db8::7 proto=tcp port=443 created=1700000000 expires=1700003600"`,
+				`nft add rule inet batstat output ip6 daddr 2001:db8::7 tcp dport 443 counter drop comment "batstat ip=2001:db8::7 proto=tcp port=443 created=1700000000 expires=1700003600"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		r := nftRunner()
+		if err := New(r).Block(context.Background(), tt.block); err != nil {
+			t.Fatalf("Block(%s): %v", tt.block.Target(), err)
+		}
+		want := append([]string{
+			"nft add table inet batstat",
+			"nft add chain inet batstat input { type filter hook input priority -10 ; policy accept ; }",
+			"nft add chain inet batstat output { type filter hook output priority -10 ; policy accept ; }",
+		}, tt.rules...)
+		if got := r.since("nft add"); !slices.Equal(got, want) {
+			t.Errorf("Block(%s) ran\n%s\nwant\n%s", tt.block.Target(), strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+const nftListing = `table inet batstat { # handle 12
+	chain input { # handle 1
+		type filter hook input priority -10; policy accept;
+		ip saddr 198.51.100.9 counter packets 4 bytes 240 drop comment "batstat ip=198.51.100.9 proto=any port=0 created=1700000500 expires=0" # handle 3
+		ip6 saddr 2001:db8::7 tcp sport 443 counter packets 0 bytes 0 drop comment "batstat ip=2001:db8::7 proto=tcp port=443 created=1700000000 expires=1700003600" # handle 5
+		ip saddr 203.0.113.1 counter packets 0 bytes 0 drop comment "added by hand" # handle 7
+	}
+	chain output { # handle 2
+		type filter hook output priority -10; policy accept;
+		ip daddr 198.51.100.9 counter packets 1 bytes 60 drop comment "batstat ip=198.51.100.9 proto=any port=0 created=1700000500 expires=0" # handle 4
+		ip6 daddr 2001:db8::7 tcp dport 443 counter packets 0 bytes 0 drop comment "batstat ip=2001:db8::7 proto=tcp port=443 created=1700000000 expires=1700003600" # handle 6
+	}
+}
+`
+
+func TestNftListAndUnblock(t *testing.T) {
+	r := &fakeRunner{respond: func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "nft -a list table inet batstat") {
+			return nftListing, nil
+		}
+		return "", nil
+	}}
+	fw := New(r)
+	ctx := context.Background()
+
+	blocks, err := fw.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Block{
+		{IP: "2001:db8::7", Proto: "tcp", Port: 443, Created: created, Expires: created.Add(time.Hour)},
+		{IP: "198.51.100.9", Created: created.Add(500 * time.Second)},
+	}
+	if !slices.EqualFunc(blocks, want, equalBlocks) {
+		t.Errorf("List = %+v, want %+v", blocks, want)
+	}
+
+	if err := fw.Unblock(ctx, Block{IP: "2001:db8::7", Proto: "tcp", Port: 443}); err != nil {
+		t.Fatal(err)
+	}
+	wantCalls := []string{
+		"nft delete rule inet batstat input handle 5",
+		"nft delete rule inet batstat output handle 6",
+	}
+	if got := r.since("nft delete"); !slices.Equal(got, wantCalls) {
+		t.Errorf("Unblock ran %q, want %q", got, wantCalls)
+	}
+
+	// The same address without the port is a different block.
+	if err := fw.Unblock(ctx, Block{IP: "2001:db8::7"}); err == nil {
+		t.Error("Unblock of a block that does not exist: no error")
+	}
+}
+
+func TestBlockReplacesExisting(t *testing.T) {
+	r := &fakeRunner{respond: func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "nft -a list table inet batstat") {
+			return nftListing, nil
+		}
+		return "", nil
+	}}
+	err := New(r).Block(context.Background(), Block{IP: "198.51.100.9", Created: created, Expires: created.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletes := r.since("nft delete")
+	if len(deletes) < 2 || deletes[0] != "nft delete rule inet batstat input handle 3" ||
+		deletes[1] != "nft delete rule inet batstat output handle 4" {
+		t.Errorf("old rules not deleted first: %q", r.calls)
+	}
+	if last := r.calls[len(r.calls)-1]; !strings.Contains(last, "expires=1700000060") {
+		t.Errorf("last call %q does not add the new expiry", last)
+	}
+}
+
+func TestFallbackToIptables(t *testing.T) {
+	r := iptRunner()
+	fw := New(r)
+	name, err := fw.Backend(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "iptables" {
+		t.Errorf("Backend = %q, want iptables", name)
+	}
+	if !slices.Equal(r.calls, []string{"nft --version", "iptables --version"}) {
+		t.Errorf("detection ran %q", r.calls)
+	}
+
+	r = &fakeRunner{respond: func(string) (string, error) { return "", errNoNft }}
+	if _, err := New(r).Backend(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Backend without either tool: err = %v", err)
+	}
+}
+
+func TestIptablesBlock(t *testing.T) {
+	tests := []struct {
+		block Block
+		want  []string
+	}{
+		{
+			Block{IP: "192.0.2.1", Created: created},
+			[]string{
+				"iptables -w -S BATSTAT",
+				"iptables -w -N BATSTAT",
+				"iptables -w -C INPUT -j BATSTAT",
+				"iptables -w -I INPUT 1 -j BATSTAT",
+				"iptables -w -C OUTPUT -j BATSTAT",
+				"iptables -w -I OUTPUT 1 -j BATSTAT",
+				"iptables -w -A BATSTAT -s 192.0.2.1 -m comment --comment batstat ip=192.0.2.1 proto=any port=0 created=1700000000 expires=0 -j DROP",
+				"iptables -w -A BATSTAT -d 192.0.2.1 -m comment --comment batstat ip=192.0.2.1 proto=any port=0 created=1700000000 expires=0 -j DROP",
+			},
+		},
+		{
+			Block{IP: "2001:db8::7", Proto: "udp", Port: 53, Created: created},
+			[]string{
+				"ip6tables -w -S BATSTAT",
+				"ip6tables -w -N BATSTAT",
+				"ip6tables -w -C INPUT -j BATSTAT",
+				"ip6tables -w -I INPUT 1 -j BATSTAT",
+				"ip6tables -w -C OUTPUT -j BATSTAT",
+				"ip6tables -w -I OUTPUT 1 -j BATSTAT",
+				"ip6tables -w -A BATSTAT -s 2001:db8::7 -p udp --sport 53 -m comment --comment batstat ip=2001:db8::7 proto=udp port=53 created=1700000000 expires=0 -j DROP",
+				"ip6tables -w -A BATSTAT -d 2001:db8::7 -p udp --dport 53 -m comment --comment batstat ip=2001:db8::7 proto=udp port=53 created=1700000000 expires=0 -j DROP",
+			},
+		},
+	}
+	for _, tt := range tests {
+		r := iptRunner()
+		if err := New(r).Block(context.Background(), tt.block); err != nil {
+			t.Fatalf("Block(%s): %v", tt.block.Target(), err)
+		}
+		// Skip detection and the listing of existing blocks.
+		got := r.calls[len(r.calls)-len(tt.want):]
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Block(%s) ran\n%s\nwant\n%s", tt.block.Target(), strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestIptablesListAndUnblock(t *testing.T) {
+	listings := map[string]string{
+		"iptables": `-N BATSTAT
+-A BATSTAT -s 192.0.2.1/32 -m comment --comment "batstat ip=192.0.2.1 proto=any port=0 created=1700000000 expires=0" -j DROP
+-A BATSTAT -s 10.0.0.0/8 -j ACCEPT
+-A BATSTAT -d 192.0.2.1/32 -m comment --comment "batstat ip=192.0.2.1 proto=any port=0 created=1700000000 expires=0" -j DROP
+`,
+		"ip6tables": `-N BATSTAT
+-A BATSTAT -s 2001:db8::7/128 -p tcp -m tcp --sport 443 -m comment --comment "batstat ip=2001:db8::7 proto=tcp port=443 created=1700000100 expires=0" -j DROP
+-A BATSTAT -d 2001:db8::7/128 -p tcp -m tcp --dport 443 -m comment --comment "batstat ip=2001:db8::7 proto=tcp port=443 created=1700000100 expires=0" -j DROP
+`,
+	}
+	r := &fakeRunner{respond: func(cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "nft ") {
+			return "", errNoNft
+		}
+		if bin, ok := strings.CutSuffix(cmd, " -w -S BATSTAT"); ok {
+			return listings[bin], nil
+		}
+		return "", nil
+	}}
+	fw := New(r)
+	ctx := context.Background()
+
+	blocks, err := fw.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Block{
+		{IP: "192.0.2.1", Created: created},
+		{IP: "2001:db8::7", Proto: "tcp", Port: 443, Created: created.Add(100 * time.Second)},
+	}
+	if !slices.EqualFunc(blocks, want, equalBlocks) {
+		t.Errorf("List = %+v, want %+v", blocks, want)
+	}
+
+	if err := fw.Unblock(ctx, Block{IP: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	// Rule 2 is not BatStat's; the bottom rule goes first so 1 stays 1.
+	wantCalls := []string{"iptables -w -D BATSTAT 3", "iptables -w -D BATSTAT 1"}
+	if got := r.since("iptables -w -D"); !slices.Equal(got, wantCalls) {
+		t.Errorf("Unblock ran %q, want %q", got, wantCalls)
+	}
+}
+
+func TestParseComment(t *testing.T) {
+	b := Block{IP: "2001:db8::7", Proto: "udp", Port: 53, Created: created, Expires: created.Add(time.Hour)}
+	got, ok := parseComment(b.comment())
+	if !ok || !equalBlocks(got, b) {
+		t.Errorf("parseComment(%q) = %+v, %v", b.comment(), got, ok)
+	}
+	for _, s := range []string{"", "added by hand", "batstat ip=not-an-ip"} {
+		if _, ok := parseComment(s); ok {
+			t.Errorf("parseComment(%q) accepted", s)
+		}
+	}
+}
+
+func equalBlocks(a, b Block) bool {
+	return a.IP == b.IP && a.Proto == b.Proto && a.Port == b.Port &&
+		a.Created.Equal(b.Created) && a.Expires.Equal(b.Expires)
+}
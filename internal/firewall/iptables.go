@@ -0,0 +1,126 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const iptChain = "BATSTAT"
+
+// iptables puts BatStat's rules in a BATSTAT chain that INPUT and OUTPUT
+// jump to. IPv6 blocks go through ip6tables.
+type iptables struct {
+	run Runner
+}
+
+func (t *iptables) name() string { return "iptables" }
+
+func (t *iptables) detect(ctx context.Context) bool {
+	_, err := t.run.Run(ctx, "iptables", "--version")
+	return err == nil
+}
+
+func binary(b Block) string {
+	if b.ipv6() {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+func (t *iptables) ensure(ctx context.Context, bin string) error {
+	if _, err := t.run.Run(ctx, bin, "-w", "-S", iptChain); err != nil {
+		if _, err := t.run.Run(ctx, bin, "-w", "-N", iptChain); err != nil {
+			return err
+		}
+	}
+	for _, hook := range []string{"INPUT", "OUTPUT"} {
+		if _, err := t.run.Run(ctx, bin, "-w", "-C", hook, "-j", iptChain); err == nil {
+			continue
+		}
+		if _, err := t.run.Run(ctx, bin, "-w", "-I", hook, "1", "-j", iptChain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *iptables) add(ctx context.Context, b Block) error {
+	bin := binary(b)
+	if err := t.ensure(ctx, bin); err != nil {
+		return err
+	}
+	for _, dir := range []string{"s", "d"} {
+		args := []string{"-w", "-A", iptChain, "-" + dir, b.IP}
+		if b.Port != 0 {
+			args = append(args, "-p", b.Proto, "--"+dir+"port", strconv.Itoa(int(b.Port)))
+		}
+		args = append(args, "-m", "comment", "--comment", b.comment(), "-j", "DROP")
+		if _, err := t.run.Run(ctx, bin, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type iptRule struct {
+	bin   string
+	num   int // Position in the chain, starting at 1
+	block Block
+}
+
+var iptComment = regexp.MustCompile(`--comment "?(` + commentPrefix + ` [^"]*)"?`)
+
+// rules lists BatStat rules from both binaries. A missing chain or a missing
+// ip6tables just means there is nothing to list.
+func (t *iptables) rules(ctx context.Context) []iptRule {
+	var rules []iptRule
+	for _, bin := range []string{"iptables", "ip6tables"} {
+		out, err := t.run.Run(ctx, bin, "-w", "-S", iptChain)
+		if err != nil {
+			continue
+		}
+		num := 0
+		for _, line := range strings.Split(string(out), "\n") {
+			if !strings.HasPrefix(line, "-A ") {
+				continue
+			}
+			num++
+			m := iptComment.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			if b, ok := parseComment(m[1]); ok {
+				rules = append(rules, iptRule{bin: bin, num: num, block: b})
+			}
+		}
+	}
+	return rules
+}
+
+func (t *iptables) list(ctx context.Context) ([]Block, error) {
+	return uniqueBlocks(t.rules(ctx), func(r iptRule) Block { return r.block }), nil
+}
+
+func (t *iptables) remove(ctx context.Context, b Block) error {
+	var matches []iptRule
+	for _, r := range t.rules(ctx) {
+		if sameTarget(r.block, b) {
+			matches = append(matches, r)
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("%s is not blocked", b.Target())
+	}
+	// Delete from the bottom so the remaining rule numbers stay valid.
+	sort.Slice(matches, func(i, j int) bool { return matches[i].num > matches[j].num })
+	for _, r := range matches {
+		if _, err := t.run.Run(ctx, r.bin, "-w", "-D", iptChain, strconv.Itoa(r.num)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
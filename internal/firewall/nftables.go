@@ -0,0 +1,163 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const nftTable = "batstat"
+
+// nftables keeps its rules in a dedicated "inet batstat" table with one
+// input and one output chain, so they never mix with the host's own rules.
+type nftables struct {
+	run Runner
+}
+
+func (n *nftables) name() string { return "nftables" }
+
+func (n *nftables) detect(ctx context.Context) bool {
+	_, err := n.run.Run(ctx, "nft", "--version")
+	return err == nil
+}
+
+func (n *nftables) nft(ctx context.Context, args ...string) (string, error) {
+	out, err := n.run.Run(ctx, "nft", args...)
+	return string(out), err
+}
+
+func (n *nftables) ensure(ctx context.Context) error {
+	if _, err := n.nft(ctx, "add", "table", "inet", nftTable); err != nil {
+		return err
+	}
+	for _, hook := range []string{"input", "output"} {
+		_, err := n.nft(ctx, "add", "chain", "inet", nftTable, hook,
+			"{", "type", "filter", "hook", hook, "priority", "-10", ";", "policy", "accept", ";", "}")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match builds the selector for one direction, e.g. "ip saddr 192.0.2.1 tcp
+// sport 443" for the input chain.
+func nftMatch(b Block, chain string) []string {
+	family, addr, port := "ip", "saddr", "sport"
+	if b.ipv6() {
+		family = "ip6"
+	}
+	if chain == "output" {
+		addr, port = "daddr", "dport"
+	}
+	m := []string{family, addr, b.IP}
+	if b.Port != 0 {
+		m = append(m, b.Proto, port, strconv.Itoa(int(b.Port)))
+	}
+	return m
+}
+
+func (n *nftables) add(ctx context.Context, b Block) error {
+	if err := n.ensure(ctx); err != nil {
+		return err
+	}
+	for _, chain := range []string{"input", "output"} {
+		args := append([]string{"add", "rule", "inet", nftTable, chain}, nftMatch(b, chain)...)
+		args = append(args, "counter", "drop", "comment", strconv.Quote(b.comment()))
+		if _, err := n.nft(ctx, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type nftRule struct {
+	chain  string
+	handle int
+	block  Block
+}
+
+var (
+	nftChainLine = regexp.MustCompile(`^\s*chain (\S+) \{`)
+	nftRuleLine  = regexp.MustCompile(`comment "([^"]*)".*# handle (\d+)`)
+)
+
+func (n *nftables) rules(ctx context.Context) ([]nftRule, error) {
+	out, err := n.nft(ctx, "-a", "list", "table", "inet", nftTable)
+	if err != nil {
+		if strings.Contains(err.Error(), "No such file or directory") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rules []nftRule
+	chain := ""
+	for _, line := range strings.Split(out, "\n") {
+		if m := nftChainLine.FindStringSubmatch(line); m != nil {
+			chain = m[1]
+			continue
+		}
+		m := nftRuleLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		b, ok := parseComment(m[1])
+		if !ok {
+			continue
+		}
+		handle, _ := strconv.Atoi(m[2])
+		rules = append(rules, nftRule{chain: chain, handle: handle, block: b})
+	}
+	return rules, nil
+}
+
+func (n *nftables) list(ctx context.Context) ([]Block, error) {
+	rules, err := n.rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return uniqueBlocks(rules, func(r nftRule) Block { return r.block }), nil
+}
+
+func (n *nftables) remove(ctx context.Context, b Block) error {
+	rules, err := n.rules(ctx)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, r := range rules {
+		if !sameTarget(r.block, b) {
+			continue
+		}
+		found = true
+		if _, err := n.nft(ctx, "delete", "rule", "inet", nftTable, r.chain, "handle", strconv.Itoa(r.handle)); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s is not blocked", b.Target())
+	}
+	return nil
+}
+
+func sameTarget(a, b Block) bool {
+	return a.IP == b.IP && a.Port == b.Port && a.Proto == b.Proto
+}
+
+// uniqueBlocks collapses the input and output rules of each block.
+func uniqueBlocks[T any](rules []T, block func(T) Block) []Block {
+	var blocks []Block
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		b := block(r)
+		if seen[b.Target()] {
+			continue
+		}
+		seen[b.Target()] = true
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
@@ -0,0 +1,296 @@
+package history
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/probe"
+)
+
+// Change says how a compared row differs between the two runs.
+type Change int
+
+const (
+	Same Change = iota
+	Changed
+	Added   // Only in the newer run
+	Removed // Only in the older run
+	Worse   // Same row, higher latency or loss
+	Better
+)
+
+// maxDiffCells bounds the line diff, which is quadratic.
+const maxDiffCells = 4_000_000
+
+type Row struct {
+	Cells  []string
+	Change Change
+}
+
+// Comparison lays two runs of the same tool side by side.
+type Comparison struct {
+	Columns []string
+	Rows    []Row
+	Summary string
+}
+
+// Compare lines up an older run a against a newer run b: hop by hop for
+// traceroutes, statistic by statistic for pings, record by record for DNS
+// lookups and line by line for anything else.
+func Compare(a, b Run) Comparison {
+	switch {
+	case a.Trace != nil && b.Trace != nil:
+		return compareTraces(*a.Trace, *b.Trace)
+	case a.Ping != nil && b.Ping != nil:
+		return comparePings(*a.Ping, *b.Ping)
+	case a.DNS != nil && b.DNS != nil:
+		return compareDNS(a.DNS, b.DNS)
+	}
+	return compareLines(a.Output, b.Output)
+}
+
+func compareTraces(a, b probe.Trace) Comparison {
+	cmp := Comparison{Columns: []string{"Hop", "Before", "After", "Loss% before", "Loss% after", "Avg before", "Avg after", "Δ Avg"}}
+	changed := 0
+	for i := range max(len(a.Hops), len(b.Hops)) {
+		switch {
+		case i >= len(a.Hops):
+			h := b.Hops[i]
+			cmp.Rows = append(cmp.Rows, Row{Change: Added, Cells: []string{
+				strconv.Itoa(h.TTL), "", hopAddr(h), "", lossText(h), "", avgText(h), ""}})
+			changed++
+			continue
+		case i >= len(b.Hops):
+			h := a.Hops[i]
+			cmp.Rows = append(cmp.Rows, Row{Change: Removed, Cells: []string{
+				strconv.Itoa(h.TTL), hopAddr(h), "", lossText(h), "", avgText(h), "", ""}})
+			changed++
+			continue
+		}
+
+		ha, hb := a.Hops[i], b.Hops[i]
+		row := Row{Cells: []string{strconv.Itoa(hb.TTL), hopAddr(ha), hopAddr(hb), lossText(ha), lossText(hb), avgText(ha), avgText(hb), ""}}
+		if ha.Received > 0 && hb.Received > 0 {
+			row.Cells[7] = deltaText(hb.Avg - ha.Avg)
+		}
+		switch {
+		case ha.Addr != hb.Addr:
+			row.Change = Changed
+			changed++
+		default:
+			row.Change = judge(ha.Avg, hb.Avg, ha.Loss(), hb.Loss())
+		}
+		cmp.Rows = append(cmp.Rows, row)
+	}
+
+	cmp.Summary = fmt.Sprintf("%d of %d hops changed", changed, max(len(a.Hops), len(b.Hops)))
+	if a.Reached != b.Reached {
+		cmp.Summary += fmt.Sprintf("; target reached before: %t, after: %t", a.Reached, b.Reached)
+	}
+	return cmp
+}
+
+func comparePings(a, b probe.PingStats) Comparison {
+	cmp := Comparison{Columns: []string{"", "Before", "After", "Δ"}}
+	cmp.Rows = append(cmp.Rows,
+		Row{Cells: []string{"Sent", strconv.Itoa(a.Sent), strconv.Itoa(b.Sent), fmt.Sprintf("%+d", b.Sent-a.Sent)}},
+		Row{Cells: []string{"Received", strconv.Itoa(a.Received), strconv.Itoa(b.Received), fmt.Sprintf("%+d", b.Received-a.Received)}},
+		Row{Cells: []string{"Loss %", fmt.Sprintf("%.1f", a.Loss()), fmt.Sprintf("%.1f", b.Loss()), fmt.Sprintf("%+.1f", b.Loss()-a.Loss())},
+			Change: judge(0, 0, a.Loss(), b.Loss())},
+	)
+	if a.Received == 0 || b.Received == 0 {
+		cmp.Summary = "No round-trip times to compare"
+		return cmp
+	}
+	for _, stat := range []struct {
+		name string
+		a, b time.Duration
+	}{
+		{"Min", a.Min, b.Min}, {"Avg", a.Avg, b.Avg}, {"Max", a.Max, b.Max}, {"Mdev", a.Mdev, b.Mdev},
+	} {
+		cmp.Rows = append(cmp.Rows, Row{
+			Cells:  []string{stat.name + " ms", msText(stat.a), msText(stat.b), deltaText(stat.b - stat.a)},
+			Change: judge(stat.a, stat.b, 0, 0),
+		})
+	}
+	cmp.Summary = fmt.Sprintf("Average %s ms, loss %+.1f points", deltaText(b.Avg-a.Avg), b.Loss()-a.Loss())
+	return cmp
+}
+
+// judge flags latency moves of at least 20% and 1 ms, and any change in loss.
+func judge(avgA, avgB time.Duration, lossA, lossB float64) Change {
+	switch {
+	case lossB > lossA:
+		return Worse
+	case lossB < lossA:
+		return Better
+	}
+	diff := avgB - avgA
+	if diff.Abs() < time.Millisecond || avgA == 0 || float64(diff.Abs())/float64(avgA) < 0.2 {
+		return Same
+	}
+	if diff > 0 {
+		return Worse
+	}
+	return Better
+}
+
+func compareDNS(a, b [][]string) Comparison {
+	cmp := Comparison{Columns: []string{"Name", "Type", "Value", "Before", "After"}}
+	// Rows are Name, Type, TTL, Value, Status; TTLs count down, so they
+	// are shown but not compared.
+	key := func(row []string) string { return row[0] + "\x00" + row[1] + "\x00" + row[3] }
+	describe := func(row []string) string {
+		if row[2] == "" || row[2] == "-" {
+			return row[4]
+		}
+		return strings.TrimSpace("TTL " + row[2] + " " + row[4])
+	}
+
+	before := make(map[string][]string)
+	for _, row := range a {
+		before[key(row)] = row
+	}
+	seen := make(map[string]bool)
+	changed := 0
+	for _, row := range b {
+		k := key(row)
+		seen[k] = true
+		old, ok := before[k]
+		switch {
+		case !ok:
+			cmp.Rows = append(cmp.Rows, Row{Change: Added, Cells: []string{row[0], row[1], row[3], "", describe(row)}})
+			changed++
+		case old[4] != row[4]:
+			cmp.Rows = append(cmp.Rows, Row{Change: Changed, Cells: []string{row[0], row[1], row[3], describe(old), describe(row)}})
+			changed++
+		default:
+			cmp.Rows = append(cmp.Rows, Row{Cells: []string{row[0], row[1], row[3], describe(old), describe(row)}})
+		}
+	}
+	for _, row := range a {
+		if !seen[key(row)] {
+			cmp.Rows = append(cmp.Rows, Row{Change: Removed, Cells: []string{row[0], row[1], row[3], describe(row), ""}})
+			changed++
+		}
+	}
+	cmp.Summary = fmt.Sprintf("%d of %d records changed", changed, len(cmp.Rows))
+	return cmp
+}
+
+// compareLines is a longest-common-subsequence diff of the raw output.
+func compareLines(a, b []string) Comparison {
+	cmp := Comparison{Columns: []string{"", "Output"}}
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			cmp.Rows = append(cmp.Rows, Row{Change: Removed, Cells: []string{"-", line}})
+		}
+		for _, line := range b {
+			cmp.Rows = append(cmp.Rows, Row{Change: Added, Cells: []string{"+", line}})
+		}
+		cmp.Summary = "Output too long to diff line by line"
+		return cmp
+	}
+
+	// lcs[i][j] is the common subsequence length of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	added, removed := 0, 0
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			cmp.Rows = append(cmp.Rows, Row{Cells: []string{" ", a[i]}})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			cmp.Rows = append(cmp.Rows, Row{Change: Removed, Cells: []string{"-", a[i]}})
+			removed++
+			i++
+		default:
+			cmp.Rows = append(cmp.Rows, Row{Change: Added, Cells: []string{"+", b[j]}})
+			added++
+			j++
+		}
+	}
+	cmp.Summary = fmt.Sprintf("%d lines added, %d removed", added, removed)
+	return cmp
+}
+
+func hopAddr(h probe.Hop) string {
+	if h.Addr == "" {
+		return "*"
+	}
+	return h.Addr
+}
+
+func lossText(h probe.Hop) string {
+	return fmt.Sprintf("%.0f", h.Loss())
+}
+
+func avgText(h probe.Hop) string {
+	if h.Received == 0 {
+		return ""
+	}
+	return msText(h.Avg)
+}
+
+func msText(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+}
+
+func deltaText(d time.Duration) string {
+	return fmt.Sprintf("%+.1f", float64(d)/float64(time.Millisecond))
+}
+
+// Summary is a one-line description of the run's result for lists.
+func (r Run) Summary() string {
+	switch {
+	case r.Trace != nil:
+		last := ""
+		if n := len(r.Trace.Hops); n > 0 {
+			last = hopAddr(r.Trace.Hops[n-1])
+		}
+		reached := "not reached"
+		if r.Trace.Reached {
+			reached = "reached"
+		}
+		return fmt.Sprintf("%d hops, %s, last %s", len(r.Trace.Hops), reached, last)
+	case r.Ping != nil && r.Ping.Received > 0:
+		return fmt.Sprintf("%d/%d, %.1f%% loss, avg %s ms", r.Ping.Received, r.Ping.Sent, r.Ping.Loss(), msText(r.Ping.Avg))
+	case r.Ping != nil:
+		return fmt.Sprintf("%d/%d, %.1f%% loss", r.Ping.Received, r.Ping.Sent, r.Ping.Loss())
+	case r.DNS != nil:
+		var names []string
+		for _, row := range r.DNS {
+			if row[1] == "PTR" {
+				names = append(names, row[3])
+			}
+		}
+		return strings.Join(names, ", ")
+	case r.Status != "":
+		return r.Status
+	}
+	// The last line of most tools sums the run up.
+	for _, line := range slices.Backward(r.Output) {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
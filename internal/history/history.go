@@ -0,0 +1,229 @@
+// Package history keeps past diagnostic runs per remote address so they can
+// be browsed and compared later.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/probe"
+)
+
+const (
+	// DefaultKeep is how many runs are kept per address.
+	DefaultKeep = 100
+	// MaxOutputLines caps the raw output stored with a run.
+	MaxOutputLines = 2000
+)
+
+// Run is one diagnostic run against a remote address. Output holds the raw
+// text; the built-ins also store their parsed result.
+type Run struct {
+	Time    time.Time         `json:"time"`
+	Target  string            `json:"target"`
+	Host    string            `json:"host,omitempty"` // Agent that ran it, empty for this machine
+	Tool    string            `json:"tool"`
+	Params  map[string]string `json:"params,omitempty"`
+	Elapsed time.Duration     `json:"elapsed"`
+	// Status is how the run ended, e.g. "exit status 1"; empty for success.
+	Status string           `json:"status,omitempty"`
+	Ping   *probe.PingStats `json:"ping,omitempty"`
+	Trace  *probe.Trace     `json:"trace,omitempty"`
+	DNS    [][]string       `json:"dns,omitempty"` // probe.DNSReport.Table rows
+	Output []string         `json:"output,omitempty"`
+}
+
+// Store appends runs to one NDJSON file per address and keeps the newest
+// runs of each.
+type Store struct {
+	dir  string
+	keep int
+
+	mu     sync.Mutex
+	counts map[string]int // Runs per address, filled on first use
+}
+
+// DefaultDir is batstat/history in the user's cache directory.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "batstat", "history")
+}
+
+// Open creates dir if needed. keep <= 0 means DefaultKeep.
+func Open(dir string, keep int) (*Store, error) {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, keep: keep, counts: make(map[string]int)}, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+func (s *Store) path(target string) string {
+	return filepath.Join(s.dir, unsafeChars.ReplaceAllString(target, "_")+".ndjson")
+}
+
+// Add stores r, dropping the oldest runs of its address beyond the limit.
+func (s *Store) Add(r Run) error {
+	if r.Target == "" {
+		return errors.New("run without a target")
+	}
+	if len(r.Output) > MaxOutputLines {
+		r.Output = r.Output[len(r.Output)-MaxOutputLines:]
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := s.count(r.Target)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path(r.Target), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	s.counts[r.Target] = n + 1
+
+	// Rewriting on every run would be wasteful; trim once a quarter over.
+	if n+1 > s.keep+s.keep/4 {
+		return s.trim(r.Target)
+	}
+	return nil
+}
+
+// Count returns the number of stored runs for target.
+func (s *Store) Count(target string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, _ := s.count(target)
+	return min(n, s.keep)
+}
+
+func (s *Store) count(target string) (int, error) {
+	if n, ok := s.counts[target]; ok {
+		return n, nil
+	}
+	// Counting lines is enough here and much cheaper than parsing them.
+	f, err := os.Open(s.path(target))
+	if errors.Is(err, fs.ErrNotExist) {
+		s.counts[target] = 0
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n := 0
+	buf := make([]byte, 32*1024)
+	for {
+		k, err := f.Read(buf)
+		n += bytes.Count(buf[:k], []byte{'\n'})
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	s.counts[target] = n
+	return n, nil
+}
+
+// Runs returns the stored runs for target, newest first.
+func (s *Store) Runs(target string) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs, err := s.read(target)
+	if err != nil {
+		return nil, err
+	}
+	s.counts[target] = len(runs)
+	if len(runs) > s.keep {
+		runs = runs[len(runs)-s.keep:]
+	}
+	slices.Reverse(runs)
+	return runs, nil
+}
+
+// read loads every run of target, oldest first. Lines that do not parse,
+// such as one cut short by a crash, are skipped.
+func (s *Store) read(target string) ([]Run, error) {
+	data, err := os.ReadFile(s.path(target))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var r Run
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			runs = append(runs, r)
+		}
+	}
+	return runs, scanner.Err()
+}
+
+func (s *Store) trim(target string) error {
+	runs, err := s.read(target)
+	if err != nil {
+		return err
+	}
+	if len(runs) > s.keep {
+		runs = runs[len(runs)-s.keep:]
+	}
+
+	var b bytes.Buffer
+	for _, r := range runs {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	path := s.path(target)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replacing %s: %w", path, err)
+	}
+	s.counts[target] = len(runs)
+	return nil
+}
@@ -0,0 +1,212 @@
+package intel
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	KindIP     = "ip"
+	KindCIDR   = "cidr"
+	KindDomain = "domain"
+)
+
+type Indicator struct {
+	Value       string
+	Kind        string
+	Source      string
+	Description string
+}
+
+func (i Indicator) String() string {
+	s := i.Value
+	if i.Description != "" {
+		s += " (" + i.Description + ")"
+	}
+	return s + " [" + i.Source + "]"
+}
+
+// LoadFile reads one indicator list. JSON files are treated as STIX-lite
+// (a STIX 2 bundle or a list of {"type", "value"} objects), .csv files as
+// indicator feeds, and anything else as plain text with one entry per line.
+func LoadFile(path string) ([]Indicator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	source := filepath.Base(path)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseSTIX(f, source)
+	case ".csv":
+		return parseCSV(f, source)
+	default:
+		return parseText(f, source)
+	}
+}
+
+func classify(value string) (string, string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", "", false
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.String(), KindIP, true
+	}
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network.String(), KindCIDR, true
+	}
+	host := strings.TrimSuffix(strings.ToLower(value), ".")
+	if validHostname(host) {
+		return host, KindDomain, true
+	}
+	return "", "", false
+}
+
+func validHostname(h string) bool {
+	if h == "" || len(h) > 253 || !strings.Contains(h, ".") {
+		return false
+	}
+	for _, r := range h {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_') {
+			return false
+		}
+	}
+	// No top-level domain is all digits, so this is a mistyped IP address
+	// such as 300.1.1.1.
+	tld := h[strings.LastIndex(h, ".")+1:]
+	return strings.Trim(tld, "0123456789") != ""
+}
+
+func parseText(r io.Reader, source string) ([]Indicator, error) {
+	var result []Indicator
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var description string
+		if i := strings.Index(line, "#"); i >= 0 {
+			description = strings.TrimSpace(line[i+1:])
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if value, kind, ok := classify(fields[0]); ok {
+			result = append(result, Indicator{Value: value, Kind: kind, Source: source, Description: description})
+		}
+	}
+	return result, scanner.Err()
+}
+
+func parseCSV(r io.Reader, source string) ([]Indicator, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	valueCol, descCol := 0, -1
+	header := records[0]
+	hasHeader := false
+	for i, h := range header {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "indicator", "value", "ioc", "ip", "address", "domain", "hostname":
+			valueCol, hasHeader = i, true
+		case "description", "comment", "name", "threat", "tags":
+			if descCol == -1 {
+				descCol = i
+			}
+			hasHeader = true
+		}
+	}
+	if hasHeader {
+		records = records[1:]
+	} else if len(header) > 1 {
+		descCol = 1
+	}
+
+	var result []Indicator
+	for _, rec := range records {
+		if valueCol >= len(rec) {
+			continue
+		}
+		value, kind, ok := classify(rec[valueCol])
+		if !ok {
+			continue
+		}
+		ind := Indicator{Value: value, Kind: kind, Source: source}
+		if descCol >= 0 && descCol < len(rec) {
+			ind.Description = strings.TrimSpace(rec[descCol])
+		}
+		result = append(result, ind)
+	}
+	return result, nil
+}
+
+var stixValue = regexp.MustCompile(`(?:ipv4-addr|ipv6-addr|domain-name):value\s*(?:=|ISSUBSET)\s*'([^']+)'`)
+
+type stixObject struct {
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Pattern     string `json:"pattern"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func parseSTIX(r io.Reader, source string) ([]Indicator, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []stixObject
+	var bundle struct {
+		Objects    []stixObject `json:"objects"`
+		Indicators []stixObject `json:"indicators"`
+	}
+	if err := json.Unmarshal(data, &bundle); err == nil {
+		objects = append(bundle.Objects, bundle.Indicators...)
+	} else if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, fmt.Errorf("%s: not a STIX bundle or indicator list: %w", source, err)
+	}
+
+	var result []Indicator
+	for _, obj := range objects {
+		description := obj.Name
+		if description == "" {
+			description = obj.Description
+		}
+
+		values := []string{obj.Value}
+		if obj.Pattern != "" {
+			values = nil
+			for _, m := range stixValue.FindAllStringSubmatch(obj.Pattern, -1) {
+				values = append(values, m[1])
+			}
+		}
+		for _, v := range values {
+			if value, kind, ok := classify(v); ok {
+				result = append(result, Indicator{Value: value, Kind: kind, Source: source, Description: description})
+			}
+		}
+	}
+	return result, nil
+}
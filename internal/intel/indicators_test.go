@@ -0,0 +1,101 @@
+package intel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	input := `# Feed header
+203.0.113.7    # scanner
+2001:DB8::1
+198.51.100.0/24 extra fields are ignored
+Bad.Example.  # c2
+not-a-host
+300.1.1.1
+
+`
+	got, err := parseText(strings.NewReader(input), "list.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Indicator{
+		{"203.0.113.7", KindIP, "list.txt", "scanner"},
+		{"2001:db8::1", KindIP, "list.txt", ""},
+		{"198.51.100.0/24", KindCIDR, "list.txt", ""},
+		{"bad.example", KindDomain, "list.txt", "c2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name, input string
+		want        []Indicator
+	}{
+		{"header", "# comment\nfirst_seen,Indicator,Threat\n2026-01-01,203.0.113.7,botnet\n2026-01-02,10.0.0.0/8\n2026-01-03,junk!,x\n",
+			[]Indicator{
+				{"203.0.113.7", KindIP, "f.csv", "botnet"},
+				{"10.0.0.0/8", KindCIDR, "f.csv", ""},
+			}},
+		{"no header", "203.0.113.7, scanner\nevil.example\n",
+			[]Indicator{
+				{"203.0.113.7", KindIP, "f.csv", "scanner"},
+				{"evil.example", KindDomain, "f.csv", ""},
+			}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		got, err := parseCSV(strings.NewReader(tt.input), "f.csv")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := parseCSV(strings.NewReader("a,\"b\n"), "f.csv"); err == nil || !strings.HasPrefix(err.Error(), "f.csv:") {
+		t.Errorf("malformed CSV: err = %v", err)
+	}
+}
+
+func TestParseSTIX(t *testing.T) {
+	tests := []struct {
+		name, input string
+		want        []Indicator
+	}{
+		{"bundle", `{"type": "bundle", "objects": [
+			{"type": "indicator", "name": "C2", "pattern": "[ipv4-addr:value = '203.0.113.7'] OR [domain-name:value = 'evil.example']"},
+			{"type": "indicator", "description": "Range", "pattern": "[ipv4-addr:value ISSUBSET '198.51.100.0/24']"},
+			{"type": "malware", "name": "no pattern"}
+		]}`, []Indicator{
+			{"203.0.113.7", KindIP, "s.json", "C2"},
+			{"evil.example", KindDomain, "s.json", "C2"},
+			{"198.51.100.0/24", KindCIDR, "s.json", "Range"},
+		}},
+		{"indicators key", `{"indicators": [{"type": "ipv6-addr", "value": "2001:db8::1", "name": "n", "description": "d"}]}`,
+			[]Indicator{{"2001:db8::1", KindIP, "s.json", "n"}}},
+		{"list", `[{"type": "ip", "value": "203.0.113.7"}, {"type": "domain", "value": "Evil.Example", "description": "d"}]`,
+			[]Indicator{
+				{"203.0.113.7", KindIP, "s.json", ""},
+				{"evil.example", KindDomain, "s.json", "d"},
+			}},
+	}
+	for _, tt := range tests {
+		got, err := parseSTIX(strings.NewReader(tt.input), "s.json")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := parseSTIX(strings.NewReader(`"203.0.113.7"`), "s.json"); err == nil {
+		t.Error("a JSON string parsed as STIX")
+	}
+}
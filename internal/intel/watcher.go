@@ -0,0 +1,282 @@
+package intel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+type set struct {
+	ips  map[string]Indicator
+	nets []netIndicator
+	size int
+}
+
+type netIndicator struct {
+	network *net.IPNet
+	Indicator
+}
+
+func (s *set) lookup(ipStr string) (Indicator, bool) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return Indicator{}, false
+	}
+	if ind, ok := s.ips[ip.String()]; ok {
+		return ind, true
+	}
+	for _, n := range s.nets {
+		if n.network.Contains(ip) {
+			return n.Indicator, true
+		}
+	}
+	return Indicator{}, false
+}
+
+type Options struct {
+	// Blocklists are indicator files; matching connections are flagged.
+	Blocklists []string `json:"blocklists"`
+	// Allowlists use the same formats and exempt addresses from flagging.
+	Allowlists []string `json:"allowlists"`
+	// ResolveHostnames looks up hostname indicators so they can match.
+	// Off by default because it sends DNS queries.
+	ResolveHostnames bool `json:"resolve_hostnames"`
+}
+
+// Watcher holds the indicators from a set of files and reloads them when
+// one of the files changes.
+type Watcher struct {
+	opts Options
+
+	mu      sync.RWMutex
+	block   *set
+	allow   *set
+	mtimes  map[string]time.Time
+	loadErr error // Of the first load, reported by Run
+}
+
+// resolveParallel bounds the hostname lookups running at once.
+const resolveParallel = 16
+
+// NewWatcher loads every list. A file that fails to load is reported but the
+// others stay in use, so the returned Watcher is valid even with an error.
+// Hostname indicators are resolved in the background and match once their
+// lookups finish.
+func NewWatcher(opts Options) (*Watcher, error) {
+	w := &Watcher{opts: opts}
+	w.loadErr = w.load()
+	return w, w.loadErr
+}
+
+func (w *Watcher) Len() int {
+	if w == nil {
+		return 0
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.block.size
+}
+
+// Annotate sets Connection.IOC on every connection whose remote address
+// matches an indicator.
+func (w *Watcher) Annotate(conns []models.Connection) {
+	if w == nil {
+		return
+	}
+	w.mu.RLock()
+	block, allow := w.block, w.allow
+	w.mu.RUnlock()
+
+	for i := range conns {
+		if !conns[i].HasRemote() {
+			continue
+		}
+		ip := conns[i].RemoteIP()
+		if _, allowed := allow.lookup(ip); allowed {
+			continue
+		}
+		if ind, ok := block.lookup(ip); ok {
+			conns[i].IOC = ind.String()
+		}
+	}
+}
+
+// Run checks the files every interval and calls onReload after each reload
+// attempt. An error from the first load is passed to onReload right away.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration, onReload func(n int, err error)) {
+	if w.loadErr != nil && onReload != nil {
+		onReload(w.Len(), w.loadErr)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			err := w.load()
+			if onReload != nil {
+				onReload(w.Len(), err)
+			}
+		}
+	}
+}
+
+func (w *Watcher) changed() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, path := range w.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			if _, known := w.mtimes[path]; known {
+				return true
+			}
+			continue
+		}
+		if !info.ModTime().Equal(w.mtimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Watcher) paths() []string {
+	return append(append([]string(nil), w.opts.Blocklists...), w.opts.Allowlists...)
+}
+
+// load rebuilds both sets. Files that fail to load are skipped and reported,
+// so one broken feed does not drop the others. Hostnames are resolved after
+// the sets are in use, so slow lookups do not hold up loading.
+func (w *Watcher) load() error {
+	mtimes := make(map[string]time.Time)
+	var errs []error
+	block, blockDomains := w.loadSet(w.opts.Blocklists, mtimes, &errs)
+	allow, allowDomains := w.loadSet(w.opts.Allowlists, mtimes, &errs)
+
+	w.mu.Lock()
+	w.block = block
+	w.allow = allow
+	w.mtimes = mtimes
+	w.mu.Unlock()
+
+	if len(blockDomains) > 0 {
+		go w.resolve(&w.block, block, blockDomains)
+	}
+	if len(allowDomains) > 0 {
+		go w.resolve(&w.allow, allow, allowDomains)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("loading indicators: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// loadSet reads paths into a set and returns the hostname indicators left
+// to resolve when ResolveHostnames is on. Without it they are dropped.
+func (w *Watcher) loadSet(paths []string, mtimes map[string]time.Time, errs *[]error) (*set, []Indicator) {
+	s := &set{ips: make(map[string]Indicator)}
+	var domains []Indicator
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			*errs = append(*errs, err)
+			continue
+		}
+		indicators, err := LoadFile(path)
+		if err != nil {
+			*errs = append(*errs, err)
+			continue
+		}
+		mtimes[path] = info.ModTime()
+
+		// Hostnames only count when they are resolved; otherwise they can
+		// never match.
+		for _, ind := range indicators {
+			switch ind.Kind {
+			case KindIP:
+				s.ips[ind.Value] = ind
+			case KindCIDR:
+				_, network, _ := net.ParseCIDR(ind.Value)
+				s.nets = append(s.nets, netIndicator{network, ind})
+			case KindDomain:
+				if !w.opts.ResolveHostnames {
+					continue
+				}
+				domains = append(domains, ind)
+			}
+			s.size++
+		}
+	}
+	return s, domains
+}
+
+// resolve looks up domains, a few at a time, and swaps a copy of s with
+// their addresses into *field, unless a reload has replaced s meanwhile.
+// Addresses listed as IPs keep their own indicator.
+func (w *Watcher) resolve(field **set, s *set, domains []Indicator) {
+	addrs := make([][]string, len(domains))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, resolveParallel)
+	for i, ind := range domains {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			addrs[i] = lookupDomain(ind.Value)
+			<-sem
+		}()
+	}
+	wg.Wait()
+
+	// Merged in file order, so the first domain listing an address wins.
+	resolved := make(map[string]Indicator)
+	for i, ind := range domains {
+		for _, addr := range addrs[i] {
+			if _, exists := resolved[addr]; !exists {
+				resolved[addr] = ind
+			}
+		}
+	}
+	if len(resolved) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if *field != s {
+		return
+	}
+	updated := *s
+	updated.ips = maps.Clone(s.ips)
+	for addr, ind := range resolved {
+		if _, exists := updated.ips[addr]; !exists {
+			updated.ips[addr] = ind
+		}
+	}
+	*field = &updated
+}
+
+func lookupDomain(name string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		return nil
+	}
+	ips := make([]string, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP.String()
+	}
+	return ips
+}
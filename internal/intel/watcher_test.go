@@ -0,0 +1,53 @@
+package intel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+func writeList(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAnnotate(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher(Options{
+		Blocklists: []string{
+			writeList(t, dir, "block.txt", "203.0.113.0/24 # net\n198.51.100.9 # host\nevil.example\n"),
+			filepath.Join(dir, "missing.txt"),
+		},
+		Allowlists: []string{writeList(t, dir, "allow.csv", "ip\n203.0.113.80\n198.51.100.9\n")},
+	})
+	// The missing list is reported, the others still load.
+	if err == nil || !strings.Contains(err.Error(), "missing.txt") {
+		t.Errorf("err = %v, want the missing list reported", err)
+	}
+	// The hostname is not resolved, so it cannot match and is not counted.
+	if w.Len() != 2 {
+		t.Errorf("Len = %d, want 2", w.Len())
+	}
+
+	conns := []models.Connection{
+		{Raddr: "203.0.113.7:443"},
+		{Raddr: "203.0.113.80:443"}, // Allowed inside a blocked network
+		{Raddr: "198.51.100.9:22"},  // Listed in both; the allowlist wins
+		{Raddr: "192.0.2.1:80"},
+		{Raddr: ":0"},
+	}
+	w.Annotate(conns)
+	want := []string{"203.0.113.0/24 (net) [block.txt]", "", "", "", ""}
+	for i, c := range conns {
+		if c.IOC != want[i] {
+			t.Errorf("%s: IOC = %q, want %q", c.Raddr, c.IOC, want[i])
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/conn"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/sockdiag"
+	"github.com/MrBrooks89/BatStat/internal/version"
+)
+
+const otherLabel = "other"
+
+type Options struct {
+	// ProcessAllowlist limits the process label to these names; everything
+	// else is reported as "other". When empty the MaxProcesses busiest
+	// processes of each scrape are labelled instead.
+	ProcessAllowlist []string
+	MaxProcesses     int
+	// StateAllowlist does the same for the state label. Empty keeps every
+	// TCP state, which is already a bounded set.
+	StateAllowlist []string
+	// ProcessIO adds per-process TCP byte gauges read from tcp_info.
+	ProcessIO bool
+}
+
+// Collector gathers a fresh snapshot on every scrape and renders it in the
+// Prometheus text exposition format.
+type Collector struct {
+	opts  Options
+	fetch func() ([]models.Connection, error)
+
+	mu           sync.Mutex
+	scrapes      uint64
+	errors       map[string]uint64
+	lastDuration time.Duration
+}
+
+func NewCollector(opts Options) *Collector {
+	if opts.MaxProcesses <= 0 {
+		opts.MaxProcesses = 20
+	}
+	return &Collector{
+		opts:   opts,
+		fetch:  conn.FetchConnections,
+		errors: map[string]uint64{"connections": 0, "sockdiag": 0},
+	}
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Collect(w)
+}
+
+func (c *Collector) Collect(w io.Writer) {
+	start := time.Now()
+	conns, err := c.fetch()
+
+	var sockets []sockdiag.Socket
+	var diagErr error
+	if err == nil && c.opts.ProcessIO {
+		sockets, diagErr = sockdiag.TCPSockets()
+	}
+	duration := time.Since(start)
+
+	c.mu.Lock()
+	c.scrapes++
+	c.lastDuration = duration
+	if err != nil {
+		c.errors["connections"]++
+	}
+	if diagErr != nil {
+		c.errors["sockdiag"]++
+	}
+	scrapes := c.scrapes
+	errs := make(map[string]uint64, len(c.errors))
+	for k, v := range c.errors {
+		errs[k] = v
+	}
+	c.mu.Unlock()
+
+	e := &encoder{w: w}
+	e.family("batstat_build_info", "gauge", "BatStat build information.")
+	e.sample("batstat_build_info", labels{"version", version.String()}, 1)
+
+	if err == nil {
+		c.writeConnections(e, conns)
+		if c.opts.ProcessIO && diagErr == nil {
+			c.writeProcessIO(e, conns, sockets)
+		}
+	}
+
+	e.family("batstat_collector_duration_seconds", "gauge", "Time taken to collect the last snapshot.")
+	e.sample("batstat_collector_duration_seconds", nil, duration.Seconds())
+	e.family("batstat_collector_scrapes_total", "counter", "Number of snapshots collected.")
+	e.sample("batstat_collector_scrapes_total", nil, float64(scrapes))
+	e.family("batstat_collector_errors_total", "counter", "Number of failed collections by source.")
+	for _, source := range sortedKeys(errs) {
+		e.sample("batstat_collector_errors_total", labels{"source", source}, float64(errs[source]))
+	}
+}
+
+func (c *Collector) writeConnections(e *encoder, conns []models.Connection) {
+	byState := make(map[string]int)
+	byProto := make(map[string]int)
+	byFamily := make(map[string]int)
+	byProcess := make(map[string]int)
+	listening := make(map[string]int)
+
+	for _, cn := range conns {
+		byState[c.stateLabel(cn.Status)]++
+		byProto[cn.Type]++
+		byFamily[cn.Family]++
+		byProcess[processName(cn)]++
+		if isListening(cn) {
+			listening[cn.Type]++
+		}
+	}
+
+	e.family("batstat_connections", "gauge", "Open sockets by connection state.")
+	for _, k := range sortedKeys(byState) {
+		e.sample("batstat_connections", labels{"state", k}, float64(byState[k]))
+	}
+	e.family("batstat_connections_by_protocol", "gauge", "Open sockets by protocol.")
+	for _, k := range sortedKeys(byProto) {
+		e.sample("batstat_connections_by_protocol", labels{"protocol", k}, float64(byProto[k]))
+	}
+	e.family("batstat_connections_by_family", "gauge", "Open sockets by address family.")
+	for _, k := range sortedKeys(byFamily) {
+		e.sample("batstat_connections_by_family", labels{"family", k}, float64(byFamily[k]))
+	}
+
+	byLabel := c.bucketProcesses(byProcess)
+	e.family("batstat_connections_by_process", "gauge", "Open sockets by owning process name.")
+	for _, k := range sortedKeys(byLabel) {
+		e.sample("batstat_connections_by_process", labels{"process", k}, float64(byLabel[k]))
+	}
+
+	e.family("batstat_listening_sockets", "gauge", "Listening TCP sockets and unconnected UDP sockets.")
+	for _, k := range sortedKeys(listening) {
+		e.sample("batstat_listening_sockets", labels{"protocol", k}, float64(listening[k]))
+	}
+}
+
+func (c *Collector) writeProcessIO(e *encoder, conns []models.Connection, sockets []sockdiag.Socket) {
+	owners := make(map[string]string, len(conns))
+	counts := make(map[string]int)
+	for _, cn := range conns {
+		if cn.Type == "TCP" {
+			name := processName(cn)
+			owners[sockdiag.AddrKey(cn.Laddr, cn.Raddr)] = name
+			counts[name]++
+		}
+	}
+	allowed := c.processLabels(counts)
+
+	rx := make(map[string]uint64)
+	tx := make(map[string]uint64)
+	for _, s := range sockets {
+		if !s.HasCounters {
+			continue
+		}
+		name, ok := owners[s.Key()]
+		if !ok {
+			continue
+		}
+		label := otherLabel
+		if allowed[name] {
+			label = name
+		}
+		rx[label] += s.BytesReceived
+		tx[label] += s.BytesSent
+	}
+
+	e.family("batstat_process_tcp_received_bytes", "gauge", "Bytes received on the process's currently open TCP sockets.")
+	for _, k := range sortedKeys(rx) {
+		e.sample("batstat_process_tcp_received_bytes", labels{"process", k}, float64(rx[k]))
+	}
+	e.family("batstat_process_tcp_sent_bytes", "gauge", "Bytes sent and acknowledged on the process's currently open TCP sockets.")
+	for _, k := range sortedKeys(tx) {
+		e.sample("batstat_process_tcp_sent_bytes", labels{"process", k}, float64(tx[k]))
+	}
+}
+
+func (c *Collector) stateLabel(status string) string {
+	if status == "" {
+		status = "NONE"
+	}
+	if len(c.opts.StateAllowlist) == 0 {
+		return status
+	}
+	for _, s := range c.opts.StateAllowlist {
+		if strings.EqualFold(s, status) {
+			return status
+		}
+	}
+	return otherLabel
+}
+
+// processLabels picks which process names keep their own label.
+func (c *Collector) processLabels(counts map[string]int) map[string]bool {
+	allowed := make(map[string]bool)
+	if len(c.opts.ProcessAllowlist) > 0 {
+		for _, name := range c.opts.ProcessAllowlist {
+			allowed[name] = true
+		}
+		return allowed
+	}
+
+	names := sortedKeys(counts)
+	sort.SliceStable(names, func(i, j int) bool { return counts[names[i]] > counts[names[j]] })
+	for i, name := range names {
+		if i >= c.opts.MaxProcesses {
+			break
+		}
+		allowed[name] = true
+	}
+	return allowed
+}
+
+func (c *Collector) bucketProcesses(counts map[string]int) map[string]int {
+	allowed := c.processLabels(counts)
+	result := make(map[string]int)
+	for name, n := range counts {
+		if allowed[name] {
+			result[name] += n
+		} else {
+			result[otherLabel] += n
+		}
+	}
+	return result
+}
+
+func processName(c models.Connection) string {
+	if c.ProcessName == "" {
+		return "unknown"
+	}
+	return c.ProcessName
+}
+
+func isListening(c models.Connection) bool {
+	switch c.Type {
+	case "TCP":
+		return c.Status == "LISTEN"
+	case "UDP":
+		return c.Family != "Unix" && !c.HasRemote()
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type labels []string
+
+type encoder struct {
+	w io.Writer
+}
+
+func (e *encoder) family(name, typ, help string) {
+	fmt.Fprintf(e.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (e *encoder) sample(name string, l labels, value float64) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(l) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(l); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", l[i], escapeLabel(l[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(e.w, "%s %s\n", b.String(), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
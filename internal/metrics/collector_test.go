@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/version"
+)
+
+func testCollector(t *testing.T, opts Options, conns []models.Connection, err error) *Collector {
+	t.Helper()
+	old := version.Version
+	version.Version = "v1.2.3"
+	t.Cleanup(func() { version.Version = old })
+	c := NewCollector(opts)
+	c.fetch = func() ([]models.Connection, error) { return conns, err }
+	return c
+}
+
+var durationSample = regexp.MustCompile(`(?m)^batstat_collector_duration_seconds .*$`)
+
+// scrape collects once and blanks the timing, the only output that varies.
+func scrape(c *Collector) string {
+	var b strings.Builder
+	c.Collect(&b)
+	return durationSample.ReplaceAllString(b.String(), "batstat_collector_duration_seconds X")
+}
+
+// samples returns the lines of one metric family's samples.
+func samples(out, name string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, name+"{") || strings.HasPrefix(line, name+" ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func tcp(proc, status string) models.Connection {
+	return models.Connection{Family: "IPv4", Type: "TCP", ProcessName: proc,
+		Laddr: "10.0.0.1:40000", Raddr: "10.0.0.2:443", Status: status}
+}
+
+func TestCollect(t *testing.T) {
+	conns := []models.Connection{
+		tcp("nginx", "ESTABLISHED"),
+		tcp("nginx", "ESTABLISHED"),
+		{Family: "IPv4", Type: "TCP", ProcessName: "nginx", Laddr: "0.0.0.0:80", Raddr: ":0", Status: "LISTEN"},
+		{Family: "IPv6", Type: "UDP", Laddr: ":::53", Raddr: ":::0"},
+	}
+	c := testCollector(t, Options{}, conns, nil)
+
+	want := `# HELP batstat_build_info BatStat build information.
+# TYPE batstat_build_info gauge
+batstat_build_info{version="v1.2.3"} 1
+# HELP batstat_connections Open sockets by connection state.
+# TYPE batstat_connections gauge
+batstat_connections{state="ESTABLISHED"} 2
+batstat_connections{state="LISTEN"} 1
+batstat_connections{state="NONE"} 1
+# HELP batstat_connections_by_protocol Open sockets by protocol.
+# TYPE batstat_connections_by_protocol gauge
+batstat_connections_by_protocol{protocol="TCP"} 3
+batstat_connections_by_protocol{protocol="UDP"} 1
+# HELP batstat_connections_by_family Open sockets by address family.
+# TYPE batstat_connections_by_family gauge
+batstat_connections_by_family{family="IPv4"} 3
+batstat_connections_by_family{family="IPv6"} 1
+# HELP batstat_connections_by_process Open sockets by owning process name.
+# TYPE batstat_connections_by_process gauge
+batstat_connections_by_process{process="nginx"} 3
+batstat_connections_by_process{process="unknown"} 1
+# HELP batstat_listening_sockets Listening TCP sockets and unconnected UDP sockets.
+# TYPE batstat_listening_sockets gauge
+batstat_listening_sockets{protocol="TCP"} 1
+batstat_listening_sockets{protocol="UDP"} 1
+# HELP batstat_collector_duration_seconds Time taken to collect the last snapshot.
+# TYPE batstat_collector_duration_seconds gauge
+batstat_collector_duration_seconds X
+# HELP batstat_collector_scrapes_total Number of snapshots collected.
+# TYPE batstat_collector_scrapes_total counter
+batstat_collector_scrapes_total 1
+# HELP batstat_collector_errors_total Number of failed collections by source.
+# TYPE batstat_collector_errors_total counter
+batstat_collector_errors_total{source="connections"} 0
+batstat_collector_errors_total{source="sockdiag"} 0
+`
+	if got := scrape(c); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got := samples(scrape(c), "batstat_collector_scrapes_total"); got[0] != "batstat_collector_scrapes_total 2" {
+		t.Errorf("second scrape: %q", got)
+	}
+}
+
+func TestCollectError(t *testing.T) {
+	c := testCollector(t, Options{}, nil, errors.New("no /proc"))
+	out := scrape(c)
+	if strings.Contains(out, "batstat_connections") {
+		t.Errorf("connection metrics despite the error:\n%s", out)
+	}
+	want := []string{
+		`batstat_collector_errors_total{source="connections"} 1`,
+		`batstat_collector_errors_total{source="sockdiag"} 0`,
+	}
+	if got := samples(out, "batstat_collector_errors_total"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	c := testCollector(t, Options{}, []models.Connection{tcp("a\"b\\c\nd", "ESTABLISHED")}, nil)
+	want := `batstat_connections_by_process{process="a\"b\\c\nd"} 1`
+	if got := samples(scrape(c), "batstat_connections_by_process"); len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestProcessLabels(t *testing.T) {
+	var conns []models.Connection
+	for proc, n := range map[string]int{"nginx": 4, "sshd": 3, "chrome": 2, "curl": 1} {
+		for range n {
+			conns = append(conns, tcp(proc, "ESTABLISHED"))
+		}
+	}
+	conns = append(conns, tcp("", "TIME_WAIT"), tcp("", "CLOSE_WAIT"))
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"top two", Options{MaxProcesses: 2}, []string{
+			`batstat_connections_by_process{process="nginx"} 4`,
+			`batstat_connections_by_process{process="other"} 5`,
+			`batstat_connections_by_process{process="sshd"} 3`,
+		}},
+		// Ties keep name order: "unknown" has 2 like chrome but sorts after it.
+		{"tie at the cutoff", Options{MaxProcesses: 3}, []string{
+			`batstat_connections_by_process{process="chrome"} 2`,
+			`batstat_connections_by_process{process="nginx"} 4`,
+			`batstat_connections_by_process{process="other"} 3`,
+			`batstat_connections_by_process{process="sshd"} 3`,
+		}},
+		{"allowlist overrides the cutoff", Options{MaxProcesses: 1, ProcessAllowlist: []string{"curl", "sshd", "absent"}}, []string{
+			`batstat_connections_by_process{process="curl"} 1`,
+			`batstat_connections_by_process{process="other"} 8`,
+			`batstat_connections_by_process{process="sshd"} 3`,
+		}},
+	}
+	for _, tt := range tests {
+		c := testCollector(t, tt.opts, conns, nil)
+		if got := samples(scrape(c), "batstat_connections_by_process"); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStateAllowlist(t *testing.T) {
+	conns := []models.Connection{tcp("a", "ESTABLISHED"), tcp("a", "TIME_WAIT"), tcp("a", "CLOSE_WAIT"), tcp("a", "LISTEN")}
+	c := testCollector(t, Options{StateAllowlist: []string{"established", "LISTEN"}}, conns, nil)
+	want := []string{
+		`batstat_connections{state="ESTABLISHED"} 1`,
+		`batstat_connections{state="LISTEN"} 1`,
+		`batstat_connections{state="other"} 2`,
+	}
+	if got := samples(scrape(c), "batstat_connections"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
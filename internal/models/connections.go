@@ -4,25 +4,28 @@ import (
 	"fmt"
 	"os/user"
 	"strconv"
+	"strings"
 
 	"github.com/shirou/gopsutil/v3/net"
 	"github.com/shirou/gopsutil/v3/process"
 )
 
 type Connection struct {
-	Fd          uint32
-	Family      string
-	Type        string
-	Laddr       string
-	Raddr       string
-	Status      string
-	Pid         int32
-	ProcessName string
+	Fd          uint32 `json:"fd"`
+	Family      string `json:"family"`
+	Type        string `json:"type"`
+	Laddr       string `json:"laddr"`
+	Raddr       string `json:"raddr"`
+	Status      string `json:"status"`
+	Pid         int32  `json:"pid"`
+	ProcessName string `json:"process"`
+	Host        string `json:"host,omitempty"`
+	IOC         string `json:"ioc,omitempty"` // Matched threat-intel indicator
 }
 
 type DetailedInfo struct {
-	Username string
-	Cmdline  string
+	Username string `json:"username"`
+	Cmdline  string `json:"cmdline"`
 }
 
 func FromNetConnectionStat(stat net.ConnectionStat, procCache map[int32]*process.Process) (Connection, map[int32]*process.Process) {
@@ -79,6 +82,52 @@ func GetDetailedInfo(pid int32) DetailedInfo {
 	}
 }
 
+// Key identifies a socket across refreshes.
+func (c Connection) Key() string {
+	return c.Host + "|" + c.Family + "|" + c.Type + "|" + c.Laddr + "|" + c.Raddr + "|" +
+		strconv.Itoa(int(c.Pid)) + "|" + strconv.FormatUint(uint64(c.Fd), 10)
+}
+
+func (c Connection) LocalIP() string {
+	ip, _ := SplitAddr(c.Laddr)
+	return ip
+}
+
+func (c Connection) LocalPort() uint32 {
+	_, port := SplitAddr(c.Laddr)
+	return port
+}
+
+func (c Connection) RemoteIP() string {
+	ip, _ := SplitAddr(c.Raddr)
+	return ip
+}
+
+func (c Connection) RemotePort() uint32 {
+	_, port := SplitAddr(c.Raddr)
+	return port
+}
+
+// HasRemote reports whether the connection has a concrete peer address.
+func (c Connection) HasRemote() bool {
+	ip := c.RemoteIP()
+	return ip != "" && ip != "0.0.0.0" && ip != "::" && ip != "*"
+}
+
+// SplitAddr splits the "ip:port" form produced by FromNetConnectionStat.
+// IPv6 addresses are not bracketed there, so the port follows the last colon.
+func SplitAddr(addr string) (string, uint32) {
+	i := strings.LastIndex(addr, ":")
+	if i < 0 {
+		return addr, 0
+	}
+	port, err := strconv.ParseUint(addr[i+1:], 10, 32)
+	if err != nil {
+		return addr, 0
+	}
+	return addr[:i], uint32(port)
+}
+
 func mapFamily(f uint32) string {
 	switch f {
 	case 2:
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+// HostInfo describes the machine BatStat runs on.
+type HostInfo struct {
+	Hostname string
+	Kernel   string
+	Uptime   time.Duration
+	Load     *load.AvgStat // nil where the platform has no load average
+}
+
+// GetHostInfo gathers what it can; fields it cannot read stay empty.
+func GetHostInfo() HostInfo {
+	var info HostInfo
+	info.Hostname, _ = os.Hostname()
+	if version, err := host.KernelVersion(); err == nil {
+		info.Kernel = runtime.GOOS + " " + version
+	}
+	if uptime, err := host.Uptime(); err == nil {
+		info.Uptime = time.Duration(uptime) * time.Second
+	}
+	if avg, err := load.Avg(); err == nil {
+		info.Load = avg
+	}
+	return info
+}
@@ -0,0 +1,82 @@
+// Package neigh reads the kernel's neighbor (ARP and NDP) table, mapping
+// on-link peer addresses to their link-layer addresses.
+package neigh
+
+import (
+	"cmp"
+	"errors"
+	"net/netip"
+	"slices"
+)
+
+var ErrUnsupported = errors.New("the neighbor table is only available on Linux")
+
+// Neighbor is one entry of the neighbor table.
+type Neighbor struct {
+	Family string // IPv4 or IPv6
+	IP     netip.Addr
+	MAC    string // Empty while unresolved
+	Iface  string
+	State  string // REACHABLE, STALE, DELAY, PROBE, FAILED, INCOMPLETE, PERMANENT; COMPLETE from /proc/net/arp
+	Router bool   // The peer advertised itself as an IPv6 router
+}
+
+// Duplicates returns the IPv4 neighbors that share their MAC address with
+// another IPv4 address on the same interface, keyed by "iface|mac". One
+// machine answering for several addresses is what ARP spoofing looks like,
+// though proxy ARP and hosts with secondary addresses do it legitimately.
+// IPv6 is left out, since hosts normally have several IPv6 addresses.
+func Duplicates(neighbors []Neighbor) map[string][]netip.Addr {
+	ips := make(map[string][]netip.Addr)
+	for _, n := range neighbors {
+		if n.Family != "IPv4" || n.MAC == "" || n.MAC == "00:00:00:00:00:00" || slices.Contains(ips[n.key()], n.IP) {
+			continue
+		}
+		ips[n.key()] = append(ips[n.key()], n.IP)
+	}
+	for key, addrs := range ips {
+		if len(addrs) < 2 {
+			delete(ips, key)
+			continue
+		}
+		slices.SortFunc(addrs, netip.Addr.Compare)
+	}
+	return ips
+}
+
+// DuplicateOf returns the other addresses sharing n's MAC address, if any.
+func DuplicateOf(dups map[string][]netip.Addr, n Neighbor) []netip.Addr {
+	addrs, ok := dups[n.key()]
+	if !ok || n.Family != "IPv4" {
+		return nil
+	}
+	return slices.DeleteFunc(slices.Clone(addrs), func(a netip.Addr) bool { return a == n.IP })
+}
+
+func (n Neighbor) key() string {
+	return n.Iface + "|" + n.MAC
+}
+
+// Find returns the entry for ip, preferring a resolved one when several
+// interfaces know it.
+func Find(neighbors []Neighbor, ip netip.Addr) (Neighbor, bool) {
+	ip = ip.Unmap()
+	var found Neighbor
+	ok := false
+	for _, n := range neighbors {
+		if n.IP != ip {
+			continue
+		}
+		if !ok || (found.MAC == "" && n.MAC != "") {
+			found, ok = n, true
+		}
+	}
+	return found, ok
+}
+
+// Sort orders neighbors by family, interface and address.
+func Sort(neighbors []Neighbor) {
+	slices.SortFunc(neighbors, func(a, b Neighbor) int {
+		return cmp.Or(cmp.Compare(a.Family, b.Family), cmp.Compare(a.Iface, b.Iface), a.IP.Compare(b.IP))
+	})
+}
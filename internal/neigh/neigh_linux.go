@@ -0,0 +1,156 @@
+package neigh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/MrBrooks89/BatStat/internal/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// ARP flags of /proc/net/arp (linux/if_arp.h).
+const (
+	atfComplete  = 0x02
+	atfPermanent = 0x04
+)
+
+// List dumps the IPv4 and IPv6 neighbor tables over netlink. Where netlink
+// is unavailable, such as in some containers, it falls back to the IPv4
+// entries of /proc/net/arp.
+func List() ([]Neighbor, error) {
+	neighbors, err := dump()
+	if err == nil {
+		return neighbors, nil
+	}
+	if neighbors, procErr := readProcARP("/proc/net/arp"); procErr == nil {
+		return neighbors, nil
+	}
+	return nil, err
+}
+
+func dump() ([]Neighbor, error) {
+	names := make(map[int]string)
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		names[iface.Index] = iface.Name
+	}
+
+	var neighbors []Neighbor
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		req := make([]byte, unix.SizeofNdMsg)
+		req[0] = family
+		msgs, err := netlink.Request(unix.NETLINK_ROUTE, unix.RTM_GETNEIGH, unix.NLM_F_DUMP, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Header.Type != unix.RTM_NEWNEIGH {
+				continue
+			}
+			if n, ok := parseNdMsg(m.Data, family, names); ok {
+				neighbors = append(neighbors, n)
+			}
+		}
+	}
+	return neighbors, nil
+}
+
+// parseNdMsg reads a struct ndmsg and its attributes.
+func parseNdMsg(b []byte, family uint8, names map[int]string) (Neighbor, bool) {
+	if len(b) < unix.SizeofNdMsg {
+		return Neighbor{}, false
+	}
+	if b[0] != unix.AF_UNSPEC {
+		family = b[0]
+	}
+	index := int(int32(binary.NativeEndian.Uint32(b[4:8])))
+	n := Neighbor{
+		Family: "IPv4",
+		Iface:  names[index],
+		State:  stateName(binary.NativeEndian.Uint16(b[8:10])),
+		Router: b[10]&unix.NTF_ROUTER != 0,
+	}
+	if family == unix.AF_INET6 {
+		n.Family = "IPv6"
+	}
+	if n.Iface == "" {
+		n.Iface = "if" + strconv.Itoa(index)
+	}
+	for _, attr := range netlink.ParseAttrs(b[unix.SizeofNdMsg:]) {
+		switch attr.Type {
+		case unix.NDA_DST:
+			n.IP, _ = netip.AddrFromSlice(attr.Value)
+		case unix.NDA_LLADDR:
+			if len(attr.Value) > 0 {
+				n.MAC = net.HardwareAddr(attr.Value).String()
+			}
+		}
+	}
+	// Like ip neigh, skip the NOARP entries of loopback and multicast
+	// addresses, which never resolve.
+	return n, n.IP.IsValid() && n.State != "NOARP"
+}
+
+func stateName(state uint16) string {
+	switch {
+	case state&unix.NUD_PERMANENT != 0:
+		return "PERMANENT"
+	case state&unix.NUD_NOARP != 0:
+		return "NOARP"
+	case state&unix.NUD_REACHABLE != 0:
+		return "REACHABLE"
+	case state&unix.NUD_STALE != 0:
+		return "STALE"
+	case state&unix.NUD_DELAY != 0:
+		return "DELAY"
+	case state&unix.NUD_PROBE != 0:
+		return "PROBE"
+	case state&unix.NUD_FAILED != 0:
+		return "FAILED"
+	case state&unix.NUD_INCOMPLETE != 0:
+		return "INCOMPLETE"
+	}
+	return "NONE"
+}
+
+// readProcARP parses /proc/net/arp, which has no neighbor states: entries
+// are COMPLETE, PERMANENT or INCOMPLETE.
+func readProcARP(path string) ([]Neighbor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var neighbors []Neighbor
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		ip, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		flags, _ := strconv.ParseUint(fields[2], 0, 32)
+		n := Neighbor{Family: "IPv4", IP: ip, Iface: fields[5], State: "INCOMPLETE"}
+		switch {
+		case flags&atfPermanent != 0:
+			n.State = "PERMANENT"
+		case flags&atfComplete != 0:
+			n.State = "COMPLETE"
+		}
+		if n.State != "INCOMPLETE" {
+			n.MAC = fields[3]
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, scanner.Err()
+}
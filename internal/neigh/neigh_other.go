@@ -0,0 +1,7 @@
+//go:build !linux
+
+package neigh
+
+func List() ([]Neighbor, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,136 @@
+// Package netif lists this machine's network interfaces with their
+// addresses and traffic counters, and turns two readings into rates.
+package netif
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// Interface is one network interface at the time of a reading.
+type Interface struct {
+	Name         string
+	Index        int
+	MTU          int
+	HardwareAddr string
+	Flags        []string
+	Addrs        []string // CIDR notation, e.g. "192.168.1.10/24"
+	Counters     Counters
+}
+
+// Counters are the cumulative totals since the interface came up.
+type Counters struct {
+	BytesRecv, BytesSent     uint64
+	PacketsRecv, PacketsSent uint64
+	ErrIn, ErrOut            uint64
+	DropIn, DropOut          uint64
+}
+
+// Rates are Counters per second.
+type Rates struct {
+	BytesRecv, BytesSent     float64
+	PacketsRecv, PacketsSent float64
+	ErrIn, ErrOut            float64
+	DropIn, DropOut          float64
+}
+
+// State summarises the flags: "up" with a carrier, "no-carrier" when
+// administratively up without one, otherwise "down".
+func (i Interface) State() string {
+	switch {
+	case i.Has("up") && i.Has("running"):
+		return "up"
+	case i.Has("up"):
+		return "no-carrier"
+	}
+	return "down"
+}
+
+func (i Interface) Has(flag string) bool {
+	return slices.Contains(i.Flags, flag)
+}
+
+// List reads every interface, ordered by index. Interfaces missing from the
+// counters, which some platforms omit, have zero counters.
+func List() ([]Interface, error) {
+	// The standard library reports the running flag, which gopsutil drops.
+	stats, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	counters := make(map[string]Counters)
+	if io, err := psnet.IOCounters(true); err == nil {
+		for _, c := range io {
+			counters[c.Name] = Counters{
+				BytesRecv: c.BytesRecv, BytesSent: c.BytesSent,
+				PacketsRecv: c.PacketsRecv, PacketsSent: c.PacketsSent,
+				ErrIn: c.Errin, ErrOut: c.Errout,
+				DropIn: c.Dropin, DropOut: c.Dropout,
+			}
+		}
+	}
+
+	ifaces := make([]Interface, 0, len(stats))
+	for _, s := range stats {
+		iface := Interface{
+			Name:         s.Name,
+			Index:        s.Index,
+			MTU:          s.MTU,
+			HardwareAddr: s.HardwareAddr.String(),
+			Flags:        strings.Split(s.Flags.String(), "|"),
+			Counters:     counters[s.Name],
+		}
+		addrs, _ := s.Addrs()
+		for _, a := range addrs {
+			iface.Addrs = append(iface.Addrs, a.String())
+		}
+		ifaces = append(ifaces, iface)
+	}
+	slices.SortFunc(ifaces, func(a, b Interface) int { return a.Index - b.Index })
+	return ifaces, nil
+}
+
+// Rate returns the change from prev to c per second over elapsed. A counter
+// that went backwards, as after a driver reset, counts as zero.
+func (c Counters) Rate(prev Counters, elapsed time.Duration) Rates {
+	secs := elapsed.Seconds()
+	if secs <= 0 {
+		return Rates{}
+	}
+	rate := func(cur, old uint64) float64 {
+		if cur < old {
+			return 0
+		}
+		return float64(cur-old) / secs
+	}
+	return Rates{
+		BytesRecv: rate(c.BytesRecv, prev.BytesRecv), BytesSent: rate(c.BytesSent, prev.BytesSent),
+		PacketsRecv: rate(c.PacketsRecv, prev.PacketsRecv), PacketsSent: rate(c.PacketsSent, prev.PacketsSent),
+		ErrIn: rate(c.ErrIn, prev.ErrIn), ErrOut: rate(c.ErrOut, prev.ErrOut),
+		DropIn: rate(c.DropIn, prev.DropIn), DropOut: rate(c.DropOut, prev.DropOut),
+	}
+}
+
+// FormatRate renders bytes per second with a binary unit, e.g. "1.5 MiB/s".
+func FormatRate(bytesPerSec float64) string {
+	return FormatBytes(bytesPerSec) + "/s"
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "1.5 MiB".
+func FormatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for bytes >= 1024 && i < len(units)-1 {
+		bytes /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[i])
+}
@@ -0,0 +1,3 @@
+// Package netlink holds the small amount of raw netlink plumbing shared by
+// the Linux-only collectors. It is empty on other platforms.
+package netlink
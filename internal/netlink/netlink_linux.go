@@ -0,0 +1,127 @@
+package netlink
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	headerLen = unix.NLMSG_HDRLEN
+	// attrTypeMask clears NLA_F_NESTED and NLA_F_NET_BYTEORDER.
+	attrTypeMask = 0x3fff
+)
+
+type Attr struct {
+	Type  uint16
+	Value []byte
+}
+
+var seq atomic.Uint32
+
+// Request sends a single netlink message and collects the replies. Dumps
+// (NLM_F_DUMP) are read until NLMSG_DONE; other requests return after the
+// first reply or acknowledgement.
+func Request(proto int, msgType, flags uint16, payload []byte) ([]syscall.NetlinkMessage, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	n := seq.Add(1)
+	msg := make([]byte, headerLen+len(payload))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:6], msgType)
+	binary.NativeEndian.PutUint16(msg[6:8], flags|unix.NLM_F_REQUEST)
+	binary.NativeEndian.PutUint32(msg[8:12], n)
+	copy(msg[headerLen:], payload)
+
+	if err := unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	dump := flags&unix.NLM_F_DUMP == unix.NLM_F_DUMP
+	var result []syscall.NetlinkMessage
+	buf := make([]byte, 1<<16)
+	for {
+		nr, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:nr])
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != n {
+				continue
+			}
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				if err := errnoFrom(m.Data); err != nil {
+					return nil, err
+				}
+				return result, nil
+			case unix.NLMSG_ERROR:
+				if err := errnoFrom(m.Data); err != nil {
+					return nil, err
+				}
+				return result, nil
+			}
+			result = append(result, m)
+			if !dump && m.Header.Flags&unix.NLM_F_MULTI == 0 {
+				return result, nil
+			}
+		}
+	}
+}
+
+func errnoFrom(data []byte) error {
+	if len(data) < 4 {
+		return nil
+	}
+	if code := int32(binary.NativeEndian.Uint32(data[:4])); code < 0 {
+		return syscall.Errno(-code)
+	}
+	return nil
+}
+
+// ParseAttrs splits a run of netlink/rtnetlink attributes. The nested and
+// byte-order flag bits are stripped from Type.
+func ParseAttrs(b []byte) []Attr {
+	var attrs []Attr
+	for len(b) >= 4 {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < 4 || l > len(b) {
+			break
+		}
+		attrs = append(attrs, Attr{
+			Type:  binary.NativeEndian.Uint16(b[2:4]) & attrTypeMask,
+			Value: b[4:l],
+		})
+		b = b[min(align(l), len(b)):]
+	}
+	return attrs
+}
+
+// AppendAttr appends an attribute, padded to netlink alignment.
+func AppendAttr(b []byte, typ uint16, value []byte) []byte {
+	l := 4 + len(value)
+	hdr := make([]byte, 4)
+	binary.NativeEndian.PutUint16(hdr[0:2], uint16(l))
+	binary.NativeEndian.PutUint16(hdr[2:4], typ)
+	b = append(b, hdr...)
+	b = append(b, value...)
+	return append(b, make([]byte, align(l)-l)...)
+}
+
+func align(l int) int {
+	return (l + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+}
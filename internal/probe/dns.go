@@ -0,0 +1,404 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	DefaultDNSTimeout = 3 * time.Second
+	// maxCNAMEs bounds how far a CNAME chain is followed.
+	maxCNAMEs = 8
+)
+
+type DNSRecord struct {
+	Name  string
+	Type  string
+	Value string
+	TTL   int // Seconds; -1 when the system resolver does not report it
+}
+
+// NameCheck is the forward lookup of one PTR name.
+type NameCheck struct {
+	Name string
+	// Records holds the CNAME chain followed by the A/AAAA records.
+	Records []DNSRecord
+	// Confirmed is set when an address record points back at the IP.
+	Confirmed bool
+	Err       error
+}
+
+type DNSReport struct {
+	IP      string
+	Server  string // Empty when the system resolver answered
+	PTR     []DNSRecord
+	Names   []NameCheck
+	Elapsed time.Duration
+}
+
+// Table lays the report out as Name, Type, TTL, Value and Status columns.
+func (r DNSReport) Table() [][]string {
+	var rows [][]string
+	for _, rec := range r.PTR {
+		status := "not forward-confirmed"
+		for _, n := range r.Names {
+			if n.Name == rec.Value && n.Confirmed {
+				status = "forward-confirmed"
+			}
+		}
+		rows = append(rows, append(rec.row(), status))
+	}
+	for _, n := range r.Names {
+		if n.Err != nil {
+			rows = append(rows, []string{n.Name, "A/AAAA", "", "", "error: " + n.Err.Error()})
+			continue
+		}
+		if len(n.Records) == 0 {
+			rows = append(rows, []string{n.Name, "A/AAAA", "", "", "no address records"})
+		}
+		for _, rec := range n.Records {
+			status := ""
+			if (rec.Type == "A" || rec.Type == "AAAA") && sameIP(rec.Value, r.IP) {
+				status = "matches " + r.IP
+			}
+			rows = append(rows, append(rec.row(), status))
+		}
+	}
+	return rows
+}
+
+func (rec DNSRecord) row() []string {
+	ttl := "-"
+	if rec.TTL >= 0 {
+		ttl = fmt.Sprint(rec.TTL)
+	}
+	return []string{rec.Name, rec.Type, ttl, rec.Value}
+}
+
+// Resolver is a small DNS client that, unlike net.Resolver, reports TTLs
+// and CNAME chains. Without a server it falls back to the system resolver.
+type Resolver struct {
+	// Server is "host" or "host:port"; empty uses the first nameserver of
+	// /etc/resolv.conf, if any.
+	Server  string
+	Timeout time.Duration
+}
+
+// DefaultNameserver returns the first nameserver of /etc/resolv.conf, or ""
+// where there is none (Windows, some containers).
+func DefaultNameserver() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+func (r Resolver) server() string {
+	server := r.Server
+	if server == "" {
+		server = DefaultNameserver()
+	}
+	if server == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	return server
+}
+
+// Inspect looks up the PTR names of ip and forward-confirms each of them:
+// a name is confirmed when its A/AAAA records include ip again.
+func (r Resolver) Inspect(ctx context.Context, ip string) (DNSReport, error) {
+	start := time.Now()
+	report := DNSReport{IP: ip, Server: r.server()}
+	if net.ParseIP(ip) == nil {
+		return report, fmt.Errorf("%q is not an IP address", ip)
+	}
+
+	var err error
+	if report.Server == "" {
+		err = report.systemLookup(ctx)
+	} else {
+		err = report.lookup(ctx, r.Timeout)
+	}
+	report.Elapsed = time.Since(start)
+	return report, err
+}
+
+func (report *DNSReport) lookup(ctx context.Context, timeout time.Duration) error {
+	client := dnsClient{server: report.Server, timeout: timeout}
+	ptrs, err := client.query(ctx, reverseName(net.ParseIP(report.IP)), dnsmessage.TypePTR)
+	if err != nil {
+		return err
+	}
+	for _, rec := range ptrs {
+		if rec.Type != "PTR" {
+			continue
+		}
+		report.PTR = append(report.PTR, rec)
+		check := NameCheck{Name: rec.Value}
+		for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			records, err := client.resolve(ctx, rec.Value, qtype)
+			if err != nil {
+				check.Err = err
+				break
+			}
+			check.Records = appendNew(check.Records, records)
+		}
+		check.confirm(report.IP)
+		report.Names = append(report.Names, check)
+	}
+	return nil
+}
+
+// systemLookup uses the OS resolver, which knows neither TTLs nor the
+// intermediate names of a CNAME chain.
+func (report *DNSReport) systemLookup(ctx context.Context) error {
+	names, err := net.DefaultResolver.LookupAddr(ctx, report.IP)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil
+		}
+		return err
+	}
+	for _, name := range names {
+		name = strings.TrimSuffix(name, ".")
+		report.PTR = append(report.PTR, DNSRecord{Name: reverseName(net.ParseIP(report.IP)), Type: "PTR", Value: name, TTL: -1})
+		check := NameCheck{Name: name}
+		owner := name
+		if cname, err := net.DefaultResolver.LookupCNAME(ctx, name); err == nil && strings.TrimSuffix(cname, ".") != name {
+			owner = strings.TrimSuffix(cname, ".")
+			check.Records = append(check.Records, DNSRecord{Name: name, Type: "CNAME", Value: owner, TTL: -1})
+		}
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			check.Err = err
+		}
+		for _, addr := range addrs {
+			rtype := "AAAA"
+			if addr.IP.To4() != nil {
+				rtype = "A"
+			}
+			check.Records = append(check.Records, DNSRecord{Name: owner, Type: rtype, Value: addr.IP.String(), TTL: -1})
+		}
+		check.confirm(report.IP)
+		report.Names = append(report.Names, check)
+	}
+	return nil
+}
+
+func (n *NameCheck) confirm(ip string) {
+	for _, rec := range n.Records {
+		if (rec.Type == "A" || rec.Type == "AAAA") && sameIP(rec.Value, ip) {
+			n.Confirmed = true
+		}
+	}
+}
+
+func sameIP(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	return ipA != nil && ipA.Equal(ipB)
+}
+
+func appendNew(records, more []DNSRecord) []DNSRecord {
+	for _, rec := range more {
+		dup := false
+		for _, have := range records {
+			if have.Name == rec.Name && have.Type == rec.Type && have.Value == rec.Value {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name of ip.
+func reverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0])
+	}
+	const hex = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		b.WriteByte(hex[ip[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hex[ip[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa")
+	return b.String()
+}
+
+type dnsClient struct {
+	server  string
+	timeout time.Duration
+}
+
+// resolve queries name and follows CNAMEs the server did not already
+// resolve in the same answer.
+func (c dnsClient) resolve(ctx context.Context, name string, qtype dnsmessage.Type) ([]DNSRecord, error) {
+	var records []DNSRecord
+	for range maxCNAMEs {
+		answer, err := c.query(ctx, name, qtype)
+		if err != nil {
+			return records, err
+		}
+		records = appendNew(records, answer)
+
+		target := name
+		for range maxCNAMEs {
+			next := ""
+			for _, rec := range answer {
+				if rec.Type == "CNAME" && strings.EqualFold(rec.Name, target) {
+					next = rec.Value
+				}
+			}
+			if next == "" {
+				break
+			}
+			target = next
+		}
+		resolved := target == name
+		for _, rec := range answer {
+			if rec.Type != "CNAME" && strings.EqualFold(rec.Name, target) {
+				resolved = true
+			}
+		}
+		if resolved {
+			return records, nil
+		}
+		name = target
+	}
+	return records, fmt.Errorf("CNAME chain of %s is longer than %d", name, maxCNAMEs)
+}
+
+// query sends one question over UDP, retrying over TCP when the answer is
+// truncated. NXDOMAIN is an empty answer, not an error.
+func (c dnsClient) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]DNSRecord, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Uint32())
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.exchange(ctx, "udp", packed, id)
+	if err == nil && resp.Truncated {
+		resp, err = c.exchange(ctx, "tcp", packed, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+	default:
+		return nil, fmt.Errorf("%s %s: server answered %s", name, strings.TrimPrefix(qtype.String(), "Type"), strings.TrimPrefix(resp.RCode.String(), "RCode"))
+	}
+
+	var records []DNSRecord
+	for _, rr := range resp.Answers {
+		rec := DNSRecord{Name: strings.TrimSuffix(rr.Header.Name.String(), "."), TTL: int(rr.Header.TTL)}
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			rec.Type, rec.Value = "A", net.IP(body.A[:]).String()
+		case *dnsmessage.AAAAResource:
+			rec.Type, rec.Value = "AAAA", net.IP(body.AAAA[:]).String()
+		case *dnsmessage.CNAMEResource:
+			rec.Type, rec.Value = "CNAME", strings.TrimSuffix(body.CNAME.String(), ".")
+		case *dnsmessage.PTRResource:
+			rec.Type, rec.Value = "PTR", strings.TrimSuffix(body.PTR.String(), ".")
+		default:
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func (c dnsClient) exchange(ctx context.Context, network string, packed []byte, id uint16) (*dnsmessage.Message, error) {
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = DefaultDNSTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, c.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	// Unblock reads as soon as the caller gives up.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if network == "tcp" {
+		// DNS over TCP prefixes every message with its length.
+		packed = append(binary.BigEndian.AppendUint16(nil, uint16(len(packed))), packed...)
+	}
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		var n int
+		if network == "tcp" {
+			if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+				return nil, err
+			}
+			n, err = io.ReadFull(conn, buf[:binary.BigEndian.Uint16(buf[:2])])
+		} else {
+			n, err = conn.Read(buf)
+		}
+		if err != nil {
+			if ctx.Err() != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, fmt.Errorf("no answer from %s within %s", c.server, timeout)
+			}
+			return nil, err
+		}
+		var resp dnsmessage.Message
+		if err := resp.Unpack(buf[:n]); err != nil || resp.ID != id || !resp.Response {
+			// A stray or spoofed datagram; keep waiting for ours.
+			continue
+		}
+		return &resp, nil
+	}
+}
@@ -0,0 +1,279 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsServer is a local stand-in nameserver answering on the same port over
+// UDP and TCP. Answers are keyed by "name type", e.g. "host.example. A";
+// unknown names get NXDOMAIN.
+type dnsServer struct {
+	addr        string
+	answers     map[string][]dnsmessage.Resource
+	truncateUDP map[string]bool // Names answered only over TCP
+	tcpQueries  atomic.Int32
+}
+
+func startDNSServer(t *testing.T, answers map[string][]dnsmessage.Resource, truncateUDP ...string) *dnsServer {
+	t.Helper()
+	s := &dnsServer{answers: answers, truncateUDP: make(map[string]bool)}
+	for _, name := range truncateUDP {
+		s.truncateUDP[name] = true
+	}
+
+	var udp net.PacketConn
+	var tcp net.Listener
+	for range 10 {
+		var err error
+		if udp, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if tcp, err = net.Listen("tcp", udp.LocalAddr().String()); err == nil {
+			break
+		}
+		udp.Close()
+		udp = nil
+	}
+	if udp == nil {
+		t.Fatal("no port free for both UDP and TCP")
+	}
+	s.addr = udp.LocalAddr().String()
+	t.Cleanup(func() {
+		udp.Close()
+		tcp.Close()
+	})
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := s.answer(buf[:n], true); resp != nil {
+				_, _ = udp.WriteTo(resp, from)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			s.tcpQueries.Add(1)
+			go func() {
+				defer conn.Close()
+				var size [2]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				if resp := s.answer(query, false); resp != nil {
+					_, _ = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *dnsServer) answer(query []byte, udp bool) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+	q := msg.Questions[0]
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: msg.ID, Response: true, RecursionAvailable: true},
+		Questions: msg.Questions,
+	}
+	name := strings.ToLower(q.Name.String())
+	answers, ok := s.answers[name+" "+strings.TrimPrefix(q.Type.String(), "Type")]
+	switch {
+	case udp && s.truncateUDP[name]:
+		resp.Truncated = true
+	case ok:
+		resp.Answers = answers
+	case !s.knows(name):
+		resp.RCode = dnsmessage.RCodeNameError
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return packed
+}
+
+// knows reports whether name has records of any type, which makes the
+// answer for a missing type NOERROR rather than NXDOMAIN.
+func (s *dnsServer) knows(name string) bool {
+	for key := range s.answers {
+		if strings.HasPrefix(key, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func rrHeader(name string, rtype dnsmessage.Type, ttl uint32) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: rtype, Class: dnsmessage.ClassINET, TTL: ttl}
+}
+
+func ptrRR(name, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{Header: rrHeader(name, dnsmessage.TypePTR, 3600),
+		Body: &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(target)}}
+}
+
+func cnameRR(name, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{Header: rrHeader(name, dnsmessage.TypeCNAME, 300),
+		Body: &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)}}
+}
+
+func aRR(name, ip string) dnsmessage.Resource {
+	var a [4]byte
+	copy(a[:], net.ParseIP(ip).To4())
+	return dnsmessage.Resource{Header: rrHeader(name, dnsmessage.TypeA, 60), Body: &dnsmessage.AResource{A: a}}
+}
+
+func aaaaRR(name, ip string) dnsmessage.Resource {
+	var a [16]byte
+	copy(a[:], net.ParseIP(ip).To16())
+	return dnsmessage.Resource{Header: rrHeader(name, dnsmessage.TypeAAAA, 60), Body: &dnsmessage.AAAAResource{AAAA: a}}
+}
+
+func testResolver(s *dnsServer) Resolver {
+	return Resolver{Server: s.addr, Timeout: 2 * time.Second}
+}
+
+func TestInspectForwardConfirms(t *testing.T) {
+	// 192.0.2.7 has two names: www follows a two-step CNAME chain, where the
+	// server leaves the first step for the client to follow, back to the IP;
+	// mail points elsewhere.
+	s := startDNSServer(t, map[string][]dnsmessage.Resource{
+		"7.2.0.192.in-addr.arpa. PTR": {
+			ptrRR("7.2.0.192.in-addr.arpa.", "www.example."),
+			ptrRR("7.2.0.192.in-addr.arpa.", "mail.example."),
+		},
+		"www.example. A":    {cnameRR("www.example.", "edge.example.")},
+		"www.example. AAAA": {cnameRR("www.example.", "edge.example.")},
+		"edge.example. A":   {cnameRR("edge.example.", "cdn.example."), aRR("cdn.example.", "192.0.2.7")},
+		"edge.example. AAAA": {cnameRR("edge.example.", "cdn.example."),
+			aaaaRR("cdn.example.", "2001:db8::7")},
+		"mail.example. A": {aRR("mail.example.", "198.51.100.25")},
+	})
+
+	report, err := testResolver(s).Inspect(context.Background(), "192.0.2.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Server != s.addr {
+		t.Errorf("Server = %q, want %q", report.Server, s.addr)
+	}
+	if len(report.PTR) != 2 || report.PTR[0].Value != "www.example" || report.PTR[0].TTL != 3600 {
+		t.Fatalf("PTR = %+v", report.PTR)
+	}
+	if len(report.Names) != 2 {
+		t.Fatalf("Names = %+v", report.Names)
+	}
+
+	www := report.Names[0]
+	if www.Err != nil || !www.Confirmed {
+		t.Errorf("www.example: confirmed = %v, err = %v", www.Confirmed, www.Err)
+	}
+	var chain []string
+	for _, rec := range www.Records {
+		chain = append(chain, rec.Name+" "+rec.Type+" "+rec.Value)
+	}
+	want := []string{
+		"www.example CNAME edge.example",
+		"edge.example CNAME cdn.example",
+		"cdn.example A 192.0.2.7",
+		"cdn.example AAAA 2001:db8::7",
+	}
+	if !slices.Equal(chain, want) {
+		t.Errorf("www.example records:\n%s\nwant\n%s", strings.Join(chain, "\n"), strings.Join(want, "\n"))
+	}
+
+	mail := report.Names[1]
+	if mail.Err != nil || mail.Confirmed {
+		t.Errorf("mail.example: confirmed = %v, err = %v", mail.Confirmed, mail.Err)
+	}
+
+	rows := report.Table()
+	if rows[0][4] != "forward-confirmed" || rows[1][4] != "not forward-confirmed" {
+		t.Errorf("PTR rows = %q", rows[:2])
+	}
+}
+
+func TestCNAMELoop(t *testing.T) {
+	s := startDNSServer(t, map[string][]dnsmessage.Resource{
+		"a.example. A": {cnameRR("a.example.", "b.example.")},
+		"b.example. A": {cnameRR("b.example.", "a.example.")},
+	})
+	client := dnsClient{server: s.addr, timeout: 2 * time.Second}
+	if _, err := client.resolve(context.Background(), "a.example", dnsmessage.TypeA); err == nil ||
+		!strings.Contains(err.Error(), "CNAME chain") {
+		t.Errorf("err = %v, want a CNAME chain error", err)
+	}
+}
+
+func TestTruncatedAnswerRetriesOverTCP(t *testing.T) {
+	s := startDNSServer(t, map[string][]dnsmessage.Resource{
+		"big.example. A": {aRR("big.example.", "192.0.2.1"), aRR("big.example.", "192.0.2.2")},
+	}, "big.example.")
+	client := dnsClient{server: s.addr, timeout: 2 * time.Second}
+
+	records, err := client.query(context.Background(), "big.example", dnsmessage.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Value != "192.0.2.2" {
+		t.Errorf("records = %+v", records)
+	}
+	if n := s.tcpQueries.Load(); n != 1 {
+		t.Errorf("%d TCP queries, want 1", n)
+	}
+}
+
+func TestNXDomainIsEmpty(t *testing.T) {
+	s := startDNSServer(t, map[string][]dnsmessage.Resource{})
+
+	report, err := testResolver(s).Inspect(context.Background(), "192.0.2.99")
+	if err != nil {
+		t.Fatalf("NXDOMAIN returned an error: %v", err)
+	}
+	if len(report.PTR) != 0 || len(report.Names) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+
+	records, err := dnsClient{server: s.addr, timeout: 2 * time.Second}.query(context.Background(), "gone.example", dnsmessage.TypeA)
+	if err != nil || len(records) != 0 {
+		t.Errorf("query = %+v, %v", records, err)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.7":   "7.2.0.192.in-addr.arpa",
+		"2001:db8::1": "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+	}
+	for ip, want := range tests {
+		if got := reverseName(net.ParseIP(ip)); got != want {
+			t.Errorf("reverseName(%s) = %s, want %s", ip, got, want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package probe implements BatStat's network diagnostics natively, so they
+// neither depend on system binaries nor on parsing their localised output.
+package probe
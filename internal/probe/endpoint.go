@@ -0,0 +1,219 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// EndpointOptions configure the TLS and HTTP checks.
+type EndpointOptions struct {
+	// ServerName is sent as SNI and Host header and verified against the
+	// certificate; empty uses the address itself.
+	ServerName string
+	// RootCAs replaces the system roots, e.g. for a test server.
+	RootCAs *x509.CertPool
+	Timeout time.Duration
+}
+
+func (o EndpointOptions) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return 2 * DefaultConnectTimeout
+	}
+	return o.Timeout
+}
+
+type CertInfo struct {
+	Subject   string
+	Issuer    string
+	SANs      []string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+type TLSReport struct {
+	Addr        string
+	ServerName  string
+	Version     string
+	CipherSuite string
+	ALPN        string
+	Chain       []CertInfo
+	// VerifyErr explains why the chain is not trusted for ServerName; nil
+	// when it verified.
+	VerifyErr error
+	Connect   time.Duration
+	Handshake time.Duration
+}
+
+// CheckTLS connects to addr and performs a TLS handshake. The certificate
+// is verified separately, so an untrusted or expired one is reported in
+// VerifyErr rather than failing the check.
+func CheckTLS(ctx context.Context, addr string, opts EndpointOptions) (TLSReport, error) {
+	report := TLSReport{Addr: addr, ServerName: opts.ServerName}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return report, err
+	}
+	name := opts.ServerName
+	if name == "" {
+		name = host
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout())
+	defer cancel()
+
+	start := time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return report, fmt.Errorf("connect: %s", connectError(err))
+	}
+	defer conn.Close()
+	report.Connect = time.Since(start)
+
+	cfg := &tls.Config{
+		ServerName:         opts.ServerName,
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true, // Verified below, to report rather than abort
+	}
+	tc := tls.Client(conn, cfg)
+	start = time.Now()
+	if err := tc.HandshakeContext(ctx); err != nil {
+		return report, fmt.Errorf("handshake: %w", err)
+	}
+	report.Handshake = time.Since(start)
+
+	state := tc.ConnectionState()
+	report.Version = tls.VersionName(state.Version)
+	report.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	report.ALPN = state.NegotiatedProtocol
+	for _, cert := range state.PeerCertificates {
+		sans := append([]string(nil), cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		report.Chain = append(report.Chain, CertInfo{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			SANs:      sans,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		})
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		report.VerifyErr = errors.New("no certificate presented")
+		return report, nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, report.VerifyErr = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       name,
+		Roots:         opts.RootCAs,
+		Intermediates: intermediates,
+	})
+	return report, nil
+}
+
+type HTTPReport struct {
+	URL      string
+	Proto    string
+	Status   string
+	Server   string
+	Location string
+	// Timings from the start of the request.
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+// CheckHTTP sends a HEAD request to addr, over TLS when useTLS is set, and
+// times its phases. Redirects are reported, not followed, and certificates
+// are not verified here; CheckTLS does that.
+func CheckHTTP(ctx context.Context, addr string, useTLS bool, opts EndpointOptions) (HTTPReport, error) {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	report := HTTPReport{URL: scheme + "://" + addr + "/"}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, report.URL, nil)
+	if err != nil {
+		return report, err
+	}
+	if opts.ServerName != "" {
+		req.Host = opts.ServerName
+	}
+	req.Header.Set("User-Agent", "BatStat")
+
+	var start, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			report.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			report.TLS = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() { report.FirstByte = time.Since(start) },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				ServerName:         opts.ServerName,
+				RootCAs:            opts.RootCAs,
+				InsecureSkipVerify: true,
+			},
+			DisableKeepAlives: true,
+			ForceAttemptHTTP2: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	defer client.CloseIdleConnections()
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return report, err
+	}
+	resp.Body.Close()
+	report.Total = time.Since(start)
+	report.Proto = resp.Proto
+	report.Status = resp.Status
+	report.Server = resp.Header.Get("Server")
+	report.Location = resp.Header.Get("Location")
+	return report, nil
+}
+
+// ExpiresIn describes how long the certificate stays valid.
+func (c CertInfo) ExpiresIn(now time.Time) string {
+	left := c.NotAfter.Sub(now)
+	switch {
+	case now.Before(c.NotBefore):
+		return "not valid yet"
+	case left < 0:
+		return fmt.Sprintf("expired %d days ago", int(-left.Hours()/24))
+	}
+	return fmt.Sprintf("%d days left", int(left.Hours()/24))
+}
+
+// TLSPorts and HTTPPorts preselect the follow-up checks by port number.
+var (
+	TLSPorts  = map[int]bool{443: true, 465: true, 636: true, 853: true, 993: true, 995: true, 8443: true}
+	HTTPPorts = map[int]bool{80: true, 443: true, 8000: true, 8080: true, 8443: true}
+)
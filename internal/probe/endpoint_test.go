@@ -0,0 +1,134 @@
+package probe
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTLSServer serves HTTPS with HTTP/2 enabled and returns the server
+// with a pool trusting its certificate. httptest certificates are valid
+// for example.com and 127.0.0.1.
+func startTLSServer(t *testing.T, handler http.Handler) (*httptest.Server, *x509.CertPool) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(handler)
+	srv.EnableHTTP2 = true
+	// CheckTLS hangs up after the handshake, which the server would log.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	return srv, roots
+}
+
+func TestCheckTLS(t *testing.T) {
+	srv, roots := startTLSServer(t, http.NotFoundHandler())
+	addr := srv.Listener.Addr().String()
+	ctx := context.Background()
+
+	report, err := CheckTLS(ctx, addr, EndpointOptions{RootCAs: roots, Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.VerifyErr != nil {
+		t.Errorf("VerifyErr = %v, want a trusted chain", report.VerifyErr)
+	}
+	if !strings.HasPrefix(report.Version, "TLS 1.") || report.CipherSuite == "" {
+		t.Errorf("Version = %q, CipherSuite = %q", report.Version, report.CipherSuite)
+	}
+	if report.ALPN != "h2" {
+		t.Errorf("ALPN = %q, want h2", report.ALPN)
+	}
+	if len(report.Chain) != 1 {
+		t.Fatalf("Chain = %+v", report.Chain)
+	}
+	leaf := report.Chain[0]
+	if !slices.Contains(leaf.SANs, "example.com") || !slices.Contains(leaf.SANs, "127.0.0.1") {
+		t.Errorf("SANs = %q", leaf.SANs)
+	}
+	if !strings.HasSuffix(leaf.ExpiresIn(time.Now()), "days left") {
+		t.Errorf("ExpiresIn = %q", leaf.ExpiresIn(time.Now()))
+	}
+
+	// A trusted certificate for another name, and one from an unknown CA,
+	// are reported rather than failing the check.
+	report, err = CheckTLS(ctx, addr, EndpointOptions{ServerName: "other.test", RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.VerifyErr == nil || !strings.Contains(report.VerifyErr.Error(), "other.test") {
+		t.Errorf("wrong name: VerifyErr = %v", report.VerifyErr)
+	}
+	report, err = CheckTLS(ctx, addr, EndpointOptions{ServerName: "example.com", RootCAs: x509.NewCertPool()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.VerifyErr == nil || !strings.Contains(report.VerifyErr.Error(), "unknown authority") {
+		t.Errorf("unknown CA: VerifyErr = %v", report.VerifyErr)
+	}
+}
+
+func TestCheckTLSPlainServer(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if _, err := CheckTLS(context.Background(), srv.Listener.Addr().String(), EndpointOptions{}); err == nil ||
+		!strings.HasPrefix(err.Error(), "handshake:") {
+		t.Errorf("err = %v, want a handshake error", err)
+	}
+}
+
+func TestCheckHTTP(t *testing.T) {
+	var gotMethod, gotHost, gotAgent string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotHost, gotAgent = r.Method, r.Host, r.UserAgent()
+		w.Header().Set("Server", "stand-in")
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	report, err := CheckHTTP(context.Background(), addr, false, EndpointOptions{ServerName: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotMethod != http.MethodHead || gotHost != "example.com" || gotAgent != "BatStat" {
+		t.Errorf("request = %s Host %q User-Agent %q", gotMethod, gotHost, gotAgent)
+	}
+	if report.URL != "http://"+addr+"/" || report.Proto != "HTTP/1.1" {
+		t.Errorf("URL = %q, Proto = %q", report.URL, report.Proto)
+	}
+	// The redirect is reported, not followed.
+	if report.Status != "302 Found" || report.Location != "/login" || report.Server != "stand-in" {
+		t.Errorf("Status = %q, Location = %q, Server = %q", report.Status, report.Location, report.Server)
+	}
+	if report.TLS != 0 || report.Total <= 0 || report.FirstByte > report.Total {
+		t.Errorf("timings: TLS %s, first byte %s, total %s", report.TLS, report.FirstByte, report.Total)
+	}
+}
+
+func TestCheckHTTPS(t *testing.T) {
+	srv, roots := startTLSServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	report, err := CheckHTTP(context.Background(), srv.Listener.Addr().String(), true, EndpointOptions{RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Proto != "HTTP/2.0" || report.Status != "204 No Content" {
+		t.Errorf("Proto = %q, Status = %q", report.Proto, report.Status)
+	}
+	if report.TLS <= 0 {
+		t.Errorf("TLS = %s, want the handshake timed", report.TLS)
+	}
+}
@@ -0,0 +1,355 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	DefaultPingSize     = 56
+	DefaultPingInterval = time.Second
+	DefaultPingTimeout  = 2 * time.Second
+	MaxPingSize         = 65000
+	// minPingInterval matches what ping(8) allows unprivileged users.
+	minPingInterval = 200 * time.Millisecond
+
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
+
+// ErrNotPermitted means neither datagram nor raw ICMP sockets are allowed.
+var ErrNotPermitted = errors.New("ICMP sockets are not permitted; run as root, grant CAP_NET_RAW or widen net.ipv4.ping_group_range")
+
+type PingOptions struct {
+	// Count is the number of echo requests; 0 pings until ctx is done.
+	Count    int
+	Interval time.Duration
+	// Size is the payload size in bytes, excluding the ICMP header.
+	Size    int
+	Timeout time.Duration
+}
+
+type PingReply struct {
+	Seq  int
+	From string
+	// Bytes is the ICMP message size, as ping(8) reports it.
+	Bytes int
+	TTL   int // -1 when the platform does not report it
+	RTT   time.Duration
+	Lost  bool
+}
+
+type PingStats struct {
+	Target    string
+	Addr      string
+	Sent      int
+	Received  int
+	Min       time.Duration
+	Avg       time.Duration
+	Max       time.Duration
+	Mdev      time.Duration
+	sum, sum2 float64
+}
+
+func (r PingReply) String() string {
+	if r.Lost {
+		return fmt.Sprintf("Request timeout for icmp_seq %d", r.Seq)
+	}
+	ttl := ""
+	if r.TTL >= 0 {
+		ttl = fmt.Sprintf(" ttl=%d", r.TTL)
+	}
+	return fmt.Sprintf("%d bytes from %s: icmp_seq=%d%s time=%s ms", r.Bytes, r.From, r.Seq, ttl, millis(r.RTT))
+}
+
+func (s PingStats) String() string {
+	return fmt.Sprintf("%d packets transmitted, %d received, %.1f%% packet loss", s.Sent, s.Received, s.Loss())
+}
+
+// RTTSummary formats the round-trip times like ping(8) does.
+func (s PingStats) RTTSummary() string {
+	if s.Received == 0 {
+		return "rtt min/avg/max/mdev = -/-/-/- ms"
+	}
+	return fmt.Sprintf("rtt min/avg/max/mdev = %s/%s/%s/%s ms", millis(s.Min), millis(s.Avg), millis(s.Max), millis(s.Mdev))
+}
+
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
+
+// Loss is the share of lost replies in percent.
+func (s PingStats) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) / float64(s.Sent) * 100
+}
+
+func (s *PingStats) add(rtt time.Duration) {
+	s.Received++
+	if s.Received == 1 || rtt < s.Min {
+		s.Min = rtt
+	}
+	if rtt > s.Max {
+		s.Max = rtt
+	}
+	f := float64(rtt)
+	s.sum += f
+	s.sum2 += f * f
+	avg := s.sum / float64(s.Received)
+	s.Avg = time.Duration(avg)
+	s.Mdev = time.Duration(math.Sqrt(math.Max(s.sum2/float64(s.Received)-avg*avg, 0)))
+}
+
+// Ping sends ICMP echo requests to target and calls onReply for every reply
+// or timeout, with the statistics so far. It prefers unprivileged datagram
+// sockets and falls back to raw sockets, which need root or CAP_NET_RAW.
+func Ping(ctx context.Context, target string, opts PingOptions, onReply func(PingReply, PingStats)) (PingStats, error) {
+	stats := PingStats{Target: target}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPingInterval
+	}
+	opts.Interval = max(opts.Interval, minPingInterval)
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultPingTimeout
+	}
+	if opts.Size < 0 || opts.Size > MaxPingSize {
+		return stats, fmt.Errorf("size must be between 0 and %d bytes", MaxPingSize)
+	}
+
+	ip, err := resolve(ctx, target)
+	if err != nil {
+		return stats, err
+	}
+	stats.Addr = ip.String()
+
+	conn, err := listenICMP(ip)
+	if err != nil {
+		return stats, err
+	}
+	defer conn.Close()
+
+	replies := make(chan PingReply)
+	go conn.read(ctx, replies)
+
+	payload := make([]byte, opts.Size)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	pending := make(map[int]time.Time)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	timeouts := time.NewTicker(opts.Timeout / 4)
+	defer timeouts.Stop()
+
+	send := func() error {
+		seq := (stats.Sent + 1) & 0xffff
+		msg := icmp.Message{Type: conn.echoType(), Body: &icmp.Echo{ID: conn.id, Seq: seq, Data: payload}}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			return err
+		}
+		pending[seq] = time.Now()
+		stats.Sent++
+		if _, err := conn.WriteTo(b, conn.addr(ip)); err != nil {
+			return fmt.Errorf("send to %s: %w", ip, err)
+		}
+		return nil
+	}
+	report := func(r PingReply) {
+		if onReply != nil {
+			onReply(r, stats)
+		}
+	}
+
+	if err := send(); err != nil {
+		return stats, err
+	}
+	for {
+		if opts.Count > 0 && stats.Sent >= opts.Count && len(pending) == 0 {
+			return stats, nil
+		}
+		select {
+		case <-ctx.Done():
+			return stats, nil
+		case <-ticker.C:
+			if opts.Count == 0 || stats.Sent < opts.Count {
+				if err := send(); err != nil {
+					return stats, err
+				}
+			}
+		case <-timeouts.C:
+			for seq, sent := range pending {
+				if time.Since(sent) > opts.Timeout {
+					delete(pending, seq)
+					report(PingReply{Seq: seq, Lost: true, TTL: -1})
+				}
+			}
+		case r := <-replies:
+			// Raw sockets see the replies of every ping; the echo ID
+			// and the source together pick out this one's.
+			if conn.raw && !sameIP(r.From, stats.Addr) {
+				continue
+			}
+			sent, ok := pending[r.Seq]
+			if !ok {
+				continue
+			}
+			delete(pending, r.Seq)
+			r.RTT = time.Since(sent)
+			stats.add(r.RTT)
+			report(r)
+		}
+	}
+}
+
+func resolve(ctx context.Context, target string) (net.IP, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		return ip, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip", target)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%s has no addresses", target)
+	}
+	return addrs[0], nil
+}
+
+type icmpConn struct {
+	*icmp.PacketConn
+	v6  bool
+	raw bool
+	id  int
+}
+
+var echoIDs atomic.Uint32
+
+// nextEchoID gives each raw socket its own echo ID, so concurrent pings and
+// traceroutes, as in a sweep, do not take each other's replies.
+func nextEchoID() int {
+	return int((uint32(os.Getpid()) + echoIDs.Add(1)) & 0xffff)
+}
+
+func listenICMP(ip net.IP) (*icmpConn, error) {
+	v6 := ip.To4() == nil
+	dgram, raw, laddr := "udp4", "ip4:icmp", "0.0.0.0"
+	if v6 {
+		dgram, raw, laddr = "udp6", "ip6:ipv6-icmp", "::"
+	}
+
+	c, err := icmp.ListenPacket(dgram, laddr)
+	if err == nil {
+		// The kernel sets the echo ID of datagram sockets itself.
+		conn := &icmpConn{PacketConn: c, v6: v6}
+		conn.enableTTL()
+		return conn, nil
+	}
+	c, rawErr := icmp.ListenPacket(raw, laddr)
+	if rawErr == nil {
+		conn := &icmpConn{PacketConn: c, v6: v6, raw: true, id: nextEchoID()}
+		conn.enableTTL()
+		return conn, nil
+	}
+	if errors.Is(rawErr, os.ErrPermission) {
+		return nil, ErrNotPermitted
+	}
+	return nil, fmt.Errorf("open ICMP socket: %w", rawErr)
+}
+
+func (c *icmpConn) enableTTL() {
+	// Not every platform reports the TTL; replies then show it as -1.
+	if c.v6 {
+		_ = c.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
+	} else {
+		_ = c.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
+	}
+}
+
+func (c *icmpConn) echoType() icmp.Type {
+	if c.v6 {
+		return ipv6.ICMPTypeEchoRequest
+	}
+	return ipv4.ICMPTypeEcho
+}
+
+func (c *icmpConn) addr(ip net.IP) net.Addr {
+	if c.raw {
+		return &net.IPAddr{IP: ip}
+	}
+	return &net.UDPAddr{IP: ip}
+}
+
+// read delivers echo replies until ctx is done or the socket is closed.
+// Datagram sockets only see replies to their own requests; raw sockets see
+// every ICMP packet, so those are matched on the echo ID.
+func (c *icmpConn) read(ctx context.Context, replies chan<- PingReply) {
+	buf := make([]byte, MaxPingSize+512)
+	proto, echoReply := protocolICMP, icmp.Type(ipv4.ICMPTypeEchoReply)
+	if c.v6 {
+		proto, echoReply = protocolICMPv6, ipv6.ICMPTypeEchoReply
+	}
+	for {
+		n, ttl, from, err := c.readFrom(buf)
+		if err != nil {
+			return
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || msg.Type != echoReply {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok || (c.raw && echo.ID != c.id) {
+			continue
+		}
+		reply := PingReply{Seq: echo.Seq, Bytes: n, TTL: ttl, From: hostOf(from)}
+		select {
+		case replies <- reply:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *icmpConn) readFrom(buf []byte) (n, ttl int, from net.Addr, err error) {
+	ttl = -1
+	if c.v6 {
+		var cm *ipv6.ControlMessage
+		n, cm, from, err = c.IPv6PacketConn().ReadFrom(buf)
+		if cm != nil {
+			ttl = cm.HopLimit
+		}
+		return n, ttl, from, err
+	}
+	var cm *ipv4.ControlMessage
+	n, cm, from, err = c.IPv4PacketConn().ReadFrom(buf)
+	if cm != nil {
+		ttl = cm.TTL
+	}
+	return n, ttl, from, err
+}
+
+func hostOf(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.IPAddr:
+		return a.IP.String()
+	case nil:
+		return ""
+	}
+	return addr.String()
+}
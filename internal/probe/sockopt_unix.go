@@ -0,0 +1,16 @@
+//go:build !windows
+
+package probe
+
+import "golang.org/x/sys/unix"
+
+// traceSupported reports whether raw ICMP sockets see the Time Exceeded
+// replies a traceroute depends on.
+const traceSupported = true
+
+func setTTL(fd uintptr, v6 bool, ttl int) error {
+	if v6 {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, ttl)
+	}
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL, ttl)
+}
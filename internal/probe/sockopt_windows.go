@@ -0,0 +1,9 @@
+package probe
+
+// Windows raw sockets do not deliver ICMP errors unless bound to an
+// interface in promiscuous mode, so the native traceroute is unavailable.
+const traceSupported = false
+
+func setTTL(fd uintptr, v6 bool, ttl int) error {
+	return ErrTraceUnsupported
+}
@@ -0,0 +1,85 @@
+package probe
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	DefaultSweepCount    = 3
+	DefaultSweepParallel = 32
+)
+
+type SweepOptions struct {
+	PingOptions
+	// TCP connects to "ip:port" targets instead of sending ICMP echoes.
+	TCP bool
+	// Parallel is how many targets are probed at once.
+	Parallel int
+}
+
+// SweepResult is the state of one target of a sweep.
+type SweepResult struct {
+	Stats PingStats
+	// Err is the most recent failure: a lost probe, a refused connect or
+	// an error that stopped probing the target altogether.
+	Err  string
+	Done bool
+}
+
+// Sweep probes every target, at most opts.Parallel at a time, and calls
+// onResult with the index of the target after every probe and once more
+// when it is done. onResult is called from several goroutines. Sweep
+// returns when every target is done or ctx is canceled.
+func Sweep(ctx context.Context, targets []string, opts SweepOptions, onResult func(int, SweepResult)) {
+	if opts.Parallel <= 0 {
+		opts.Parallel = DefaultSweepParallel
+	}
+	if opts.Count <= 0 {
+		opts.Count = DefaultSweepCount
+	}
+
+	sem := make(chan struct{}, opts.Parallel)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			onResult(i, sweepOne(ctx, target, opts, func(r SweepResult) { onResult(i, r) }))
+		}()
+	}
+	wg.Wait()
+}
+
+func sweepOne(ctx context.Context, target string, opts SweepOptions, progress func(SweepResult)) SweepResult {
+	var res SweepResult
+	var err error
+	if opts.TCP {
+		res.Stats, err = TCPing(ctx, target, opts.PingOptions, func(r ConnectResult, stats PingStats) {
+			res.Stats = stats
+			if r.Err != nil {
+				res.Err = connectError(r.Err)
+			}
+			progress(res)
+		})
+	} else {
+		res.Stats, err = Ping(ctx, target, opts.PingOptions, func(r PingReply, stats PingStats) {
+			res.Stats = stats
+			if r.Lost {
+				res.Err = "request timed out"
+			}
+			progress(res)
+		})
+	}
+	if err != nil {
+		res.Err = err.Error()
+	}
+	res.Done = true
+	return res
+}
@@ -0,0 +1,99 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const DefaultConnectTimeout = 3 * time.Second
+
+type ConnectResult struct {
+	Seq  int
+	Addr string
+	RTT  time.Duration
+	Err  error
+}
+
+func (r ConnectResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("seq=%d %s: %s", r.Seq, r.Addr, connectError(r.Err))
+	}
+	return fmt.Sprintf("seq=%d connected to %s time=%s ms", r.Seq, r.Addr, millis(r.RTT))
+}
+
+// TCPing opens a TCP connection to addr ("host:port") every interval and
+// reports how long each connect took; failed connects count as lost. It
+// works where ICMP is filtered, as long as the port answers. opts.Size is
+// ignored.
+func TCPing(ctx context.Context, addr string, opts PingOptions, onResult func(ConnectResult, PingStats)) (PingStats, error) {
+	stats := PingStats{Target: addr, Addr: addr}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return stats, err
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPingInterval
+	}
+	opts.Interval = max(opts.Interval, minPingInterval)
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultConnectTimeout
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		stats.Sent++
+		r := ConnectResult{Seq: stats.Sent, Addr: addr}
+		r.RTT, r.Err = connect(ctx, addr, opts.Timeout)
+		if ctx.Err() != nil {
+			stats.Sent--
+			return stats, nil
+		}
+		if r.Err == nil {
+			stats.add(r.RTT)
+		}
+		if onResult != nil {
+			onResult(r, stats)
+		}
+		if opts.Count > 0 && stats.Sent >= opts.Count {
+			return stats, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return stats, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func connect(ctx context.Context, addr string, timeout time.Duration) (time.Duration, error) {
+	d := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	rtt := time.Since(start)
+	if err != nil {
+		return rtt, err
+	}
+	conn.Close()
+	return rtt, nil
+}
+
+// connectError shortens the usual dial errors to what they mean.
+func connectError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused (port closed)"
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out (filtered?)"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable"
+	}
+	return err.Error()
+}
@@ -0,0 +1,80 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTCPingOpenPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var results []ConnectResult
+	stats, err := TCPing(context.Background(), ln.Addr().String(), PingOptions{Count: 2, Interval: minPingInterval},
+		func(r ConnectResult, _ PingStats) { results = append(results, r) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 2 || stats.Received != 2 || stats.Min <= 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(results) != 2 || results[1].Seq != 2 || results[1].Err != nil {
+		t.Fatalf("results = %+v", results)
+	}
+	if s := results[0].String(); !strings.HasPrefix(s, "seq=1 connected to "+ln.Addr().String()) {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestTCPingRefused(t *testing.T) {
+	// Take a free port and close it again, so nothing listens there.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var results []ConnectResult
+	stats, err := TCPing(context.Background(), addr, PingOptions{Count: 1, Timeout: time.Second},
+		func(r ConnectResult, _ PingStats) { results = append(results, r) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 1 || stats.Received != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("results = %+v", results)
+	}
+	if want := "seq=1 " + addr + ": refused (port closed)"; results[0].String() != want {
+		t.Errorf("String() = %q, want %q", results[0].String(), want)
+	}
+}
+
+func TestTCPingCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stats, err := TCPing(ctx, "127.0.0.1:9", PingOptions{}, nil)
+	if err != nil || stats.Sent != 0 {
+		t.Errorf("stats = %+v, err = %v", stats, err)
+	}
+	if _, err := TCPing(context.Background(), "127.0.0.1", PingOptions{}, nil); err == nil {
+		t.Error("no error for an address without a port")
+	}
+}
@@ -0,0 +1,46 @@
+package probe
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Lines formats the hop table as aligned text, like mtr's report mode.
+func (t Trace) Lines() []string {
+	rows := make([][]string, 0, len(t.Hops))
+	for _, h := range t.Hops {
+		if h.Received == 0 {
+			rows = append(rows, []string{fmt.Sprint(h.TTL), "*", fmt.Sprintf("%.0f", h.Loss()), fmt.Sprint(h.Sent), "", "", ""})
+			continue
+		}
+		rows = append(rows, []string{fmt.Sprint(h.TTL), h.Addr, fmt.Sprintf("%.0f", h.Loss()), fmt.Sprint(h.Sent),
+			fmt.Sprintf("%.1f", ms(h.Best)), fmt.Sprintf("%.1f", ms(h.Avg)), fmt.Sprintf("%.1f", ms(h.Worst))})
+	}
+	return textTable([]string{"HOP", "ADDRESS", "LOSS%", "SENT", "BEST", "AVG", "WORST"}, rows)
+}
+
+// Lines formats Table as aligned text.
+func (r DNSReport) Lines() []string {
+	return textTable([]string{"NAME", "TYPE", "TTL", "VALUE", "STATUS"}, r.Table())
+}
+
+func textTable(header []string, rows [][]string) []string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return lines
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,490 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	TraceICMP = "icmp"
+	TraceUDP  = "udp"
+	TraceTCP  = "tcp"
+
+	DefaultMaxHops = 30
+	// traceUDPPort is the first destination port of UDP probes, as in
+	// traceroute(8); each probe uses the next one to tell replies apart.
+	traceUDPPort  = 33434
+	traceUDPPorts = 1024
+)
+
+var TraceModes = []string{TraceICMP, TraceUDP, TraceTCP}
+
+var ErrTraceUnsupported = errors.New("native traceroute is not supported on this platform")
+
+type TraceOptions struct {
+	Mode string
+	// Port is the destination port of TCP probes.
+	Port    int
+	MaxHops int
+	// Timeout is how long a probe waits for its reply.
+	Timeout time.Duration
+	// Interval is the time between the starts of two rounds.
+	Interval time.Duration
+	// Rounds is the number of probes per hop; 0 traces until ctx is done.
+	Rounds int
+}
+
+type Hop struct {
+	TTL      int
+	Addr     string // Last address that answered
+	Sent     int
+	Received int
+	Last     time.Duration
+	Best     time.Duration
+	Worst    time.Duration
+	Avg      time.Duration
+	// End marks the last hop: the target, or a router reporting it as
+	// unreachable.
+	End bool
+	sum time.Duration
+}
+
+func (h Hop) Loss() float64 {
+	if h.Sent == 0 {
+		return 0
+	}
+	return float64(h.Sent-h.Received) / float64(h.Sent) * 100
+}
+
+func (h *Hop) add(addr string, rtt time.Duration) {
+	h.Addr = addr
+	h.Received++
+	h.Last = rtt
+	if h.Received == 1 || rtt < h.Best {
+		h.Best = rtt
+	}
+	h.Worst = max(h.Worst, rtt)
+	h.sum += rtt
+	h.Avg = h.sum / time.Duration(h.Received)
+}
+
+type Trace struct {
+	Target string
+	Addr   string
+	Round  int
+	// Reached is set once the target itself answered.
+	Reached bool
+	Hops    []Hop
+}
+
+// Traceroute probes every hop towards target with increasing TTLs, round
+// after round like mtr, and calls onUpdate with a snapshot whenever a reply
+// comes in. Replies are read from a raw ICMP socket, which needs root or
+// CAP_NET_RAW. TCP probes are ordinary connects with a low TTL, so a reached
+// target sees a complete, immediately closed connection.
+func Traceroute(ctx context.Context, target string, opts TraceOptions, onUpdate func(Trace)) (Trace, error) {
+	trace := Trace{Target: target}
+	if !traceSupported {
+		return trace, ErrTraceUnsupported
+	}
+	if opts.Mode == "" {
+		opts.Mode = TraceICMP
+	}
+	if opts.Mode == TraceTCP && (opts.Port <= 0 || opts.Port > 65535) {
+		return trace, errors.New("TCP traceroute needs a destination port")
+	}
+	if opts.MaxHops <= 0 {
+		opts.MaxHops = DefaultMaxHops
+	}
+	opts.MaxHops = min(opts.MaxHops, 255)
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultPingTimeout
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPingInterval
+	}
+
+	ip, err := resolve(ctx, target)
+	if err != nil {
+		return trace, err
+	}
+	trace.Addr = ip.String()
+
+	t, err := newTracer(ip, opts)
+	if err != nil {
+		return trace, err
+	}
+	defer t.close()
+
+	results := make(chan traceResult, 64)
+	go t.read(ctx, results)
+
+	trace.Hops = make([]Hop, opts.MaxHops)
+	for i := range trace.Hops {
+		trace.Hops[i].TTL = i + 1
+	}
+	limit := opts.MaxHops
+	snapshot := func() Trace {
+		s := trace
+		s.Hops = append([]Hop(nil), trace.Hops[:limit]...)
+		return s
+	}
+	update := func() {
+		if onUpdate != nil {
+			onUpdate(snapshot())
+		}
+	}
+
+	for round := 1; opts.Rounds == 0 || round <= opts.Rounds; round++ {
+		trace.Round = round
+		start := time.Now()
+		pending := make(map[int]probeInfo)
+		// Spread the probes a little; routers rate-limit their ICMP errors.
+		gap := min(opts.Interval/time.Duration(limit), 20*time.Millisecond)
+		for ttl := 1; ttl <= limit; ttl++ {
+			key, err := t.send(ctx, ttl, results)
+			if err != nil {
+				return snapshot(), err
+			}
+			pending[key] = probeInfo{ttl: ttl, sent: time.Now()}
+			trace.Hops[ttl-1].Sent++
+			if !sleep(ctx, gap) {
+				return snapshot(), nil
+			}
+		}
+		update()
+
+		timeout := time.NewTimer(opts.Timeout)
+	wait:
+		for len(pending) > 0 {
+			select {
+			case <-ctx.Done():
+				timeout.Stop()
+				return snapshot(), nil
+			case <-timeout.C:
+				break wait
+			case r := <-results:
+				probe, ok := pending[r.key]
+				if !ok {
+					continue
+				}
+				delete(pending, r.key)
+				if probe.ttl > limit {
+					continue
+				}
+				hop := &trace.Hops[probe.ttl-1]
+				hop.add(r.addr, r.at.Sub(probe.sent))
+				if r.end {
+					hop.End = true
+					trace.Reached = trace.Reached || r.addr == trace.Addr
+					limit = probe.ttl
+				}
+				update()
+			}
+		}
+		timeout.Stop()
+		update()
+
+		if !sleep(ctx, opts.Interval-time.Since(start)) {
+			break
+		}
+	}
+	return snapshot(), nil
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+type probeInfo struct {
+	ttl  int
+	sent time.Time
+}
+
+type traceResult struct {
+	key  int
+	addr string
+	end  bool
+	at   time.Time
+}
+
+// tracer sends the probes of one mode and matches the ICMP replies, which
+// quote the start of the probe, back to them by a per-probe key: the echo
+// sequence, the UDP destination port or the TCP source port.
+type tracer struct {
+	dst  net.IP
+	v6   bool
+	opts TraceOptions
+	icmp *icmp.PacketConn
+	udp  net.PacketConn
+	id   int
+	seq  int
+}
+
+func newTracer(dst net.IP, opts TraceOptions) (*tracer, error) {
+	t := &tracer{dst: dst, v6: dst.To4() == nil, opts: opts, id: nextEchoID()}
+	network, laddr := "ip4:icmp", "0.0.0.0"
+	if t.v6 {
+		network, laddr = "ip6:ipv6-icmp", "::"
+	}
+	c, err := icmp.ListenPacket(network, laddr)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return nil, ErrNotPermitted
+		}
+		return nil, fmt.Errorf("open ICMP socket: %w", err)
+	}
+	t.icmp = c
+
+	if opts.Mode == TraceUDP {
+		network := "udp4"
+		if t.v6 {
+			network = "udp6"
+		}
+		if t.udp, err = net.ListenPacket(network, ":0"); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *tracer) close() {
+	t.icmp.Close()
+	if t.udp != nil {
+		t.udp.Close()
+	}
+}
+
+// send fires one probe with the given TTL and returns its key. TCP probes
+// report reaching the target on results themselves.
+func (t *tracer) send(ctx context.Context, ttl int, results chan<- traceResult) (int, error) {
+	t.seq++
+	switch t.opts.Mode {
+	case TraceUDP:
+		port := traceUDPPort + t.seq%traceUDPPorts
+		var err error
+		if t.v6 {
+			err = ipv6.NewPacketConn(t.udp).SetHopLimit(ttl)
+		} else {
+			err = ipv4.NewPacketConn(t.udp).SetTTL(ttl)
+		}
+		if err != nil {
+			return 0, err
+		}
+		_, err = t.udp.WriteTo(make([]byte, 32), &net.UDPAddr{IP: t.dst, Port: port})
+		return port, ignoreUnreachable(err)
+
+	case TraceTCP:
+		port := 33000 + rand.IntN(28000)
+		go t.connect(ctx, ttl, port, results)
+		return port, nil
+
+	default:
+		seq := t.seq & 0xffff
+		echoType, err := icmp.Type(ipv4.ICMPTypeEcho), error(nil)
+		if t.v6 {
+			echoType = ipv6.ICMPTypeEchoRequest
+			err = t.icmp.IPv6PacketConn().SetHopLimit(ttl)
+		} else {
+			err = t.icmp.IPv4PacketConn().SetTTL(ttl)
+		}
+		if err != nil {
+			return 0, err
+		}
+		msg := icmp.Message{Type: echoType, Body: &icmp.Echo{ID: t.id, Seq: seq, Data: make([]byte, 32)}}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			return 0, err
+		}
+		_, err = t.icmp.WriteTo(b, &net.IPAddr{IP: t.dst})
+		return seq, ignoreUnreachable(err)
+	}
+}
+
+// ignoreUnreachable drops send errors caused by earlier ICMP errors, which
+// the reader reports anyway.
+func ignoreUnreachable(err error) error {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return nil
+	}
+	return err
+}
+
+func (t *tracer) connect(ctx context.Context, ttl, port int, results chan<- traceResult) {
+	d := net.Dialer{
+		LocalAddr: &net.TCPAddr{Port: port},
+		Timeout:   t.opts.Timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) { err = setTTL(fd, t.v6, ttl) }); cerr != nil {
+				return cerr
+			}
+			return err
+		},
+	}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(t.dst.String(), fmt.Sprint(t.opts.Port)))
+	at := time.Now()
+	if err == nil {
+		conn.Close()
+	} else if !errors.Is(err, syscall.ECONNREFUSED) {
+		// Intermediate hops answer over ICMP.
+		return
+	}
+	select {
+	case results <- traceResult{key: port, addr: t.dst.String(), end: true, at: at}:
+	case <-ctx.Done():
+	}
+}
+
+func (t *tracer) read(ctx context.Context, results chan<- traceResult) {
+	proto := protocolICMP
+	timeExceeded, unreachable, echoReply := icmp.Type(ipv4.ICMPTypeTimeExceeded), icmp.Type(ipv4.ICMPTypeDestinationUnreachable), icmp.Type(ipv4.ICMPTypeEchoReply)
+	if t.v6 {
+		proto = protocolICMPv6
+		timeExceeded, unreachable, echoReply = ipv6.ICMPTypeTimeExceeded, ipv6.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeEchoReply
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := t.icmp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		at := time.Now()
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+
+		r := traceResult{addr: hostOf(from), at: at}
+		ok := false
+		switch body := msg.Body.(type) {
+		case *icmp.TimeExceeded:
+			if msg.Type == timeExceeded {
+				r.key, ok = t.match(body.Data)
+			}
+		case *icmp.DstUnreach:
+			if msg.Type == unreachable {
+				r.key, ok = t.match(body.Data)
+				r.end = true
+			}
+		case *icmp.Echo:
+			if msg.Type == echoReply && t.opts.Mode == TraceICMP && body.ID == t.id {
+				r.key, ok, r.end = body.Seq, true, true
+			}
+		}
+		if !ok {
+			continue
+		}
+		select {
+		case results <- r:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// match finds the key of the probe quoted in an ICMP error: the original IP
+// header followed by at least 8 bytes of its payload.
+func (t *tracer) match(data []byte) (int, bool) {
+	var proto int
+	var dst net.IP
+	var l4 []byte
+	if t.v6 {
+		if len(data) < 48 || data[0]>>4 != 6 {
+			return 0, false
+		}
+		proto, dst, l4 = int(data[6]), net.IP(data[24:40]), data[40:]
+	} else {
+		if len(data) < 28 || data[0]>>4 != 4 {
+			return 0, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if len(data) < ihl+8 {
+			return 0, false
+		}
+		proto, dst, l4 = int(data[9]), net.IP(data[16:20]), data[ihl:]
+	}
+	if !dst.Equal(t.dst) {
+		return 0, false
+	}
+
+	switch t.opts.Mode {
+	case TraceUDP:
+		if proto != syscall.IPPROTO_UDP || int(binary.BigEndian.Uint16(l4[0:2])) != t.udp.LocalAddr().(*net.UDPAddr).Port {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint16(l4[2:4])), true
+	case TraceTCP:
+		if proto != syscall.IPPROTO_TCP {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint16(l4[0:2])), true
+	default:
+		if (proto != protocolICMP && proto != protocolICMPv6) || int(binary.BigEndian.Uint16(l4[4:6])) != t.id {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint16(l4[6:8])), true
+	}
+}
+
+// LookupASN returns the origin AS of ip as "AS15169 GOOGLE", using the Team
+// Cymru IP-to-ASN DNS service. This sends the address to a third party.
+func LookupASN(ctx context.Context, ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return "", nil
+	}
+	zone := "origin.asn.cymru.com"
+	if addr.To4() == nil {
+		zone = "origin6.asn.cymru.com"
+	}
+	name := strings.TrimSuffix(reverseName(addr), ".in-addr.arpa")
+	name = strings.TrimSuffix(name, ".ip6.arpa")
+
+	txts, err := net.DefaultResolver.LookupTXT(ctx, name+"."+zone)
+	if err != nil || len(txts) == 0 {
+		return "", err
+	}
+	// "15169 | 8.8.8.0/24 | US | arin | 2023-12-28"
+	asn := strings.TrimSpace(strings.Split(txts[0], "|")[0])
+	if i := strings.IndexByte(asn, ' '); i > 0 {
+		asn = asn[:i] // Multi-origin prefixes list several ASNs
+	}
+	if asn == "" {
+		return "", nil
+	}
+
+	label := "AS" + asn
+	// "15169 | US | arin | 2000-03-30 | GOOGLE - Google LLC, US"
+	if txts, err := net.DefaultResolver.LookupTXT(ctx, "AS"+asn+".asn.cymru.com"); err == nil && len(txts) > 0 {
+		fields := strings.Split(txts[0], "|")
+		if owner := strings.TrimSpace(fields[len(fields)-1]); owner != "" {
+			label += " " + strings.TrimSpace(strings.SplitN(owner, " - ", 2)[0])
+		}
+	}
+	return label, nil
+}
@@ -0,0 +1,216 @@
+// Package route reads the kernel routing tables and policy rules, and asks
+// the kernel which route it would pick for a destination. It is backed by
+// rtnetlink and only works on Linux.
+package route
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrUnsupported = errors.New("routing tables are only available on Linux")
+
+// Well-known table IDs.
+const (
+	TableDefault = 253
+	TableMain    = 254
+	TableLocal   = 255
+)
+
+// Route is one routing table entry, or the result of a lookup.
+type Route struct {
+	Family   string // IPv4 or IPv6
+	Table    uint32
+	Dst      netip.Prefix // 0.0.0.0/0 or ::/0 for the default route
+	Gateway  netip.Addr   // Invalid for directly connected routes
+	Iface    string
+	Src      netip.Addr // Preferred source address, when set
+	Metric   uint32
+	Protocol string // Who installed it: kernel, boot, static, dhcp, ...
+	Scope    string // global, link, host, ...
+	Type     string // unicast, local, blackhole, unreachable, ...
+	Nexthops []Nexthop
+}
+
+// Nexthop is one path of a multipath route.
+type Nexthop struct {
+	Gateway netip.Addr
+	Iface   string
+	Weight  int
+}
+
+// IsDefault reports whether r matches every destination of its family.
+func (r Route) IsDefault() bool {
+	return r.Dst.IsValid() && r.Dst.Bits() == 0
+}
+
+// Destination formats Dst as ip route does, with "default" for /0.
+func (r Route) Destination() string {
+	switch {
+	case !r.Dst.IsValid():
+		return "-"
+	case r.IsDefault():
+		return "default"
+	case r.Dst.IsSingleIP():
+		return r.Dst.Addr().String()
+	}
+	return r.Dst.String()
+}
+
+// Via describes where packets go: the gateway and interface, one per path
+// for multipath routes.
+func (r Route) Via() string {
+	hops := r.Nexthops
+	if len(hops) == 0 {
+		hops = []Nexthop{{Gateway: r.Gateway, Iface: r.Iface}}
+	}
+	parts := make([]string, 0, len(hops))
+	for _, h := range hops {
+		var s []string
+		if h.Gateway.IsValid() {
+			s = append(s, "via "+h.Gateway.String())
+		}
+		if h.Iface != "" {
+			s = append(s, "dev "+h.Iface)
+		}
+		if h.Weight > 1 {
+			s = append(s, "weight "+strconv.Itoa(h.Weight))
+		}
+		parts = append(parts, strings.Join(s, " "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// String formats r like one line of ip route.
+func (r Route) String() string {
+	s := []string{r.Destination()}
+	if r.Type != "" && r.Type != "unicast" {
+		s = []string{r.Type, r.Destination()}
+	}
+	if via := r.Via(); via != "" {
+		s = append(s, via)
+	}
+	if r.Table != 0 && r.Table != TableMain {
+		s = append(s, "table "+TableName(r.Table))
+	}
+	if r.Protocol != "" {
+		s = append(s, "proto "+r.Protocol)
+	}
+	if r.Scope != "" && r.Scope != "global" {
+		s = append(s, "scope "+r.Scope)
+	}
+	if r.Src.IsValid() {
+		s = append(s, "src "+r.Src.String())
+	}
+	if r.Metric != 0 {
+		s = append(s, "metric "+strconv.FormatUint(uint64(r.Metric), 10))
+	}
+	return strings.Join(s, " ")
+}
+
+// Rule is one policy routing rule.
+type Rule struct {
+	Family   string
+	Priority uint32
+	Invert   bool
+	Src      netip.Prefix // Invalid matches any source
+	Dst      netip.Prefix
+	IIF, OIF string
+	FwMark   uint32
+	FwMask   uint32
+	TOS      uint8
+	Action   string // lookup, goto, nop, blackhole, unreachable, prohibit
+	Table    uint32 // For lookup
+	Goto     uint32 // Target priority for goto
+}
+
+// Selector formats what the rule matches, like ip rule.
+func (r Rule) Selector() string {
+	var s []string
+	if r.Invert {
+		s = append(s, "not")
+	}
+	if r.Src.IsValid() && r.Src.Bits() > 0 {
+		s = append(s, "from "+r.Src.String())
+	} else {
+		s = append(s, "from all")
+	}
+	if r.Dst.IsValid() && r.Dst.Bits() > 0 {
+		s = append(s, "to "+r.Dst.String())
+	}
+	if r.TOS != 0 {
+		s = append(s, fmt.Sprintf("tos %#x", r.TOS))
+	}
+	if r.FwMark != 0 || r.FwMask != 0 {
+		mark := fmt.Sprintf("fwmark %#x", r.FwMark)
+		if r.FwMask != 0xffffffff {
+			mark += fmt.Sprintf("/%#x", r.FwMask)
+		}
+		s = append(s, mark)
+	}
+	if r.IIF != "" {
+		s = append(s, "iif "+r.IIF)
+	}
+	if r.OIF != "" {
+		s = append(s, "oif "+r.OIF)
+	}
+	return strings.Join(s, " ")
+}
+
+// Target formats the rule's action.
+func (r Rule) Target() string {
+	switch r.Action {
+	case "lookup":
+		return "lookup " + TableName(r.Table)
+	case "goto":
+		return "goto " + strconv.FormatUint(uint64(r.Goto), 10)
+	}
+	return r.Action
+}
+
+var (
+	tableNamesOnce sync.Once
+	tableNames     = map[uint32]string{
+		TableDefault: "default",
+		TableMain:    "main",
+		TableLocal:   "local",
+	}
+)
+
+// TableName returns the name of a table from iproute2's rt_tables, or its
+// number when it has none.
+func TableName(id uint32) string {
+	tableNamesOnce.Do(loadTableNames)
+	if name, ok := tableNames[id]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func loadTableNames() {
+	for _, path := range []string{"/etc/iproute2/rt_tables", "/usr/share/iproute2/rt_tables", "/usr/lib/iproute2/rt_tables"} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			if id, err := strconv.ParseUint(fields[0], 0, 32); err == nil {
+				tableNames[uint32(id)] = fields[1]
+			}
+		}
+		f.Close()
+		return
+	}
+}
@@ -0,0 +1,367 @@
+package route
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"syscall"
+
+	"github.com/MrBrooks89/BatStat/internal/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Attributes of struct fib_rule_hdr messages (linux/fib_rules.h).
+const (
+	fraDst      = 1
+	fraSrc      = 2
+	fraIIFName  = 3
+	fraGoto     = 4
+	fraPriority = 6
+	fraFwMark   = 10
+	fraTable    = 15
+	fraFwMask   = 16
+	fraOIFName  = 17
+)
+
+// Routes dumps the IPv4 and IPv6 routes of every table.
+func Routes() ([]Route, error) {
+	var routes []Route
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		msgs, err := netlink.Request(unix.NETLINK_ROUTE, unix.RTM_GETROUTE, unix.NLM_F_DUMP, rtMsg(family, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		names := ifaceNames()
+		for _, m := range msgs {
+			if r, ok := parseRoute(m, family, names); ok {
+				routes = append(routes, r)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// Rules dumps the IPv4 and IPv6 policy routing rules.
+func Rules() ([]Rule, error) {
+	var rules []Rule
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		msgs, err := netlink.Request(unix.NETLINK_ROUTE, unix.RTM_GETRULE, unix.NLM_F_DUMP, rtMsg(family, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if r, ok := parseRule(m, family); ok {
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// Lookup asks the kernel which route it would use to reach dst from src, as
+// ip route get does. src may be invalid or unspecified; policy rules that
+// match on the source address are only honoured when it is given. The
+// result is the matching table entry, with Src set to the source address the
+// kernel would pick when the entry has none.
+func Lookup(dst, src netip.Addr) (Route, error) {
+	dst = dst.Unmap()
+	src = src.Unmap()
+	if src.IsValid() && (src.IsUnspecified() || src.Is4() != dst.Is4()) {
+		src = netip.Addr{}
+	}
+
+	// The plain lookup fills in the chosen source address; RTM_F_FIB_MATCH
+	// (Linux 4.13) returns the table entry itself, with its prefix and metric.
+	chosen, err := lookup(dst, src, unix.RTM_F_LOOKUP_TABLE)
+	if err != nil && src.IsValid() {
+		// The local address may have gone away with the socket.
+		src = netip.Addr{}
+		chosen, err = lookup(dst, src, unix.RTM_F_LOOKUP_TABLE)
+	}
+	if err != nil {
+		return Route{}, err
+	}
+	entry, err := lookup(dst, src, unix.RTM_F_LOOKUP_TABLE|unix.RTM_F_FIB_MATCH)
+	if err != nil {
+		return chosen, nil
+	}
+	if !entry.Src.IsValid() {
+		entry.Src = chosen.Src
+	}
+	if len(entry.Nexthops) > 0 {
+		// Multipath: Gateway and Iface are the path this destination takes.
+		entry.Gateway, entry.Iface = chosen.Gateway, chosen.Iface
+	}
+	return entry, nil
+}
+
+func lookup(dst, src netip.Addr, flags uint32) (Route, error) {
+	family := uint8(unix.AF_INET6)
+	if dst.Is4() {
+		family = unix.AF_INET
+	}
+	req := rtMsg(family, uint8(dst.BitLen()), flags)
+	req = netlink.AppendAttr(req, unix.RTA_DST, dst.AsSlice())
+	if src.IsValid() {
+		req[2] = uint8(src.BitLen())
+		req = netlink.AppendAttr(req, unix.RTA_SRC, src.AsSlice())
+	}
+	msgs, err := netlink.Request(unix.NETLINK_ROUTE, unix.RTM_GETROUTE, 0, req)
+	if err != nil {
+		return Route{}, err
+	}
+	for _, m := range msgs {
+		if r, ok := parseRoute(m, family, ifaceNames()); ok {
+			return r, nil
+		}
+	}
+	return Route{}, errors.New("no route returned")
+}
+
+// rtMsg builds a struct rtmsg; struct fib_rule_hdr has the same layout.
+func rtMsg(family, dstLen uint8, flags uint32) []byte {
+	b := make([]byte, unix.SizeofRtMsg)
+	b[0] = family
+	b[1] = dstLen
+	binary.NativeEndian.PutUint32(b[8:12], flags)
+	return b
+}
+
+// parseRoute reads a struct rtmsg reply to a request for family.
+func parseRoute(m syscall.NetlinkMessage, family uint8, names map[int]string) (Route, bool) {
+	b := m.Data
+	if m.Header.Type != unix.RTM_NEWROUTE || len(b) < unix.SizeofRtMsg {
+		return Route{}, false
+	}
+	// Some kernels leave rtm_family unset on default routes.
+	if b[0] != unix.AF_UNSPEC {
+		family = b[0]
+	}
+	r := Route{
+		Family:   familyName(family),
+		Table:    uint32(b[4]),
+		Protocol: protocolName(b[5]),
+		Scope:    scopeName(b[6]),
+		Type:     typeName(b[7]),
+	}
+	if r.Family == "" {
+		return Route{}, false
+	}
+	dstLen := int(b[1])
+	dst := unspecified(family)
+
+	for _, attr := range netlink.ParseAttrs(b[unix.SizeofRtMsg:]) {
+		switch attr.Type {
+		case unix.RTA_DST:
+			if addr, ok := netip.AddrFromSlice(attr.Value); ok {
+				dst = addr
+			}
+		case unix.RTA_GATEWAY:
+			r.Gateway, _ = netip.AddrFromSlice(attr.Value)
+		case unix.RTA_VIA:
+			r.Gateway = parseVia(attr.Value)
+		case unix.RTA_OIF:
+			r.Iface = ifaceName(names, attr.Value)
+		case unix.RTA_PREFSRC:
+			r.Src, _ = netip.AddrFromSlice(attr.Value)
+		case unix.RTA_PRIORITY:
+			if len(attr.Value) >= 4 {
+				r.Metric = binary.NativeEndian.Uint32(attr.Value)
+			}
+		case unix.RTA_TABLE:
+			if len(attr.Value) >= 4 {
+				r.Table = binary.NativeEndian.Uint32(attr.Value)
+			}
+		case unix.RTA_MULTIPATH:
+			r.Nexthops = parseMultipath(attr.Value, names)
+		}
+	}
+	r.Dst = netip.PrefixFrom(dst, dstLen)
+	return r, true
+}
+
+// parseMultipath walks the struct rtnexthop entries of RTA_MULTIPATH.
+func parseMultipath(b []byte, names map[int]string) []Nexthop {
+	var hops []Nexthop
+	for len(b) >= unix.SizeofRtNexthop {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < unix.SizeofRtNexthop || l > len(b) {
+			break
+		}
+		hop := Nexthop{
+			Iface:  ifaceName(names, b[4:8]),
+			Weight: int(b[3]) + 1,
+		}
+		for _, attr := range netlink.ParseAttrs(b[unix.SizeofRtNexthop:l]) {
+			switch attr.Type {
+			case unix.RTA_GATEWAY:
+				hop.Gateway, _ = netip.AddrFromSlice(attr.Value)
+			case unix.RTA_VIA:
+				hop.Gateway = parseVia(attr.Value)
+			}
+		}
+		hops = append(hops, hop)
+		b = b[min((l+unix.RTA_ALIGNTO-1)&^(unix.RTA_ALIGNTO-1), len(b)):]
+	}
+	return hops
+}
+
+// parseVia reads a struct rtvia: a gateway of another family, such as an
+// IPv6 next hop for an IPv4 route.
+func parseVia(b []byte) netip.Addr {
+	if len(b) < 2 {
+		return netip.Addr{}
+	}
+	addr, _ := netip.AddrFromSlice(b[2:])
+	return addr
+}
+
+func parseRule(m syscall.NetlinkMessage, family uint8) (Rule, bool) {
+	b := m.Data
+	if m.Header.Type != unix.RTM_NEWRULE || len(b) < unix.SizeofRtMsg {
+		return Rule{}, false
+	}
+	if b[0] != unix.AF_UNSPEC {
+		family = b[0]
+	}
+	r := Rule{
+		Family: familyName(family),
+		TOS:    b[3],
+		Table:  uint32(b[4]),
+		Action: actionName(b[7]),
+		Invert: binary.NativeEndian.Uint32(b[8:12])&unix.FIB_RULE_INVERT != 0,
+	}
+	if r.Family == "" {
+		return Rule{}, false
+	}
+	dstLen, srcLen := int(b[1]), int(b[2])
+
+	for _, attr := range netlink.ParseAttrs(b[unix.SizeofRtMsg:]) {
+		var u32 uint32
+		if len(attr.Value) >= 4 {
+			u32 = binary.NativeEndian.Uint32(attr.Value)
+		}
+		switch attr.Type {
+		case fraDst:
+			if addr, ok := netip.AddrFromSlice(attr.Value); ok {
+				r.Dst = netip.PrefixFrom(addr, dstLen)
+			}
+		case fraSrc:
+			if addr, ok := netip.AddrFromSlice(attr.Value); ok {
+				r.Src = netip.PrefixFrom(addr, srcLen)
+			}
+		case fraIIFName:
+			r.IIF = cString(attr.Value)
+		case fraOIFName:
+			r.OIF = cString(attr.Value)
+		case fraGoto:
+			r.Goto = u32
+		case fraPriority:
+			r.Priority = u32
+		case fraFwMark:
+			r.FwMark = u32
+		case fraFwMask:
+			r.FwMask = u32
+		case fraTable:
+			r.Table = u32
+		}
+	}
+	return r, true
+}
+
+func familyName(f uint8) string {
+	switch f {
+	case unix.AF_INET:
+		return "IPv4"
+	case unix.AF_INET6:
+		return "IPv6"
+	}
+	return ""
+}
+
+// unspecified is the address of a default route, which carries no RTA_DST.
+func unspecified(family uint8) netip.Addr {
+	if family == unix.AF_INET {
+		return netip.IPv4Unspecified()
+	}
+	return netip.IPv6Unspecified()
+}
+
+// ifaceNames maps interface indexes to names for one batch of messages.
+func ifaceNames() map[int]string {
+	names := make(map[int]string)
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		names[iface.Index] = iface.Name
+	}
+	return names
+}
+
+func ifaceName(names map[int]string, b []byte) string {
+	if len(b) < 4 {
+		return ""
+	}
+	index := int(int32(binary.NativeEndian.Uint32(b)))
+	if name, ok := names[index]; ok {
+		return name
+	}
+	return "if" + strconv.Itoa(index)
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+var protocols = map[uint8]string{
+	1: "redirect", 2: "kernel", 3: "boot", 4: "static", 8: "gated", 9: "ra", 10: "mrt",
+	11: "zebra", 12: "bird", 13: "dnrouted", 14: "xorp", 15: "ntk", 16: "dhcp", 17: "mrouted",
+	18: "keepalived", 42: "babel", 99: "openr", 186: "bgp", 187: "isis", 188: "ospf", 189: "rip", 192: "eigrp",
+}
+
+func protocolName(p uint8) string {
+	if name, ok := protocols[p]; ok {
+		return name
+	}
+	return strconv.Itoa(int(p))
+}
+
+func scopeName(s uint8) string {
+	switch s {
+	case 0:
+		return "global"
+	case 200:
+		return "site"
+	case 253:
+		return "link"
+	case 254:
+		return "host"
+	case 255:
+		return "nowhere"
+	}
+	return strconv.Itoa(int(s))
+}
+
+var routeTypes = []string{"unspec", "unicast", "local", "broadcast", "anycast", "multicast",
+	"blackhole", "unreachable", "prohibit", "throw", "nat", "xresolve"}
+
+func typeName(t uint8) string {
+	if int(t) < len(routeTypes) {
+		return routeTypes[t]
+	}
+	return strconv.Itoa(int(t))
+}
+
+var ruleActions = map[uint8]string{1: "lookup", 2: "goto", 3: "nop", 6: "blackhole", 7: "unreachable", 8: "prohibit"}
+
+func actionName(a uint8) string {
+	if name, ok := ruleActions[a]; ok {
+		return name
+	}
+	return strconv.Itoa(int(a))
+}
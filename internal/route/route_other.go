@@ -0,0 +1,17 @@
+//go:build !linux
+
+package route
+
+import "net/netip"
+
+func Routes() ([]Route, error) {
+	return nil, ErrUnsupported
+}
+
+func Rules() ([]Rule, error) {
+	return nil, ErrUnsupported
+}
+
+func Lookup(dst, src netip.Addr) (Route, error) {
+	return Route{}, ErrUnsupported
+}
@@ -0,0 +1,36 @@
+package sockdiag
+
+import (
+	"errors"
+	"net"
+	"strconv"
+)
+
+var ErrUnsupported = errors.New("socket diagnostics are only available on Linux")
+
+// Socket is one TCP socket as reported by the kernel's inet_diag interface.
+type Socket struct {
+	Family  string
+	State   uint8
+	SrcIP   net.IP
+	SrcPort uint16
+	DstIP   net.IP
+	DstPort uint16
+	UID     uint32
+	Inode   uint32
+	// BytesSent and BytesReceived come from tcp_info (bytes_acked and
+	// bytes_received) and are only set when HasCounters is true.
+	BytesSent     uint64
+	BytesReceived uint64
+	HasCounters   bool
+}
+
+// Key formats the socket the same way models.Connection formats its
+// addresses so the two can be joined.
+func (s Socket) Key() string {
+	return AddrKey(s.SrcIP.String()+":"+strconv.Itoa(int(s.SrcPort)), s.DstIP.String()+":"+strconv.Itoa(int(s.DstPort)))
+}
+
+func AddrKey(laddr, raddr string) string {
+	return laddr + "|" + raddr
+}
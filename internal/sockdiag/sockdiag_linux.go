@@ -0,0 +1,133 @@
+package sockdiag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/MrBrooks89/BatStat/internal/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	inetDiagReqLen = 56
+	inetDiagMsgLen = 72
+	inetDiagInfo   = 2
+
+	tcpInfoBytesAcked    = 120
+	tcpInfoBytesReceived = 128
+)
+
+// TCPSockets dumps every IPv4 and IPv6 TCP socket in the current network
+// namespace, including the tcp_info byte counters.
+func TCPSockets() ([]Socket, error) {
+	var sockets []Socket
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		req := diagRequest(family, unix.IPPROTO_TCP, 0xffffffff, 1<<(inetDiagInfo-1))
+		msgs, err := netlink.Request(unix.NETLINK_SOCK_DIAG, unix.SOCK_DIAG_BY_FAMILY, unix.NLM_F_DUMP, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if s, ok := parseDiagMsg(m.Data); ok {
+				sockets = append(sockets, s)
+			}
+		}
+	}
+	return sockets, nil
+}
+
+// DestroyTCP closes one TCP socket with SOCK_DESTROY, as "ss -K" does. The
+// owning process sees the connection fail with ECONNABORTED.
+func DestroyTCP(src net.IP, sport uint16, dst net.IP, dport uint16) error {
+	family := uint8(unix.AF_INET6)
+	if src.To4() != nil && dst.To4() != nil {
+		family = unix.AF_INET
+	}
+	err := destroy(family, src, sport, dst, dport)
+	if family == unix.AF_INET && errors.Is(err, unix.ENOENT) {
+		// IPv4 peers of a dual-stack socket live in the AF_INET6 table as
+		// v4-mapped addresses.
+		err = destroy(unix.AF_INET6, src, sport, dst, dport)
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.EOPNOTSUPP):
+		return fmt.Errorf("kernel lacks socket destroy support (CONFIG_INET_DIAG_DESTROY): %w", err)
+	case errors.Is(err, unix.EPERM), errors.Is(err, unix.EACCES):
+		return fmt.Errorf("closing sockets needs root or CAP_NET_ADMIN: %w", err)
+	case errors.Is(err, unix.ENOENT):
+		return fmt.Errorf("socket is already gone: %w", err)
+	}
+	return err
+}
+
+func destroy(family uint8, src net.IP, sport uint16, dst net.IP, dport uint16) error {
+	req := diagRequest(family, unix.IPPROTO_TCP, 0xffffffff, 0)
+	putSockID(req[8:], family, src, sport, dst, dport)
+	_, err := netlink.Request(unix.NETLINK_SOCK_DIAG, unix.SOCK_DESTROY, unix.NLM_F_ACK, req)
+	return err
+}
+
+// putSockID fills a struct inet_diag_sockid. Ports and addresses are in
+// network byte order; the cookie is INET_DIAG_NOCOOKIE.
+func putSockID(b []byte, family uint8, src net.IP, sport uint16, dst net.IP, dport uint16) {
+	binary.BigEndian.PutUint16(b[0:2], sport)
+	binary.BigEndian.PutUint16(b[2:4], dport)
+	if family == unix.AF_INET {
+		copy(b[4:8], src.To4())
+		copy(b[20:24], dst.To4())
+	} else {
+		copy(b[4:20], src.To16())
+		copy(b[20:36], dst.To16())
+	}
+	binary.NativeEndian.PutUint32(b[40:44], 0xffffffff)
+	binary.NativeEndian.PutUint32(b[44:48], 0xffffffff)
+}
+
+// diagRequest builds a struct inet_diag_req_v2 with a zero socket id.
+func diagRequest(family, protocol uint8, states uint32, ext uint8) []byte {
+	b := make([]byte, inetDiagReqLen)
+	b[0] = family
+	b[1] = protocol
+	b[2] = ext
+	binary.NativeEndian.PutUint32(b[4:8], states)
+	return b
+}
+
+func parseDiagMsg(b []byte) (Socket, bool) {
+	if len(b) < inetDiagMsgLen {
+		return Socket{}, false
+	}
+
+	s := Socket{
+		State:   b[1],
+		SrcPort: binary.BigEndian.Uint16(b[4:6]),
+		DstPort: binary.BigEndian.Uint16(b[6:8]),
+		UID:     binary.NativeEndian.Uint32(b[64:68]),
+		Inode:   binary.NativeEndian.Uint32(b[68:72]),
+	}
+	switch b[0] {
+	case unix.AF_INET:
+		s.Family = "IPv4"
+		s.SrcIP = net.IP(append([]byte(nil), b[8:12]...))
+		s.DstIP = net.IP(append([]byte(nil), b[24:28]...))
+	case unix.AF_INET6:
+		s.Family = "IPv6"
+		s.SrcIP = net.IP(append([]byte(nil), b[8:24]...))
+		s.DstIP = net.IP(append([]byte(nil), b[24:40]...))
+	default:
+		return Socket{}, false
+	}
+
+	for _, attr := range netlink.ParseAttrs(b[inetDiagMsgLen:]) {
+		if attr.Type == inetDiagInfo && len(attr.Value) >= tcpInfoBytesReceived+8 {
+			s.BytesSent = binary.NativeEndian.Uint64(attr.Value[tcpInfoBytesAcked:])
+			s.BytesReceived = binary.NativeEndian.Uint64(attr.Value[tcpInfoBytesReceived:])
+			s.HasCounters = true
+		}
+	}
+	return s, true
+}
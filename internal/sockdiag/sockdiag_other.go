@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sockdiag
+
+import "net"
+
+func TCPSockets() ([]Socket, error) {
+	return nil, ErrUnsupported
+}
+
+func DestroyTCP(src net.IP, sport uint16, dst net.IP, dport uint16) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,198 @@
+// Package timeseries keeps aggregates of recent connection snapshots in a
+// fixed-size ring, so trends such as a climbing CLOSE_WAIT count can be
+// graphed without storing the snapshots themselves.
+package timeseries
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+const (
+	DefaultWindow = 30 * time.Minute
+	// keepPerSample bounds the process and remote host counts kept per
+	// sample; the rest only add to the total.
+	keepPerSample = 50
+)
+
+// Dimension is one way of grouping a snapshot's connections.
+type Dimension int
+
+const (
+	ByState Dimension = iota
+	ByProtocol
+	ByProcess
+	ByRemote
+)
+
+func (d Dimension) String() string {
+	return [...]string{"States", "Protocols", "Processes", "Remote hosts"}[d]
+}
+
+// Sample aggregates one snapshot.
+type Sample struct {
+	Time   time.Time
+	Total  int
+	Counts [4]map[string]int // Indexed by Dimension
+}
+
+// Aggregate counts conns by state, protocol, process and remote address.
+func Aggregate(conns []models.Connection, now time.Time) Sample {
+	s := Sample{Time: now, Total: len(conns)}
+	for d := range s.Counts {
+		s.Counts[d] = make(map[string]int)
+	}
+	for _, c := range conns {
+		s.Counts[ByState][cmp.Or(c.Status, "NONE")]++
+		s.Counts[ByProtocol][protocol(c)]++
+		name := cmp.Or(c.ProcessName, "-")
+		if c.Host != "" {
+			name += "@" + c.Host
+		}
+		s.Counts[ByProcess][name]++
+		if c.HasRemote() {
+			s.Counts[ByRemote][c.RemoteIP()]++
+		}
+	}
+	s.Counts[ByProcess] = largest(s.Counts[ByProcess], keepPerSample)
+	s.Counts[ByRemote] = largest(s.Counts[ByRemote], keepPerSample)
+	return s
+}
+
+func protocol(c models.Connection) string {
+	switch c.Family {
+	case "IPv4":
+		return c.Type + "4"
+	case "IPv6":
+		return c.Type + "6"
+	case "Unix":
+		return "Unix"
+	}
+	return c.Type
+}
+
+func largest(counts map[string]int, n int) map[string]int {
+	if len(counts) <= n {
+		return counts
+	}
+	kept := make(map[string]int, n)
+	for _, k := range rank(counts)[:n] {
+		kept[k] = counts[k]
+	}
+	return kept
+}
+
+// rank orders keys by count, largest first, then by name.
+func rank(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	return keys
+}
+
+// Ring holds the samples of the last window. It is safe for concurrent use.
+type Ring struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	samples []Sample
+	head    int // Oldest sample once the ring is full
+}
+
+// NewRing sizes the ring for one sample per interval over window.
+func NewRing(window, interval time.Duration) *Ring {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	size := max(int(window/interval)+1, 2)
+	return &Ring{window: window, samples: make([]Sample, 0, size)}
+}
+
+func (r *Ring) Window() time.Duration { return r.window }
+
+func (r *Ring) Add(s Sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.samples) < cap(r.samples) {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.head] = s
+	r.head = (r.head + 1) % len(r.samples)
+}
+
+// Samples returns the samples within the window, oldest first.
+func (r *Ring) Samples() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := append(slices.Clone(r.samples[r.head:]), r.samples[:r.head]...)
+	if len(all) == 0 {
+		return nil
+	}
+	// Refreshes can stall, e.g. while agents time out, so the ring may
+	// reach back further than the window.
+	since := all[len(all)-1].Time.Add(-r.window)
+	i, _ := slices.BinarySearchFunc(all, since, func(s Sample, t time.Time) int { return s.Time.Compare(t) })
+	return all[i:]
+}
+
+// Series is one key's count across samples.
+type Series struct {
+	Key    string
+	Values []float64
+}
+
+// Last is the most recent value.
+func (s Series) Last() float64 {
+	if len(s.Values) == 0 {
+		return 0
+	}
+	return s.Values[len(s.Values)-1]
+}
+
+// Total returns the total connection count of each sample.
+func Total(samples []Sample) Series {
+	s := Series{Key: "Total", Values: make([]float64, len(samples))}
+	for i, sample := range samples {
+		s.Values[i] = float64(sample.Total)
+	}
+	return s
+}
+
+// Top returns the series of the n keys of d with the highest count in the
+// latest sample, ties going to the highest peak over all samples. Keys
+// missing from a sample count as zero there.
+func Top(samples []Sample, d Dimension, n int) []Series {
+	if len(samples) == 0 {
+		return nil
+	}
+	last := samples[len(samples)-1].Counts[d]
+	peak := make(map[string]int)
+	for _, sample := range samples {
+		for k, v := range sample.Counts[d] {
+			peak[k] = max(peak[k], v)
+		}
+	}
+	keys := rank(peak)
+	slices.SortStableFunc(keys, func(a, b string) int { return cmp.Compare(last[b], last[a]) })
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+
+	series := make([]Series, len(keys))
+	for i, k := range keys {
+		series[i] = Series{Key: k, Values: make([]float64, len(samples))}
+		for j, sample := range samples {
+			series[i].Values[j] = float64(sample.Counts[d][k])
+		}
+	}
+	return series
+}
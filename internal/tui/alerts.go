@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/alerts"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/rivo/tview"
+)
+
+const (
+	alertPaneHeight = 8
+	alertLogSize    = 100
+)
+
+// evaluateAlerts runs the rules against the full snapshot and starts the
+// exec and webhook notifications. UI updates are left to the caller.
+func (a *App) evaluateAlerts(conns []models.Connection) (alerts.Result, bool) {
+	if a.alerts.Empty() {
+		return alerts.Result{}, false
+	}
+
+	res := a.alerts.Evaluate(conns, time.Now())
+	a.state.SetHighlights(res.Highlighted)
+
+	for _, event := range res.Events {
+		if !event.Has(alerts.ActionExec) && !event.Has(alerts.ActionWebhook) {
+			continue
+		}
+		go func(event alerts.Alert) {
+			if err := a.alerts.Dispatch(event); err != nil {
+				a.tviewApp.QueueUpdateDraw(func() {
+					a.view.SetStatusMessage(err.Error())
+				})
+			}
+		}(event)
+	}
+	return res, true
+}
+
+func (v *View) showAlertResult(res alerts.Result) {
+	a := v.app
+	a.active = res.Active
+
+	bell := false
+	for _, event := range res.Events {
+		a.alertLog = append(a.alertLog, event)
+		if event.State != alerts.StateFiring {
+			continue
+		}
+		if event.Has(alerts.ActionBell) {
+			bell = true
+		}
+		if event.Has(alerts.ActionPane) {
+			v.setAlertPaneVisible(true)
+		}
+		v.SetStatusMessage("[red]ALERT[white] " + tview.Escape(event.Message))
+	}
+	if len(a.alertLog) > alertLogSize {
+		a.alertLog = a.alertLog[len(a.alertLog)-alertLogSize:]
+	}
+
+	if bell && a.screen != nil {
+		_ = a.screen.Beep()
+	}
+	v.renderAlertPane()
+}
+
+func (v *View) renderAlertPane() {
+	var b strings.Builder
+	if len(v.app.active) == 0 {
+		b.WriteString("[green]No active alerts[white]\n")
+	}
+	for _, active := range v.app.active {
+		fmt.Fprintf(&b, "[red::b]FIRING[-::-] %s  [gray]since %s[white]\n",
+			tview.Escape(active.Message), active.Since.Format("15:04:05"))
+	}
+
+	if len(v.app.alertLog) > 0 {
+		b.WriteString("[gray]── history ──[white]\n")
+	}
+	for i := len(v.app.alertLog) - 1; i >= 0; i-- {
+		event := v.app.alertLog[i]
+		color := "red"
+		if event.State == alerts.StateResolved {
+			color = "green"
+		}
+		fmt.Fprintf(&b, "[gray]%s[white] [%s]%-8s[white] %s\n",
+			event.Time.Format("15:04:05"), color, event.State, tview.Escape(event.Message))
+	}
+
+	v.alertView.SetTitle(fmt.Sprintf(" Alerts (%d active) ", len(v.app.active)))
+	v.alertView.SetText(b.String())
+	v.alertView.ScrollToBeginning()
+}
+
+func (v *View) setAlertPaneVisible(show bool) {
+	height := 0
+	if show {
+		height = alertPaneHeight
+	}
+	v.layout.ResizeItem(v.alertView, height, 0)
+}
+
+func (v *View) toggleAlertPane() {
+	if v.app.alerts.Empty() {
+		v.SetStatusMessage("No alert rules configured.")
+		return
+	}
+	_, _, _, height := v.alertView.GetRect()
+	v.setAlertPaneVisible(height == 0)
+	v.renderAlertPane()
+}
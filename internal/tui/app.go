@@ -1,32 +1,177 @@
 package tui
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/agent"
+	"github.com/MrBrooks89/BatStat/internal/alerts"
 	"github.com/MrBrooks89/BatStat/internal/conn"
+	"github.com/MrBrooks89/BatStat/internal/firewall"
+	"github.com/MrBrooks89/BatStat/internal/history"
+	"github.com/MrBrooks89/BatStat/internal/intel"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/neigh"
+	"github.com/MrBrooks89/BatStat/internal/probe"
+	"github.com/MrBrooks89/BatStat/internal/sockdiag"
+	"github.com/MrBrooks89/BatStat/internal/timeseries"
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
+type Options struct {
+	// Agents are remote hosts shown alongside (or instead of) this one.
+	Agents []*agent.Client
+	// NoLocal hides this machine's own connections.
+	NoLocal bool
+	Alerts  *alerts.Engine
+	// Intel flags connections to addresses on the configured blocklists.
+	Intel *intel.Watcher
+	// Kill holds the defaults of the kill dialog; KillTree preselects
+	// killing child processes too.
+	Kill     actions.KillOptions
+	KillTree bool
+	// Diagnostics are the user-defined tools, run alongside the built-ins.
+	Diagnostics []actions.Diagnostic
+	// History stores diagnostic runs per remote address; nil disables it.
+	History *history.Store
+	// StatsWindow is how far back the trends pane reaches; StatsTop is
+	// how many keys each of its columns shows.
+	StatsWindow time.Duration
+	StatsTop    int
+}
+
+const refreshInterval = 3 * time.Second
+
 type App struct {
-	tviewApp *tview.Application
-	view     *View
-	state    *AppState
+	tviewApp  *tview.Application
+	view      *View
+	state     *AppState
+	localHost string
+	noLocal   bool
+	agents    map[string]*agent.Client
+	agentList []*agent.Client
+	alerts    *alerts.Engine
+	alertLog  []alerts.Alert // Only touched on the UI goroutine
+	active    []alerts.Alert
+	intel     *intel.Watcher
+	screen    tcell.Screen
+
+	firewall   *firewall.Firewall
+	expiryOnce sync.Once
+
+	killOptions actions.KillOptions
+	killTree    bool
+	diagnostics []actions.Diagnostic // Built-ins first
+	history     *history.Store       // nil when disabled
+	pingOptions probe.PingOptions    // Last settings of the ping dialog
+	tcping      probe.PingOptions    // Last settings of the TCP probe dialog
+	dnsServer   string               // Last nameserver of the DNS panel
+	trace       probe.TraceOptions
+	traceASN    bool
+	sweep       probe.SweepOptions
+	trends      *timeseries.Ring
+	trendTop    int
+
+	// Set by each refresh for the summary header and details pane
+	hostInfo     models.HostInfo
+	fetchTime    time.Duration
+	refreshed    time.Time
+	neighbors    []neigh.Neighbor
+	neighborDups map[string][]netip.Addr
 }
 
-func NewApp() *App {
+func NewApp(opts Options) *App {
 	a := &App{
-		tviewApp: tview.NewApplication(),
-		state:    NewAppState(),
+		tviewApp:  tview.NewApplication(),
+		state:     NewAppState(),
+		noLocal:   opts.NoLocal,
+		agents:    make(map[string]*agent.Client),
+		agentList: opts.Agents,
+		alerts:    opts.Alerts,
+		intel:     opts.Intel,
+		firewall:  firewall.New(firewall.ExecRunner{}),
+
+		killOptions: opts.Kill,
+		killTree:    opts.KillTree,
+		diagnostics: append(slices.Clone(actions.BuiltinDiagnostics), opts.Diagnostics...),
+		history:     opts.History,
+		pingOptions: probe.PingOptions{Interval: probe.DefaultPingInterval, Size: probe.DefaultPingSize},
+		tcping:      probe.PingOptions{Interval: probe.DefaultPingInterval},
+		trace:       probe.TraceOptions{Mode: probe.TraceICMP, MaxHops: probe.DefaultMaxHops},
+		sweep: probe.SweepOptions{
+			PingOptions: probe.PingOptions{Count: probe.DefaultSweepCount, Interval: 200 * time.Millisecond},
+			Parallel:    probe.DefaultSweepParallel,
+		},
+		trends:   timeseries.NewRing(opts.StatsWindow, refreshInterval),
+		trendTop: opts.StatsTop,
 	}
-	a.view = NewView(a) 
+	if a.killOptions.Grace <= 0 {
+		a.killOptions.Grace = actions.DefaultKillGrace
+	}
+	if a.trendTop <= 0 {
+		a.trendTop = DefaultTrendTop
+	}
+
+	var hosts []string
+	if len(opts.Agents) > 0 {
+		a.localHost = localHostName()
+		if !opts.NoLocal {
+			hosts = append(hosts, a.localHost)
+		}
+	}
+	for _, c := range opts.Agents {
+		a.agents[c.Name] = c
+		hosts = append(hosts, c.Name)
+	}
+	a.state.SetHosts(hosts)
+
+	a.view = NewView(a)
 	return a
 }
 
+// localHostName labels this machine's connections next to the agents'.
+func localHostName() string {
+	name, _ := os.Hostname()
+	return cmp.Or(name, "local")
+}
+
+// CheckAgentNames rejects agents sharing a name with each other or, unless
+// noLocal is set, with this machine: connections are routed to the host they
+// are labelled with, so a clash would send actions to the wrong one.
+func CheckAgentNames(agents []*agent.Client, noLocal bool) error {
+	seen := make(map[string]bool)
+	local := localHostName()
+	for _, c := range agents {
+		switch {
+		case c.Name == local && !noLocal:
+			return fmt.Errorf("agent %q has the name of this machine; give it another with -agent name=url", c.Name)
+		case seen[c.Name]:
+			return fmt.Errorf("two agents are named %q; give one another with -agent name=url", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
 func (a *App) Run() error {
 	a.view.Init()
 	a.setKeybindings()
+	a.tviewApp.SetAfterDrawFunc(func(screen tcell.Screen) { a.screen = screen })
 
 	go a.refreshDataLoop()
+	if a.intel != nil {
+		go a.intel.Run(context.Background(), 5*time.Second, a.intelReloaded)
+	}
 
 	return a.tviewApp.Run()
 }
@@ -38,7 +183,7 @@ func (a *App) Stop() {
 func (a *App) refreshDataLoop() {
 	a.loadData()
 
-	ticker := time.NewTicker(3 * time.Second)
+	ticker := time.NewTicker(refreshInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -49,19 +194,141 @@ func (a *App) refreshDataLoop() {
 func (a *App) loadData() {
 	selectedRow, _ := a.view.table.GetSelection()
 
-	conns, err := conn.FetchConnections()
+	start := time.Now()
+	conns, err := a.fetchConnections()
 	if err != nil {
 		return
 	}
+	fetchTime := time.Since(start)
+	hostInfo := models.GetHostInfo()
+	neighbors, _ := neigh.List()
+	neighborDups := neigh.Duplicates(neighbors)
 
+	a.intel.Annotate(conns)
 	a.state.SetConnections(conns)
+	a.trends.Add(timeseries.Aggregate(conns, time.Now()))
+	alertResult, evaluated := a.evaluateAlerts(conns)
 
 	a.tviewApp.QueueUpdateDraw(func() {
+		a.hostInfo, a.fetchTime, a.refreshed = hostInfo, fetchTime, time.Now()
+		a.neighbors, a.neighborDups = neighbors, neighborDups
+		if evaluated {
+			a.view.showAlertResult(alertResult)
+		}
 		a.view.Refresh()
+		if a.view.trendPaneVisible() {
+			a.view.renderTrendPane()
+		}
 		if selectedRow < a.view.table.GetRowCount() {
 			a.view.table.Select(selectedRow, 0)
 		} else if a.view.table.GetRowCount() > 1 {
-			a.view.table.Select(1, 0) 
+			a.view.table.Select(1, 0)
 		}
 	})
 }
+
+// fetchConnections gathers every source concurrently. It only fails when no
+// source answered; per-host errors are kept for the host switcher.
+func (a *App) fetchConnections() ([]models.Connection, error) {
+	if len(a.agentList) == 0 {
+		return conn.FetchConnections()
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result []models.Connection
+		failed int
+	)
+	collect := func(host string, conns []models.Connection, details map[int32]models.DetailedInfo, err error) {
+		a.state.SetHostResult(host, err, details)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failed++
+			return
+		}
+		result = append(result, conns...)
+	}
+
+	if !a.noLocal {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conns, err := conn.FetchConnections()
+			for i := range conns {
+				conns[i].Host = a.localHost
+			}
+			collect(a.localHost, conns, nil, err)
+		}()
+	}
+
+	for _, client := range a.agentList {
+		wg.Add(1)
+		go func(client *agent.Client) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), agent.Timeout)
+			defer cancel()
+			snap, err := client.Snapshot(ctx)
+			collect(client.Name, snap.Connections, snap.Details, err)
+		}(client)
+	}
+	wg.Wait()
+
+	if failed == len(a.state.Hosts()) {
+		return nil, errors.New("no host could be reached")
+	}
+	return result, nil
+}
+
+func (a *App) intelReloaded(n int, err error) {
+	a.tviewApp.QueueUpdateDraw(func() {
+		if err != nil {
+			a.view.SetStatusMessage("[red]" + tview.Escape(err.Error()))
+			return
+		}
+		a.view.SetStatusMessage(fmt.Sprintf("Reloaded %d indicators", n))
+	})
+}
+
+func (a *App) remote(c models.Connection) (*agent.Client, bool) {
+	client, ok := a.agents[c.Host]
+	return client, ok
+}
+
+func (a *App) detailsFor(c models.Connection) models.DetailedInfo {
+	if _, ok := a.remote(c); ok {
+		if info, ok := a.state.RemoteDetails(c.Host, c.Pid); ok {
+			return info
+		}
+		return models.DetailedInfo{Username: "N/A", Cmdline: "N/A"}
+	}
+	return models.GetDetailedInfo(c.Pid)
+}
+
+func (a *App) killProcess(c models.Connection, force bool) error {
+	if client, ok := a.remote(c); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), agent.Timeout)
+		defer cancel()
+		return client.Kill(ctx, c.Pid, force)
+	}
+	if force {
+		return actions.ForceKillProcess(c.Pid)
+	}
+	return actions.KillProcess(c.Pid)
+}
+
+// closeSocket destroys the TCP socket behind c without touching its process.
+func (a *App) closeSocket(c models.Connection) error {
+	if _, ok := a.remote(c); ok {
+		return errors.New("closing sockets is only available for local connections")
+	}
+	if c.Type != "TCP" || !c.HasRemote() {
+		return errors.New("only connected TCP sockets can be closed")
+	}
+	src, dst := net.ParseIP(c.LocalIP()), net.ParseIP(c.RemoteIP())
+	if src == nil || dst == nil {
+		return fmt.Errorf("cannot parse %s -> %s", c.Laddr, c.Raddr)
+	}
+	return sockdiag.DestroyTCP(src, uint16(c.LocalPort()), dst, uint16(c.RemotePort()))
+}
@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/agent"
+)
+
+func TestCheckAgentNames(t *testing.T) {
+	client := func(name string) *agent.Client {
+		c, err := agent.NewClient(name, "https://10.0.0.1:9878", agent.ClientOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	local := localHostName()
+
+	tests := []struct {
+		agents  []string
+		noLocal bool
+		want    string // Part of the error, "" for none
+	}{
+		{[]string{"web1", "db1"}, false, ""},
+		{[]string{"web1", "web1"}, false, `two agents are named "web1"`},
+		{[]string{local}, false, "has the name of this machine"},
+		{[]string{local}, true, ""},
+		{[]string{local, local}, true, "two agents are named"},
+	}
+	for _, tt := range tests {
+		var agents []*agent.Client
+		for _, name := range tt.agents {
+			agents = append(agents, client(name))
+		}
+		err := CheckAgentNames(agents, tt.noLocal)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%q (no-local %t): %v", tt.agents, tt.noLocal, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%q (no-local %t): err = %v, want %q", tt.agents, tt.noLocal, err, tt.want)
+		}
+	}
+}
+
+func TestDiagnostic(t *testing.T) {
+	a := &App{diagnostics: actions.BuiltinDiagnostics}
+	for _, name := range []string{"ping", "nslookup", "traceroute"} {
+		if d, ok := a.diagnostic(name); !ok || d.Name != name {
+			t.Errorf("diagnostic(%q) = %q, %v", name, d.Name, ok)
+		}
+	}
+	if _, ok := a.diagnostic("mtr"); ok {
+		t.Error("diagnostic(\"mtr\") found an unregistered diagnostic")
+	}
+}
@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+type column struct {
+	title string
+	value func(no int, c models.Connection) string
+	// less is nil for columns that cannot be sorted.
+	less func(a, b models.Connection) bool
+}
+
+const (
+	colMark = iota
+	colNo
+	colHost
+)
+
+var columns = []column{
+	{
+		// The mark glyph depends on state, so populateTable fills it in.
+		title: "✓",
+		value: func(int, models.Connection) string { return "" },
+	},
+	{
+		title: "No",
+		value: func(no int, _ models.Connection) string { return strconv.Itoa(no) },
+	},
+	{
+		title: "Host",
+		value: func(_ int, c models.Connection) string { return c.Host },
+		less:  func(a, b models.Connection) bool { return a.Host < b.Host },
+	},
+	{
+		title: "Process",
+		value: func(_ int, c models.Connection) string { return c.ProcessName },
+		less: func(a, b models.Connection) bool {
+			return strings.ToLower(a.ProcessName) < strings.ToLower(b.ProcessName)
+		},
+	},
+	{
+		title: "PID",
+		value: func(_ int, c models.Connection) string { return strconv.Itoa(int(c.Pid)) },
+		less:  func(a, b models.Connection) bool { return a.Pid < b.Pid },
+	},
+	{
+		title: "Status",
+		value: func(_ int, c models.Connection) string { return c.Status },
+		less:  func(a, b models.Connection) bool { return a.Status < b.Status },
+	},
+	{
+		title: "Family",
+		value: func(_ int, c models.Connection) string { return c.Family },
+		less:  func(a, b models.Connection) bool { return a.Family < b.Family },
+	},
+	{
+		title: "Type",
+		value: func(_ int, c models.Connection) string { return c.Type },
+		less:  func(a, b models.Connection) bool { return a.Type < b.Type },
+	},
+	{
+		title: "Local Addr",
+		value: func(_ int, c models.Connection) string { return c.Laddr },
+		less:  func(a, b models.Connection) bool { return a.Laddr < b.Laddr },
+	},
+	{
+		title: "Remote Addr",
+		value: func(_ int, c models.Connection) string { return c.Raddr },
+		less:  func(a, b models.Connection) bool { return a.Raddr < b.Raddr },
+	},
+}
@@ -0,0 +1,470 @@
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/conntrack"
+	"github.com/MrBrooks89/BatStat/internal/filter"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/netif"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const conntrackKeys = "/ Filter  s/S Sort  e Export  r Reload  Enter Show its socket  Esc Close"
+
+// ctFlow is a conntrack entry with the local socket it belongs to. Flows
+// forwarded through this machine, or whose socket is already gone, have
+// none.
+type ctFlow struct {
+	conntrack.Flow
+	socket *models.Connection
+}
+
+// conn is what filters match against: the socket when there is one, so
+// terms mean the same as in the main table, otherwise the original tuple
+// with its source as the local end.
+func (f *ctFlow) conn(host string) models.Connection {
+	if f.socket != nil {
+		return *f.socket
+	}
+	return models.Connection{
+		Family: f.Family,
+		Type:   strings.ToUpper(f.Protocol),
+		Laddr:  f.Orig.SrcAddr(),
+		Raddr:  f.Orig.DstAddr(),
+		Status: f.State,
+		Host:   host,
+	}
+}
+
+func (f *ctFlow) flags() string {
+	var flags []string
+	if f.Assured {
+		flags = append(flags, "ASSURED")
+	}
+	if !f.Replied {
+		flags = append(flags, "UNREPLIED")
+	}
+	return strings.Join(flags, " ")
+}
+
+func (f *ctFlow) socketName() string {
+	if f.socket == nil {
+		return ""
+	}
+	if f.socket.Pid == 0 {
+		return cmp.Or(f.socket.ProcessName, "-")
+	}
+	return fmt.Sprintf("%s (%d)", f.socket.ProcessName, f.socket.Pid)
+}
+
+func (f *ctFlow) packets() uint64 { return f.OrigCounters.Packets + f.ReplyCounters.Packets }
+func (f *ctFlow) bytes() uint64   { return f.OrigCounters.Bytes + f.ReplyCounters.Bytes }
+
+// conntrackColumns are the columns of the flow table and how they sort.
+var conntrackColumns = []struct {
+	title string
+	cmp   func(a, b *ctFlow) int
+}{
+	{"Proto", func(a, b *ctFlow) int {
+		return cmp.Or(strings.Compare(a.Protocol, b.Protocol), compareTuples(a.Orig, b.Orig))
+	}},
+	{"State", func(a, b *ctFlow) int { return strings.Compare(a.State, b.State) }},
+	{"Original", func(a, b *ctFlow) int { return compareTuples(a.Orig, b.Orig) }},
+	{"Reply", func(a, b *ctFlow) int { return compareTuples(a.Reply, b.Reply) }},
+	{"NAT", func(a, b *ctFlow) int { return strings.Compare(a.NAT(), b.NAT()) }},
+	{"Flags", func(a, b *ctFlow) int { return strings.Compare(a.flags(), b.flags()) }},
+	{"Timeout", func(a, b *ctFlow) int { return cmp.Compare(a.Timeout, b.Timeout) }},
+	{"Mark", func(a, b *ctFlow) int { return cmp.Compare(a.Mark, b.Mark) }},
+	{"Zone", func(a, b *ctFlow) int { return cmp.Compare(a.Zone, b.Zone) }},
+	{"Packets", func(a, b *ctFlow) int { return cmp.Compare(a.packets(), b.packets()) }},
+	{"Bytes", func(a, b *ctFlow) int { return cmp.Compare(a.bytes(), b.bytes()) }},
+	{"Socket", func(a, b *ctFlow) int { return strings.Compare(a.socketName(), b.socketName()) }},
+}
+
+func compareTuples(a, b conntrack.Tuple) int {
+	return cmp.Or(
+		a.Src.Compare(b.Src),
+		cmp.Compare(a.SrcPort, b.SrcPort),
+		a.Dst.Compare(b.Dst),
+		cmp.Compare(a.DstPort, b.DstPort),
+	)
+}
+
+func (v *View) showConntrackModal() {
+	statusView := tview.NewTextView().SetDynamicColors(true)
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	filterInput := tview.NewInputField().
+		SetLabel("Filter: ").
+		SetLabelColor(tcell.ColorYellow).
+		SetFieldBackgroundColor(tcell.ColorDarkSlateGray).
+		SetText(v.app.state.GetFilterText())
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(statusView, 1, 0, false).
+		AddItem(table, 0, 1, true).
+		AddItem(filterInput, 1, 0, false)
+	layout.SetBorderPadding(0, 0, 1, 1)
+
+	frame := tview.NewFrame(layout).
+		AddText("Connection tracking (conntrack/NAT)", true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(conntrackKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	// Only touched on the UI goroutine.
+	var flows, rows []*ctFlow
+	var loadErr error
+	sortColumn, sortAsc := 2, true
+	render := func() {
+		if loadErr != nil {
+			statusView.SetText("[red]" + tview.Escape(loadErr.Error()))
+			return
+		}
+		var selected *ctFlow
+		if row, _ := table.GetSelection(); row >= 1 && row <= len(rows) {
+			selected = rows[row-1]
+		}
+		query := filter.Parse(filterInput.GetText())
+		rows = rows[:0]
+		for _, f := range flows {
+			if query.Match(f.conn(v.app.localHost)) {
+				rows = append(rows, f)
+			}
+		}
+		slices.SortStableFunc(rows, func(a, b *ctFlow) int {
+			c := conntrackColumns[sortColumn].cmp(a, b)
+			if !sortAsc {
+				return -c
+			}
+			return c
+		})
+		renderConntrack(table, rows, sortColumn, sortAsc)
+		if i := slices.Index(rows, selected); i >= 0 {
+			table.Select(i+1, 0)
+		} else {
+			table.Select(1, 0).ScrollToBeginning()
+		}
+		statusView.SetText(conntrackStatus(flows, rows))
+	}
+	load := func() {
+		var list []conntrack.Flow
+		if list, loadErr = conntrack.List(); loadErr != nil {
+			flows = nil
+		} else {
+			flows = v.app.linkFlows(list)
+		}
+		render()
+	}
+
+	closeModal := func() {
+		v.pages.RemovePage("conntrack_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+	filterInput.SetChangedFunc(func(string) { render() })
+	filterInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter || key == tcell.KeyEscape {
+			v.app.tviewApp.SetFocus(table)
+		}
+	})
+	table.SetSelectedFunc(func(row, column int) {
+		if row < 1 || row > len(rows) {
+			return
+		}
+		f := rows[row-1]
+		if f.socket == nil {
+			statusView.SetText("[yellow]No local socket for this flow; it is forwarded or already closed.")
+			return
+		}
+		closeModal()
+		v.filterBySocket(*f.socket)
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			closeModal()
+			return nil
+		case event.Rune() == '/':
+			v.app.tviewApp.SetFocus(filterInput)
+			return nil
+		case event.Rune() == 's':
+			sortColumn = (sortColumn + 1) % len(conntrackColumns)
+			render()
+			return nil
+		case event.Rune() == 'S':
+			sortAsc = !sortAsc
+			render()
+			return nil
+		case event.Rune() == 'r':
+			load()
+			return nil
+		case event.Rune() == 'e':
+			if len(rows) == 0 {
+				statusView.SetText("No flows to export.")
+				return nil
+			}
+			order := "ASC"
+			if !sortAsc {
+				order = "DESC"
+			}
+			meta := actions.NewExportMeta(filterInput.GetText(), conntrackColumns[sortColumn].title+" "+order)
+			exported := slices.Clone(rows)
+			v.showExportModal(" Export conntrack table ", "batstat_conntrack", func() actions.Dataset {
+				return conntrackDataset(exported)
+			}, meta)
+			return nil
+		}
+		return event
+	})
+
+	load()
+	v.pages.AddPage("conntrack_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(table)
+}
+
+func conntrackStatus(flows, rows []*ctFlow) string {
+	linked, nat := 0, 0
+	counters := false
+	for _, f := range rows {
+		if f.socket != nil {
+			linked++
+		}
+		if f.NAT() != "" {
+			nat++
+		}
+		counters = counters || f.HasCounters
+	}
+	status := fmt.Sprintf("[yellow]Flows:[white] %d", len(rows))
+	if len(rows) != len(flows) {
+		status += fmt.Sprintf(" of %d", len(flows))
+	}
+	status += fmt.Sprintf("  [yellow]NAT:[white] %d  [yellow]Local sockets:[white] %d", nat, linked)
+	if !counters && !conntrack.AccountingEnabled() {
+		status += "  [gray]no counters (nf_conntrack_acct=0)[white]"
+	}
+	return status
+}
+
+func renderConntrack(table *tview.Table, rows []*ctFlow, sortColumn int, sortAsc bool) {
+	table.Clear()
+	for i, col := range conntrackColumns {
+		title := col.title
+		if i == sortColumn {
+			title += " [yellow]▲"
+			if !sortAsc {
+				title = col.title + " [yellow]▼"
+			}
+		}
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false))
+	}
+
+	for r, f := range rows {
+		packets, bytes := "-", "-"
+		if f.HasCounters {
+			packets = fmt.Sprintf("%d/%d", f.OrigCounters.Packets, f.ReplyCounters.Packets)
+			bytes = netif.FormatBytes(float64(f.OrigCounters.Bytes)) + "/" + netif.FormatBytes(float64(f.ReplyCounters.Bytes))
+		}
+		mark, zone := "", ""
+		if f.Mark != 0 {
+			mark = fmt.Sprintf("%#x", f.Mark)
+		}
+		if f.Zone != 0 {
+			zone = strconv.Itoa(int(f.Zone))
+		}
+		cells := []string{f.Protocol, f.State, formatTuple(f.Orig), formatTuple(f.Reply), f.NAT(), f.flags(),
+			formatTimeout(f.Timeout), mark, zone, packets, bytes, f.socketName()}
+		for c, text := range cells {
+			cell := tview.NewTableCell(tview.Escape(text))
+			switch c {
+			case 1:
+				cell.SetTextColor(getStatusColor(f.State))
+			case 3, 4:
+				if f.NAT() != "" {
+					cell.SetTextColor(tcell.ColorYellow)
+				}
+			case 6, 7, 8, 9, 10:
+				cell.SetAlign(tview.AlignRight)
+			case 11:
+				cell.SetExpansion(1)
+			}
+			table.SetCell(r+1, c, cell)
+		}
+	}
+}
+
+// formatTuple shows one direction as "src:port → dst:port", with IPv6
+// addresses in brackets.
+func formatTuple(t conntrack.Tuple) string {
+	if t.SrcPort == 0 && t.DstPort == 0 {
+		return t.Src.String() + " → " + t.Dst.String()
+	}
+	return net.JoinHostPort(t.Src.String(), strconv.Itoa(int(t.SrcPort))) + " → " +
+		net.JoinHostPort(t.Dst.String(), strconv.Itoa(int(t.DstPort)))
+}
+
+// formatTimeout shows short timeouts to the second and long ones, like the
+// days of an established TCP flow, as uptimes.
+func formatTimeout(d time.Duration) string {
+	if d < time.Hour {
+		return d.String()
+	}
+	return formatUptime(d)
+}
+
+// linkFlows pairs each flow with the local socket at one of its ends.
+// Connected sockets are found by their address pair, which is the original
+// tuple for flows this machine started and the reply tuple for those it
+// accepted, after any DNAT. Flows to unconnected UDP sockets, such as a DNS
+// server's, are found by local port.
+func (a *App) linkFlows(list []conntrack.Flow) []*ctFlow {
+	connected := make(map[string]*models.Connection)
+	bound := make(map[uint32][]*models.Connection)
+	conns := a.state.Connections()
+	for i := range conns {
+		c := &conns[i]
+		if _, ok := a.remote(*c); ok || c.Family == "Unix" {
+			continue
+		}
+		local, err := netip.ParseAddr(c.LocalIP())
+		if err != nil {
+			continue
+		}
+		if !c.HasRemote() {
+			if c.Type == "UDP" {
+				bound[c.LocalPort()] = append(bound[c.LocalPort()], c)
+			}
+			continue
+		}
+		remote, err := netip.ParseAddr(c.RemoteIP())
+		if err != nil {
+			continue
+		}
+		connected[flowKey(c.Type, local.Unmap(), uint16(c.LocalPort()), remote.Unmap(), uint16(c.RemotePort()))] = c
+	}
+
+	flows := make([]*ctFlow, len(list))
+	for i, f := range list {
+		flows[i] = &ctFlow{Flow: f}
+		typ := strings.ToUpper(f.Protocol)
+		tuples := []conntrack.Tuple{f.Orig, f.Reply}
+		for _, t := range tuples {
+			if c, ok := connected[flowKey(typ, t.Src, t.SrcPort, t.Dst, t.DstPort)]; ok {
+				flows[i].socket = c
+				break
+			}
+		}
+		if flows[i].socket != nil || typ != "UDP" {
+			continue
+		}
+	bound:
+		for _, t := range tuples {
+			for _, c := range bound[uint32(t.SrcPort)] {
+				if local, _ := netip.ParseAddr(c.LocalIP()); local.IsUnspecified() || local.Unmap() == t.Src {
+					flows[i].socket = c
+					break bound
+				}
+			}
+		}
+	}
+	return flows
+}
+
+func flowKey(typ string, local netip.Addr, localPort uint16, remote netip.Addr, remotePort uint16) string {
+	return fmt.Sprintf("%s|%s|%d|%s|%d", typ, local.Unmap(), localPort, remote.Unmap(), remotePort)
+}
+
+// filterBySocket narrows the main table to one socket.
+func (v *View) filterBySocket(c models.Connection) {
+	term := fmt.Sprintf("type:%s lport:%d", strings.ToLower(c.Type), c.LocalPort())
+	if c.HasRemote() {
+		term += fmt.Sprintf(" raddr:%s rport:%d", c.RemoteIP(), c.RemotePort())
+	}
+	if v.app.state.MultiHost() {
+		term = "host:" + v.app.localHost + " " + term
+	}
+	v.filterInput.SetText(term)
+}
+
+func conntrackDataset(rows []*ctFlow) actions.Dataset {
+	ds := actions.Dataset{
+		Name:  "conntrack",
+		Title: "BatStat conntrack table",
+		Columns: []actions.Column{
+			{Key: "family", Title: "Family"},
+			{Key: "protocol", Title: "Protocol"},
+			{Key: "state", Title: "State"},
+			{Key: "orig_src", Title: "OrigSrc"},
+			{Key: "orig_sport", Title: "OrigSport"},
+			{Key: "orig_dst", Title: "OrigDst"},
+			{Key: "orig_dport", Title: "OrigDport"},
+			{Key: "reply_src", Title: "ReplySrc"},
+			{Key: "reply_sport", Title: "ReplySport"},
+			{Key: "reply_dst", Title: "ReplyDst"},
+			{Key: "reply_dport", Title: "ReplyDport"},
+			{Key: "nat", Title: "NAT"},
+			{Key: "flags", Title: "Flags"},
+			{Key: "timeout_s", Title: "TimeoutS"},
+			{Key: "mark", Title: "Mark"},
+			{Key: "zone", Title: "Zone"},
+			{Key: "orig_packets", Title: "OrigPackets"},
+			{Key: "orig_bytes", Title: "OrigBytes"},
+			{Key: "reply_packets", Title: "ReplyPackets"},
+			{Key: "reply_bytes", Title: "ReplyBytes"},
+			{Key: "process", Title: "Process"},
+			{Key: "pid", Title: "PID"},
+		},
+	}
+
+	protocols := make(map[string]int)
+	states := make(map[string]int)
+	nat, linked := 0, 0
+	for _, f := range rows {
+		var origPackets, origBytes, replyPackets, replyBytes any
+		if f.HasCounters {
+			origPackets, origBytes = f.OrigCounters.Packets, f.OrigCounters.Bytes
+			replyPackets, replyBytes = f.ReplyCounters.Packets, f.ReplyCounters.Bytes
+		}
+		var process, pid any
+		if f.socket != nil {
+			process, pid = f.socket.ProcessName, f.socket.Pid
+			linked++
+		}
+		ds.Rows = append(ds.Rows, []any{
+			f.Family, f.Protocol, f.State,
+			f.Orig.Src.String(), f.Orig.SrcPort, f.Orig.Dst.String(), f.Orig.DstPort,
+			f.Reply.Src.String(), f.Reply.SrcPort, f.Reply.Dst.String(), f.Reply.DstPort,
+			f.NAT(), f.flags(), int(f.Timeout.Seconds()), f.Mark, f.Zone,
+			origPackets, origBytes, replyPackets, replyBytes, process, pid,
+		})
+
+		protocols[f.Protocol]++
+		if f.State != "" {
+			states[f.State]++
+		}
+		if f.NAT() != "" {
+			nat++
+		}
+	}
+
+	ds.Summary = []actions.SummaryGroup{
+		{Title: "Total", Counts: []actions.SummaryCount{
+			{Label: "Flows", Count: len(rows)},
+			{Label: "NAT", Count: nat},
+			{Label: "With local socket", Count: linked},
+		}},
+		{Title: "By protocol", Counts: actions.SortedCounts(protocols)},
+		{Title: "By TCP state", Counts: actions.SortedCounts(states)},
+	}
+	return ds
+}
@@ -0,0 +1,310 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/history"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// reservedKeys are bound by BatStat itself and cannot be given to
+// user-defined diagnostics.
+const reservedKeys = "qsSrkKcxbBpntoDdwIRAC mMyagiHhe/"
+
+// CheckDiagnosticKeys rejects user-defined diagnostics whose key is taken.
+func CheckDiagnosticKeys(diags []actions.Diagnostic) error {
+	for _, d := range diags {
+		if d.Key != 0 && strings.ContainsRune(reservedKeys, d.Key) {
+			return fmt.Errorf("diagnostic %q: key %q is already used by BatStat", d.Name, d.Key)
+		}
+	}
+	return nil
+}
+
+// diagnostic returns the registered diagnostic called name.
+func (a *App) diagnostic(name string) (actions.Diagnostic, bool) {
+	for _, d := range a.diagnostics {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return actions.Diagnostic{}, false
+}
+
+// builtin returns the diagnostic called name, saying so in the status bar
+// when there is none.
+func (v *View) builtin(name string) (actions.Diagnostic, bool) {
+	d, ok := v.app.diagnostic(name)
+	if !ok {
+		v.SetStatusMessage("[red]No diagnostic named " + tview.Escape(name))
+	}
+	return d, ok
+}
+
+// diagnosticForKey finds the user-defined diagnostic bound to key.
+func (a *App) diagnosticForKey(key rune) (actions.Diagnostic, bool) {
+	for _, d := range a.diagnostics {
+		if d.Func == nil && d.Key != 0 && d.Key == key {
+			return d, true
+		}
+	}
+	return actions.Diagnostic{}, false
+}
+
+// runDiag runs d against c, on c's agent for remote connections. Agents only
+// offer the built-ins.
+func (a *App) runDiag(ctx context.Context, d actions.Diagnostic, c models.Connection, outputChan chan<- string) error {
+	if client, ok := a.remote(c); ok {
+		if d.Func == nil {
+			close(outputChan)
+			return fmt.Errorf("%s only runs for local connections", d.Name)
+		}
+		client.Diag(ctx, d.Name, c.RemoteIP(), outputChan)
+		return nil
+	}
+	return d.Run(ctx, c, outputChan)
+}
+
+// runDiagnostic runs d on the marked connections, or on the selected one.
+// Built-ins use their native views for a single local connection.
+func (v *View) runDiagnostic(d actions.Diagnostic) {
+	if v.app.state.MarkCount() > 0 {
+		v.showBatchDiagModal(d)
+		return
+	}
+	switch d.Name {
+	case "ping":
+		v.showPingModal()
+		return
+	case "nslookup":
+		v.showNslookupModal()
+		return
+	case "traceroute":
+		v.showTracerouteModal()
+		return
+	}
+
+	c := v.GetSelectedConnection()
+	if c == nil {
+		return
+	}
+	if d.NeedsRemote() && !c.HasRemote() {
+		v.SetStatusMessage(d.Name + " needs a connection with a remote address.")
+		return
+	}
+	v.showDiagModal(d, *c)
+}
+
+// diagLabel describes one run of d, with the command line for user-defined
+// diagnostics.
+func diagLabel(d actions.Diagnostic, c models.Connection) string {
+	if d.Func != nil {
+		return d.Name + " " + c.RemoteIP() + viaHost(c)
+	}
+	argv, err := d.Expand(c)
+	if err != nil {
+		return d.Name
+	}
+	for i, arg := range argv {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			argv[i] = strconv.Quote(arg)
+		}
+	}
+	return strings.Join(argv, " ")
+}
+
+// showDiagModal streams the output of one diagnostic run.
+func (v *View) showDiagModal(d actions.Diagnostic, c models.Connection) {
+	v.showStreamModal(diagLabel(d, c)+"...", d.Name, func(ctx context.Context, out *outputViewer) {
+		err := v.streamDiag(ctx, d, c, out)
+		if ctx.Err() == nil {
+			out.finish(diagResult(d, err), err != nil)
+		}
+	})
+}
+
+// streamDiag runs d and prints its output. Finished runs against a remote
+// address go into the history.
+func (v *View) streamDiag(ctx context.Context, d actions.Diagnostic, c models.Connection, out *outputViewer) error {
+	start := time.Now()
+	outputChan := make(chan string)
+	errChan := make(chan error, 1)
+	go func() { errChan <- v.app.runDiag(ctx, d, c, outputChan) }()
+	var output []string
+	for line := range outputChan {
+		out.print("", line)
+		if output = append(output, line); len(output) > history.MaxOutputLines {
+			output = output[1:]
+		}
+	}
+	err := <-errChan
+
+	if ctx.Err() == nil && c.HasRemote() {
+		run := history.Run{
+			Time:    start,
+			Target:  c.RemoteIP(),
+			Tool:    d.Name,
+			Elapsed: time.Since(start),
+			Output:  output,
+		}
+		if _, ok := v.app.remote(c); ok {
+			run.Host = c.Host
+		}
+		if d.Func == nil {
+			run.Params = map[string]string{"command": diagLabel(d, c)}
+		}
+		if err != nil {
+			run.Status = err.Error()
+		}
+		v.app.recordRun(run)
+	}
+	return err
+}
+
+// diagResult describes how a run ended: the exit status of a command, or
+// just "done" for the built-ins, which have none.
+func diagResult(d actions.Diagnostic, err error) string {
+	switch {
+	case err != nil:
+		return err.Error()
+	case d.Func == nil:
+		return "exit status 0"
+	}
+	return "done"
+}
+
+// showStreamModal shows the output printed by run in an output viewer.
+// Closing it cancels run's context.
+func (v *View) showStreamModal(title, name string, run func(ctx context.Context, out *outputViewer)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := newOutputViewer(v, name, func() {
+		cancel()
+		v.pages.RemovePage("stream_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	})
+
+	frame := tview.NewFrame(out).
+		AddText(title, true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(outputKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	go run(ctx, out)
+
+	v.pages.AddPage("stream_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(out)
+}
+
+// showBatchDiagModal runs one diagnostic against the marked connections,
+// one after another, in a single output window.
+func (v *View) showBatchDiagModal(d actions.Diagnostic) {
+	targets := diagTargets(d, v.app.state.MarkedConnections())
+	if len(targets) == 0 {
+		v.SetStatusMessage(fmt.Sprintf("No marked connection can run %s.", d.Name))
+		return
+	}
+
+	title := fmt.Sprintf("%s for %d marked connection(s)...", d.Name, len(targets))
+	if d.Func != nil {
+		title = fmt.Sprintf("%s for %d marked host(s)...", d.Name, len(targets))
+	}
+	v.showStreamModal(title, d.Name+"_batch", func(ctx context.Context, out *outputViewer) {
+		failed := 0
+		for i, c := range targets {
+			out.print("yellow", fmt.Sprintf("── %s (%d/%d) ──", diagLabel(d, c), i+1, len(targets)))
+			start := time.Now()
+			err := v.streamDiag(ctx, d, c, out)
+			if ctx.Err() != nil {
+				return
+			}
+			color := "gray"
+			if err != nil {
+				color = "red"
+				failed++
+			}
+			out.print(color, fmt.Sprintf("%s after %s", diagResult(d, err), time.Since(start).Round(time.Millisecond)))
+			out.print("", "")
+		}
+		out.finish(fmt.Sprintf("%d of %d runs failed", failed, len(targets)), failed > 0)
+	})
+}
+
+// diagTargets picks the connections to run d on: one per remote address
+// for the built-ins, one per distinct command line otherwise.
+func diagTargets(d actions.Diagnostic, conns []models.Connection) []models.Connection {
+	seen := make(map[string]bool)
+	var targets []models.Connection
+	for _, c := range conns {
+		if d.NeedsRemote() && !c.HasRemote() {
+			continue
+		}
+		key := c.Host + "|" + c.RemoteIP()
+		if d.Func == nil {
+			argv, err := d.Expand(c)
+			if err != nil {
+				continue
+			}
+			key = c.Host + "|" + strings.Join(argv, "\x00")
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		targets = append(targets, c)
+	}
+	if d.Func != nil {
+		sort.SliceStable(targets, func(i, j int) bool { return targets[i].RemoteIP() < targets[j].RemoteIP() })
+	}
+	return targets
+}
+
+// showDiagMenu lists every diagnostic, including those without a key of
+// their own, and runs the chosen one on the selection.
+func (v *View) showDiagMenu() {
+	closeModal := func() {
+		v.pages.RemovePage("diag_menu")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	list := tview.NewList()
+	for _, d := range v.app.diagnostics {
+		secondary := "  " + d.Description
+		if d.Func == nil {
+			secondary = "  " + strings.Join(d.Command, " ")
+			if d.Description != "" {
+				secondary = "  " + d.Description
+			}
+		}
+		list.AddItem(d.Name, tview.Escape(secondary), d.Key, func() {
+			closeModal()
+			v.runDiagnostic(d)
+		})
+	}
+	title := " Diagnostics "
+	if n := v.app.state.MarkCount(); n > 0 {
+		title = fmt.Sprintf(" Diagnostics for %d marked ", n)
+	}
+	list.SetBorder(true).SetTitle(title)
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	grid := tview.NewGrid().
+		SetColumns(0, 70, 0).
+		SetRows(0, min(2*len(v.app.diagnostics)+2, 24), 0).
+		AddItem(list, 1, 1, 1, 1, 0, 0, true)
+
+	v.pages.AddPage("diag_menu", grid, true, true)
+	v.app.tviewApp.SetFocus(list)
+}
@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/history"
+	"github.com/MrBrooks89/BatStat/internal/probe"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// showNslookupModal shows the PTR names of the remote address and whether
+// each of them resolves back to it, queried directly over DNS.
+func (v *View) showNslookupModal() {
+	c := v.GetSelectedConnection()
+	if c == nil || !c.HasRemote() {
+		return
+	}
+	if _, ok := v.app.remote(*c); ok {
+		if d, ok := v.builtin("nslookup"); ok {
+			v.showDiagModal(d, *c)
+		}
+		return
+	}
+	ip := c.RemoteIP()
+
+	server := v.app.dnsServer
+	if server == "" {
+		server = probe.DefaultNameserver()
+	}
+	serverInput := tview.NewInputField().
+		SetLabel("Nameserver: ").
+		SetPlaceholder("system resolver").
+		SetText(server).
+		SetFieldWidth(40)
+	statusView := tview.NewTextView().SetDynamicColors(true)
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(serverInput, 1, 0, true).
+		AddItem(statusView, 2, 0, false).
+		AddItem(table, 0, 1, false)
+	layout.SetBorderPadding(0, 0, 1, 1)
+
+	frame := tview.NewFrame(layout).
+		AddText(fmt.Sprintf("DNS for %s", ip), true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText("Enter to query, Tab to switch focus, Esc to close", false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	cancel := func() {}
+	query := func() {
+		cancel()
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		server := strings.TrimSpace(serverInput.GetText())
+		v.app.dnsServer = server
+		statusView.SetText("[gray]Querying...")
+
+		go func() {
+			start := time.Now()
+			report, err := probe.Resolver{Server: server}.Inspect(ctx, ip)
+			if ctx.Err() != nil {
+				return
+			}
+			run := history.Run{
+				Time:    start,
+				Target:  ip,
+				Tool:    "nslookup",
+				Params:  map[string]string{"server": cmp.Or(server, "system")},
+				Elapsed: time.Since(start),
+			}
+			switch rows := report.Table(); {
+			case err != nil:
+				run.Status = err.Error()
+			case len(rows) == 0:
+				run.Output = []string{"No PTR record for " + ip}
+			default:
+				run.DNS, run.Output = rows, report.Lines()
+			}
+			v.app.recordRun(run)
+			v.app.tviewApp.QueueUpdateDraw(func() {
+				renderDNS(table, statusView, report, err)
+			})
+		}()
+	}
+
+	serverInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			query()
+		}
+	})
+
+	frame.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			cancel()
+			v.pages.RemovePage("nslookup_modal")
+			v.app.tviewApp.SetFocus(v.table)
+			return nil
+		case tcell.KeyTab, tcell.KeyBacktab:
+			if serverInput.HasFocus() {
+				v.app.tviewApp.SetFocus(table)
+			} else {
+				v.app.tviewApp.SetFocus(serverInput)
+			}
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("nslookup_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(serverInput)
+	query()
+}
+
+func renderDNS(table *tview.Table, statusView *tview.TextView, report probe.DNSReport, err error) {
+	server := report.Server
+	if server == "" {
+		server = "system resolver (no TTLs)"
+	}
+	status := fmt.Sprintf("[yellow]Server:[white] %s  [yellow]Time:[white] %s", tview.Escape(server), report.Elapsed.Round(time.Millisecond/10))
+	switch {
+	case err != nil:
+		status += "\n[red]" + tview.Escape(err.Error())
+	case len(report.PTR) == 0:
+		status += fmt.Sprintf("\n[gray]No PTR record for %s", report.IP)
+	}
+	statusView.SetText(status)
+
+	table.Clear()
+	for i, title := range []string{"Name", "Type", "TTL", "Value", "Status"} {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false).
+			SetExpansion(1))
+	}
+	for r, row := range report.Table() {
+		color := tcell.ColorWhite
+		status := row[len(row)-1]
+		switch {
+		case strings.HasPrefix(status, "not ") || strings.HasPrefix(status, "error") || strings.HasPrefix(status, "no "):
+			color = tcell.ColorRed
+		case status != "":
+			color = tcell.ColorGreen
+		}
+		for col, text := range row {
+			cell := tview.NewTableCell(tview.Escape(text)).SetExpansion(1)
+			if col == len(row)-1 {
+				cell.SetTextColor(color)
+			}
+			table.SetCell(r+1, col, cell)
+		}
+	}
+}
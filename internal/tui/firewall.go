@@ -0,0 +1,250 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/firewall"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const firewallTimeout = 10 * time.Second
+
+// startBlockExpiry removes expired blocks while BatStat runs. Blocks that
+// expire while it is closed are removed the next time the list is shown.
+func (a *App) startBlockExpiry() {
+	a.expiryOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(10 * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				ctx, cancel := context.WithTimeout(context.Background(), firewallTimeout)
+				expired, err := a.firewall.Expire(ctx, time.Now())
+				cancel()
+				if err == nil && len(expired) == 0 {
+					continue
+				}
+				a.tviewApp.QueueUpdateDraw(func() {
+					if err != nil {
+						a.view.SetStatusMessage("[red]Expiring blocks: " + tview.Escape(err.Error()))
+						return
+					}
+					targets := make([]string, len(expired))
+					for i, b := range expired {
+						targets[i] = b.Target()
+					}
+					a.view.SetStatusMessage("Block expired: " + strings.Join(targets, ", "))
+				})
+			}
+		}()
+	})
+}
+
+func (v *View) showBlockModal() {
+	c := v.GetSelectedConnection()
+	if c == nil || !c.HasRemote() {
+		return
+	}
+	if _, ok := v.app.remote(*c); ok {
+		v.SetStatusMessage("Blocking is only available for local connections.")
+		return
+	}
+
+	ip, port := c.RemoteIP(), c.RemotePort()
+	proto := strings.ToLower(c.Type)
+	targets := []string{ip}
+	if port != 0 && (proto == "tcp" || proto == "udp") {
+		targets = append(targets, firewall.Block{IP: ip, Port: port, Proto: proto}.Target())
+	}
+
+	selected := 0
+	targetDropDown := tview.NewDropDown().
+		SetLabel("Block: ").
+		SetOptions(targets, func(option string, index int) { selected = index }).
+		SetCurrentOption(0)
+	expiryInput := tview.NewInputField().
+		SetLabel("Expire after: ").
+		SetPlaceholder("e.g. 30m or 2h, blank for never").
+		SetFieldWidth(34)
+
+	closeModal := func() {
+		v.pages.RemovePage("block_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	form := tview.NewForm().
+		AddFormItem(targetDropDown).
+		AddFormItem(expiryInput).
+		AddButton("Block", func() {
+			b := firewall.Block{IP: ip}
+			if selected == 1 {
+				b.Port, b.Proto = port, proto
+			}
+			if text := strings.TrimSpace(expiryInput.GetText()); text != "" {
+				d, err := time.ParseDuration(text)
+				if err != nil || d <= 0 {
+					v.SetStatusMessage("Invalid expiry: " + tview.Escape(text))
+					return
+				}
+				b.Expires = time.Now().Add(d)
+			}
+			closeModal()
+			v.app.blockAddress(b)
+		}).
+		AddButton("Cancel", closeModal)
+
+	form.SetBorder(true).SetTitle(fmt.Sprintf(" Block traffic to/from %s ", ip))
+
+	grid := tview.NewGrid().
+		SetColumns(0, 60, 0).
+		SetRows(0, 9, 0).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("block_modal", grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
+
+func (a *App) blockAddress(b firewall.Block) {
+	a.view.SetStatusMessage("Blocking " + b.Target() + "...")
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), firewallTimeout)
+		defer cancel()
+		err := a.firewall.Block(ctx, b)
+		backend, _ := a.firewall.Backend(ctx)
+		a.tviewApp.QueueUpdateDraw(func() {
+			if err != nil {
+				a.view.SetStatusMessage("[red]Block failed: " + tview.Escape(err.Error()))
+				return
+			}
+			msg := fmt.Sprintf("Blocked %s via %s", b.Target(), backend)
+			if !b.Expires.IsZero() {
+				msg += " until " + b.Expires.Format("15:04:05")
+				a.startBlockExpiry()
+			}
+			a.view.SetStatusMessage(msg)
+		})
+	}()
+}
+
+func (v *View) showBlocksModal() {
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	table.SetBorder(true).SetTitle(" BatStat blocks ")
+
+	var blocks []firewall.Block
+	load := func() {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), firewallTimeout)
+			defer cancel()
+			if _, err := v.app.firewall.Expire(ctx, time.Now()); err != nil {
+				v.app.tviewApp.QueueUpdateDraw(func() {
+					v.SetStatusMessage("[red]" + tview.Escape(err.Error()))
+				})
+			}
+			list, err := v.app.firewall.List(ctx)
+			if list == nil {
+				list = []firewall.Block{}
+			}
+			v.app.tviewApp.QueueUpdateDraw(func() {
+				blocks = list
+				renderBlocks(table, list, err)
+				for _, b := range list {
+					if !b.Expires.IsZero() {
+						v.app.startBlockExpiry()
+						break
+					}
+				}
+			})
+		}()
+	}
+
+	closeModal := func() {
+		v.pages.RemovePage("blocks_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'B':
+			closeModal()
+			return nil
+		case event.Rune() == 'u' || event.Key() == tcell.KeyDelete:
+			row, _ := table.GetSelection()
+			if row < 1 || row > len(blocks) {
+				return nil
+			}
+			b := blocks[row-1]
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), firewallTimeout)
+				defer cancel()
+				err := v.app.firewall.Unblock(ctx, b)
+				v.app.tviewApp.QueueUpdateDraw(func() {
+					if err != nil {
+						v.SetStatusMessage("[red]Unblock failed: " + tview.Escape(err.Error()))
+						return
+					}
+					v.SetStatusMessage("Unblocked " + b.Target())
+				})
+				load()
+			}()
+			return nil
+		}
+		return event
+	})
+
+	frame := tview.NewFrame(table).
+		AddText("u Unblock selected   Esc Close", false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	grid := tview.NewGrid().
+		SetColumns(0, 80, 0).
+		SetRows(0, 20, 0).
+		AddItem(frame, 1, 1, 1, 1, 0, 0, true)
+
+	renderBlocks(table, nil, nil)
+	v.pages.AddPage("blocks_modal", grid, true, true)
+	v.app.tviewApp.SetFocus(table)
+	load()
+}
+
+func renderBlocks(table *tview.Table, blocks []firewall.Block, err error) {
+	table.Clear()
+	for i, title := range []string{"Target", "Created", "Expires"} {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false).
+			SetExpansion(1))
+	}
+	switch {
+	case err != nil:
+		table.SetCell(1, 0, tview.NewTableCell("[red]"+tview.Escape(err.Error())).SetSelectable(false))
+		return
+	case blocks == nil:
+		table.SetCell(1, 0, tview.NewTableCell("[gray]Loading...").SetSelectable(false))
+		return
+	case len(blocks) == 0:
+		table.SetCell(1, 0, tview.NewTableCell("[gray]No active blocks").SetSelectable(false))
+		return
+	}
+	for r, b := range blocks {
+		expires := "never"
+		if !b.Expires.IsZero() {
+			expires = fmt.Sprintf("%s (in %s)", b.Expires.Format("15:04:05"), time.Until(b.Expires).Round(time.Second))
+		}
+		table.SetCell(r+1, 0, tview.NewTableCell(b.Target()).SetExpansion(1))
+		table.SetCell(r+1, 1, tview.NewTableCell(b.Created.Format("2006-01-02 15:04:05")).SetExpansion(1))
+		table.SetCell(r+1, 2, tview.NewTableCell(expires).SetExpansion(1))
+	}
+	table.Select(1, 0)
+}
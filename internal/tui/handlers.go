@@ -1,10 +1,10 @@
 package tui
 
 import (
+	"fmt"
 
 	"github.com/MrBrooks89/BatStat/internal/actions"
 	"github.com/gdamore/tcell/v2"
-	"github.com/rivo/tview"
 )
 
 func (v *View) IsModalActive() bool {
@@ -42,14 +42,80 @@ func (a *App) setKeybindings() {
 		case 'K':
 			a.view.showKillConfirmationModal(true) 
 			return nil
+		case 'c':
+			a.view.showCloseSocketModal()
+			return nil
+		case 'x':
+			a.view.showSignalModal()
+			return nil
+		case 'b':
+			a.view.showBlockModal()
+			return nil
+		case 'B':
+			a.view.showBlocksModal()
+			return nil
 		case 'p':
-			a.view.showPingModal()
+			if d, ok := a.view.builtin("ping"); ok {
+				a.view.runDiagnostic(d)
+			}
 			return nil
 		case 'n':
-			a.view.showNslookupModal()
+			if d, ok := a.view.builtin("nslookup"); ok {
+				a.view.runDiagnostic(d)
+			}
 			return nil
 		case 't':
-			a.view.showTracerouteModal()
+			if d, ok := a.view.builtin("traceroute"); ok {
+				a.view.runDiagnostic(d)
+			}
+			return nil
+		case 'o':
+			a.view.showTCPProbeModal()
+			return nil
+		case 'D':
+			a.view.showDiagMenu()
+			return nil
+		case 'd':
+			a.view.showHistoryModal()
+			return nil
+		case 'w':
+			a.view.showSweepModal()
+			return nil
+		case ' ':
+			a.view.toggleMark()
+			return nil
+		case 'm':
+			a.view.markFiltered()
+			return nil
+		case 'M':
+			a.view.clearMarks()
+			return nil
+		case 'y':
+			a.view.copySelection()
+			return nil
+		case 'a':
+			a.view.toggleAlertPane()
+			return nil
+		case 'g':
+			a.view.toggleTrendPane()
+			return nil
+		case 'i':
+			a.view.toggleSummary()
+			return nil
+		case 'I':
+			a.view.showInterfacesModal()
+			return nil
+		case 'R':
+			a.view.showRoutesModal()
+			return nil
+		case 'A':
+			a.view.showNeighborsModal()
+			return nil
+		case 'C':
+			a.view.showConntrackModal()
+			return nil
+		case 'H':
+			a.view.showHostSwitcher()
 			return nil
 		case 'h':
 			a.view.showHelpModal()
@@ -61,6 +127,12 @@ func (a *App) setKeybindings() {
 			a.tviewApp.SetFocus(a.view.filterInput)
 			return nil
 		}
+		if event.Key() == tcell.KeyRune {
+			if d, ok := a.diagnosticForKey(event.Rune()); ok {
+				a.view.runDiagnostic(d)
+				return nil
+			}
+		}
 		return event
 	})
 
@@ -86,41 +158,19 @@ func (a *App) setKeybindings() {
 	})
 }
 func (a *App) handleExport() {
+	title := "Export connections"
 	conns := a.state.GetFilteredConnections()
+	if marked := a.state.MarkedConnections(); len(marked) > 0 {
+		conns = marked
+		title = fmt.Sprintf("Export %d marked connections", len(marked))
+	}
 	if len(conns) == 0 {
 		a.view.SetStatusMessage("No connections to export.")
 		return
 	}
 
-	modal := tview.NewModal().
-		SetText("Export to CSV").
-		AddButtons([]string{"Default", "Custom", "Cancel"}).
-		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-			a.view.pages.RemovePage("export_modal")
-			switch buttonLabel {
-			case "Default":
-				filename, err := actions.ExportToCSV(conns, "batstat_export.csv")
-				if err != nil {
-					a.view.SetStatusMessage("Error exporting to CSV: " + err.Error())
-				} else {
-					a.view.SetStatusMessage("Exported to " + filename)
-				}
-			case "Custom":
-				a.view.showInputModal("Export to CSV", "File path: ", func(path string) {
-					if path == "" {
-						a.view.SetStatusMessage("Export canceled.")
-						return
-					}
-					filename, err := actions.ExportToCSV(conns, path)
-					if err != nil {
-						a.view.SetStatusMessage("Error exporting to CSV: " + err.Error())
-					} else {
-						a.view.SetStatusMessage("Exported to " + filename)
-					}
-				})
-			}
-			a.tviewApp.SetFocus(a.view.table)
-		})
-
-	a.view.pages.AddPage("export_modal", modal, true, true)
+	meta := actions.NewExportMeta(a.state.GetFilterText(), a.state.SortDescription())
+	a.view.showExportModal(title, "batstat_export", func() actions.Dataset {
+		return actions.ConnectionsDataset(conns, a.detailsFor)
+	}, meta)
 }
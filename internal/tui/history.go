@@ -0,0 +1,299 @@
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/history"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const historyKeys = "Enter Show output  Space Mark  c Compare  Esc Close"
+
+// recordRun stores r in the background; the details pane picks up the new
+// count once it is written.
+func (a *App) recordRun(r history.Run) {
+	if a.history == nil {
+		return
+	}
+	go func() {
+		err := a.history.Add(r)
+		a.tviewApp.QueueUpdateDraw(func() {
+			if err != nil {
+				a.view.SetStatusMessage("[red]Saving to history: " + tview.Escape(err.Error()))
+				return
+			}
+			row, _ := a.view.table.GetSelection()
+			a.view.updateDetailsView(row)
+		})
+	}()
+}
+
+// historyCount is the number of stored runs for c's remote address, for
+// the details pane.
+func (a *App) historyCount(c models.Connection) int {
+	if a.history == nil || !c.HasRemote() {
+		return 0
+	}
+	return a.history.Count(c.RemoteIP())
+}
+
+// showHistoryModal lists the stored runs against the selected connection's
+// remote address, newest first.
+func (v *View) showHistoryModal() {
+	c := v.GetSelectedConnection()
+	if c == nil || !c.HasRemote() {
+		return
+	}
+	if v.app.history == nil {
+		v.SetStatusMessage("History is disabled in the config file.")
+		return
+	}
+	ip := c.RemoteIP()
+	runs, err := v.app.history.Runs(ip)
+	if err != nil {
+		v.SetStatusMessage("[red]Reading history: " + tview.Escape(err.Error()))
+		return
+	}
+	if len(runs) == 0 {
+		v.SetStatusMessage("No diagnostics have been run against " + ip + " yet.")
+		return
+	}
+
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	frame := tview.NewFrame(table).
+		AddText(fmt.Sprintf("History of %s (%d runs)", ip, len(runs)), true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(historyKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	marked := make(map[int]bool)
+	render := func() { renderHistory(table, runs, marked) }
+
+	closeModal := func() {
+		v.pages.RemovePage("history_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+	selected := func() int {
+		row, _ := table.GetSelection()
+		return row - 1
+	}
+
+	table.SetSelectedFunc(func(row, _ int) {
+		if row >= 1 && row <= len(runs) {
+			v.showHistoryRun(runs[row-1], table)
+		}
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			closeModal()
+			return nil
+		case event.Rune() == ' ':
+			i := selected()
+			if i < 0 || i >= len(runs) {
+				return nil
+			}
+			switch {
+			case marked[i]:
+				delete(marked, i)
+			case len(marked) == 2:
+				v.SetStatusMessage("Only two runs can be compared; unmark one first.")
+				return nil
+			default:
+				marked[i] = true
+			}
+			render()
+			if i+1 < len(runs) {
+				table.Select(i+2, 0)
+			}
+			return nil
+		case event.Rune() == 'c':
+			var older, newer int
+			switch len(marked) {
+			case 2:
+				pair := slices.Sorted(maps.Keys(marked))
+				newer, older = pair[0], pair[1]
+			case 0:
+				newer, older = selected(), -1
+				if newer < 0 || newer >= len(runs) {
+					return nil
+				}
+				for i := newer + 1; i < len(runs); i++ {
+					if sameKind(runs[i], runs[newer]) {
+						older = i
+						break
+					}
+				}
+				if older < 0 {
+					v.SetStatusMessage("No earlier " + runs[newer].Tool + " run to compare with.")
+					return nil
+				}
+			default:
+				v.SetStatusMessage("Mark two runs with Space, or compare the selected one with its previous run.")
+				return nil
+			}
+			v.showCompareModal(ip, runs[older], runs[newer], table)
+			return nil
+		}
+		return event
+	})
+
+	render()
+	v.pages.AddPage("history_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(table)
+}
+
+// sameKind reports whether two runs used the same tool in the same way, so
+// that comparing them makes sense.
+func sameKind(a, b history.Run) bool {
+	return a.Tool == b.Tool && a.Host == b.Host && a.Params["command"] == b.Params["command"] &&
+		a.Params["mode"] == b.Params["mode"] && a.Params["port"] == b.Params["port"]
+}
+
+func renderHistory(table *tview.Table, runs []history.Run, marked map[int]bool) {
+	table.Clear()
+	for i, title := range []string{"", "Time", "Tool", "Via", "Took", "Parameters", "Result"} {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false))
+	}
+	for i, r := range runs {
+		mark := " "
+		if marked[i] {
+			mark = "*"
+		}
+		result, color := r.Summary(), tcell.ColorWhite
+		if r.Status != "" {
+			color = tcell.ColorRed
+		}
+		cells := []string{mark, r.Time.Local().Format(time.DateTime), r.Tool, cmp.Or(r.Host, "local"),
+			r.Elapsed.Round(time.Millisecond).String(), formatParams(r.Params), result}
+		for col, text := range cells {
+			cell := tview.NewTableCell(tview.Escape(text))
+			switch col {
+			case 0:
+				cell.SetTextColor(tcell.ColorYellow)
+			case 5:
+				cell.SetMaxWidth(40)
+			case 6:
+				cell.SetTextColor(color).SetExpansion(1)
+			}
+			table.SetCell(i+1, col, cell)
+		}
+	}
+}
+
+func formatParams(params map[string]string) string {
+	var parts []string
+	for _, k := range slices.Sorted(maps.Keys(params)) {
+		if k == "command" {
+			continue
+		}
+		parts = append(parts, k+"="+params[k])
+	}
+	if command, ok := params["command"]; ok {
+		parts = append(parts, command)
+	}
+	return strings.Join(parts, " ")
+}
+
+// showHistoryRun replays the output of a stored run in an output viewer.
+func (v *View) showHistoryRun(r history.Run, back tview.Primitive) {
+	out := newOutputViewer(v, r.Tool+"_"+r.Target+"_"+r.Time.Format("20060102_150405"), func() {
+		v.pages.RemovePage("history_run")
+		v.app.tviewApp.SetFocus(back)
+	})
+	title := fmt.Sprintf("%s %s%s, %s", r.Tool, r.Target, viaName(r.Host), r.Time.Local().Format(time.DateTime))
+	frame := tview.NewFrame(out).
+		AddText(title, true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(outputKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	if params := formatParams(r.Params); params != "" {
+		out.print("gray", params)
+	}
+	for _, line := range r.Output {
+		out.print("", line)
+	}
+	result := cmp.Or(r.Status, "done")
+	out.finishAfter(result, r.Status != "", r.Elapsed)
+
+	v.pages.AddPage("history_run", frame, true, true)
+	v.app.tviewApp.SetFocus(out)
+}
+
+// showCompareModal shows two runs side by side, the older one first.
+func (v *View) showCompareModal(ip string, older, newer history.Run, back tview.Primitive) {
+	comparison := history.Compare(older, newer)
+
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	for i, title := range comparison.Columns {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false))
+	}
+	for r, row := range comparison.Rows {
+		for col, text := range row.Cells {
+			cell := tview.NewTableCell(tview.Escape(text)).SetTextColor(changeColor(row.Change))
+			if col == len(row.Cells)-1 {
+				cell.SetExpansion(1)
+			}
+			table.SetCell(r+1, col, cell)
+		}
+	}
+
+	title := fmt.Sprintf("%s %s: %s → %s", older.Tool, ip,
+		older.Time.Local().Format(time.DateTime), newer.Time.Local().Format(time.DateTime))
+	if older.Tool != newer.Tool {
+		title = fmt.Sprintf("%s %s → %s %s", older.Tool, older.Time.Local().Format(time.DateTime),
+			newer.Tool, newer.Time.Local().Format(time.DateTime))
+	}
+	frame := tview.NewFrame(table).
+		AddText(title, true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(comparison.Summary, true, tview.AlignCenter, tview.Styles.PrimaryTextColor).
+		AddText("[red]removed  [green]added  [yellow]changed  [orange]worse  [aqua]better[-]   Esc Back",
+			false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			v.pages.RemovePage("history_compare")
+			v.app.tviewApp.SetFocus(back)
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("history_compare", frame, true, true)
+	v.app.tviewApp.SetFocus(table)
+}
+
+func changeColor(c history.Change) tcell.Color {
+	switch c {
+	case history.Removed:
+		return tcell.ColorRed
+	case history.Added:
+		return tcell.ColorGreen
+	case history.Changed:
+		return tcell.ColorYellow
+	case history.Worse:
+		return tcell.ColorOrange
+	case history.Better:
+		return tcell.ColorAqua
+	}
+	return tcell.ColorWhite
+}
+
+func viaName(host string) string {
+	if host == "" {
+		return ""
+	}
+	return " on " + host
+}
@@ -0,0 +1,229 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/netif"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	ifaceInterval = time.Second
+	ifaceKeys     = "Enter Show its connections  Esc Close"
+)
+
+var ifaceColumns = []string{"Interface", "State", "MTU", "Addresses", "Sockets",
+	"RX", "TX", "RX pkt/s", "TX pkt/s", "Errors in/out", "Drops in/out", "MAC"}
+
+// ifaceReading is one interface with its rates since the previous reading.
+type ifaceReading struct {
+	netif.Interface
+	rates    netif.Rates
+	hasRates bool
+}
+
+func (v *View) showInterfacesModal() {
+	statusView := tview.NewTextView().SetDynamicColors(true)
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 1)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(statusView, 1, 0, false).
+		AddItem(table, 0, 1, true)
+	layout.SetBorderPadding(0, 0, 1, 1)
+
+	frame := tview.NewFrame(layout).
+		AddText("Network interfaces", true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(ifaceKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	closeModal := func() {
+		cancel()
+		v.pages.RemovePage("iface_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	// Only touched on the UI goroutine.
+	var readings []ifaceReading
+	render := func(err error) {
+		if err != nil {
+			statusView.SetText("[red]" + tview.Escape(err.Error()))
+			return
+		}
+		row, _ := table.GetSelection()
+		renderInterfaces(table, readings, v.app.localSockets())
+		if row < 1 {
+			row = 1
+		}
+		table.Select(min(row, table.GetRowCount()-1), 0)
+		statusView.SetText(fmt.Sprintf("[yellow]Interfaces:[white] %d  [gray]rates per second, updated every %s[white]",
+			len(readings), ifaceInterval))
+	}
+
+	table.SetSelectedFunc(func(row, column int) {
+		if row < 1 || row > len(readings) {
+			return
+		}
+		closeModal()
+		v.filterByInterface(readings[row-1].Interface)
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	go func() {
+		var prev map[string]netif.Counters
+		var prevTime time.Time
+		ticker := time.NewTicker(ifaceInterval)
+		defer ticker.Stop()
+		for {
+			ifaces, err := netif.List()
+			now := time.Now()
+			next := make([]ifaceReading, len(ifaces))
+			for i, iface := range ifaces {
+				next[i].Interface = iface
+				if old, ok := prev[iface.Name]; ok {
+					next[i].rates = iface.Counters.Rate(old, now.Sub(prevTime))
+					next[i].hasRates = true
+				}
+			}
+			prev, prevTime = make(map[string]netif.Counters, len(ifaces)), now
+			for _, iface := range ifaces {
+				prev[iface.Name] = iface.Counters
+			}
+
+			v.app.tviewApp.QueueUpdateDraw(func() {
+				if ctx.Err() != nil {
+					return
+				}
+				if err == nil {
+					readings = next
+				}
+				render(err)
+			})
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	v.pages.AddPage("iface_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(table)
+}
+
+func renderInterfaces(table *tview.Table, readings []ifaceReading, sockets []netip.Addr) {
+	table.Clear()
+	for i, title := range ifaceColumns {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false))
+	}
+
+	for r, iface := range readings {
+		stateColor := tcell.ColorRed
+		switch iface.State() {
+		case "up":
+			stateColor = tcell.ColorGreen
+		case "no-carrier":
+			stateColor = tcell.ColorYellow
+		}
+
+		rx, tx, rxPkts, txPkts, errs, drops := "", "", "", "", "", ""
+		if iface.hasRates {
+			rates := iface.rates
+			rx, tx = netif.FormatRate(rates.BytesRecv), netif.FormatRate(rates.BytesSent)
+			rxPkts, txPkts = fmt.Sprintf("%.0f", rates.PacketsRecv), fmt.Sprintf("%.0f", rates.PacketsSent)
+			errs = fmt.Sprintf("%.0f/%.0f", rates.ErrIn, rates.ErrOut)
+			drops = fmt.Sprintf("%.0f/%.0f", rates.DropIn, rates.DropOut)
+		}
+
+		cells := []string{iface.Name, iface.State(), fmt.Sprint(iface.MTU), strings.Join(iface.Addrs, ", "),
+			fmt.Sprint(countOnInterface(iface.Interface, sockets)), rx, tx, rxPkts, txPkts, errs, drops, iface.HardwareAddr}
+		for c, text := range cells {
+			cell := tview.NewTableCell(tview.Escape(text))
+			switch c {
+			case 1:
+				cell.SetTextColor(stateColor)
+			case 3:
+				cell.SetMaxWidth(40)
+			case 2, 4, 5, 6, 7, 8, 9, 10:
+				cell.SetAlign(tview.AlignRight)
+			}
+			if (c == 9 || c == 10) && text != "0/0" && text != "" {
+				cell.SetTextColor(tcell.ColorRed)
+			}
+			table.SetCell(r+1, c, cell)
+		}
+	}
+}
+
+// localSockets returns the local address of every connection on this
+// machine, skipping agents' connections.
+func (a *App) localSockets() []netip.Addr {
+	var addrs []netip.Addr
+	for _, c := range a.state.Connections() {
+		if _, ok := a.remote(c); ok {
+			continue
+		}
+		if addr, err := netip.ParseAddr(c.LocalIP()); err == nil {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	return addrs
+}
+
+func interfaceAddrs(iface netif.Interface) []netip.Addr {
+	var addrs []netip.Addr
+	for _, a := range iface.Addrs {
+		if prefix, err := netip.ParsePrefix(a); err == nil {
+			addrs = append(addrs, prefix.Addr().Unmap())
+		}
+	}
+	return addrs
+}
+
+func countOnInterface(iface netif.Interface, sockets []netip.Addr) int {
+	addrs := interfaceAddrs(iface)
+	n := 0
+	for _, s := range sockets {
+		if slices.Contains(addrs, s) {
+			n++
+		}
+	}
+	return n
+}
+
+// filterByInterface narrows the table to sockets bound to one of the
+// interface's addresses. Names with spaces, common on Windows, cannot be a
+// filter term, so their addresses are listed instead.
+func (v *View) filterByInterface(iface netif.Interface) {
+	term := "iface:" + iface.Name
+	if strings.ContainsAny(iface.Name, " \t") {
+		var ips []string
+		for _, addr := range interfaceAddrs(iface) {
+			ips = append(ips, addr.String())
+		}
+		if len(ips) == 0 {
+			v.SetStatusMessage("[red]" + tview.Escape(iface.Name) + " has no addresses")
+			return
+		}
+		term = "laddr:" + strings.Join(ips, ",")
+	}
+	if v.app.state.MultiHost() {
+		term = "host:" + v.app.localHost + " " + term
+	}
+	v.filterInput.SetText(term)
+}
@@ -0,0 +1,305 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func (v *View) showKillConfirmationModal(force bool) {
+	procs := uniqueProcesses(v.selection())
+	if len(procs) == 0 {
+		return
+	}
+	if len(procs) == 1 {
+		if _, ok := v.app.remote(procs[0].conn); ok {
+			v.showRemoteKillModal(procs[0].conn, force)
+			return
+		}
+	}
+
+	var remote, local []process
+	for _, p := range procs {
+		if _, ok := v.app.remote(p.conn); ok {
+			remote = append(remote, p)
+		} else {
+			local = append(local, p)
+		}
+	}
+	// Walking the process table for children is only worth it with the
+	// tree option on; otherwise they are looked up if the box is ticked.
+	tree := v.app.killTree
+	roots, all, errs := killTargets(local, tree)
+	if len(roots) == 0 && len(remote) == 0 {
+		v.SetStatusMessage("[red]Cannot kill: " + tview.Escape(strings.Join(errs, "; ")))
+		return
+	}
+	children := len(all) - len(roots)
+	offerTree := !tree || children > 0
+	tree = tree && children > 0
+
+	opts := v.app.killOptions
+	opts.Force = force
+
+	label := processesLabel(procs)
+
+	closeModal := func() {
+		v.pages.RemovePage("kill_confirm")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	var summary strings.Builder
+	if len(procs) > 1 {
+		fmt.Fprintf(&summary, "[yellow]%d processes, %d connections:[white]\n", len(procs), len(v.selection()))
+		summary.WriteString(processSummary(procs, 8))
+	}
+	if len(remote) > 0 && len(roots) > 0 {
+		signal := "SIGTERM"
+		if force {
+			signal = "SIGKILL"
+		}
+		fmt.Fprintf(&summary, "[gray]%d remote process(es) get a plain %s through their agent.[white]\n", len(remote), signal)
+	}
+	if len(errs) > 0 {
+		summary.WriteString("[red]" + tview.Escape(strings.Join(errs, "; ")) + "[white]\n")
+	}
+
+	form := tview.NewForm()
+	graceInput := tview.NewInputField().
+		SetLabel("Grace period: ").
+		SetText(opts.Grace.String()).
+		SetFieldWidth(8)
+	if !force && len(roots) > 0 {
+		form.AddFormItem(graceInput)
+		form.AddCheckbox("Escalate to SIGKILL: ", opts.Escalate, func(checked bool) { opts.Escalate = checked })
+	}
+	if offerTree && len(roots) > 0 {
+		treeLabel := "Include child processes: "
+		if children > 0 {
+			treeLabel = fmt.Sprintf("Include %d child process(es): ", children)
+		}
+		form.AddCheckbox(treeLabel, tree, func(checked bool) { tree = checked })
+	}
+	form.AddButton("Kill", func() {
+		if !force && len(roots) > 0 {
+			d, err := time.ParseDuration(strings.TrimSpace(graceInput.GetText()))
+			if err != nil || d <= 0 {
+				v.SetStatusMessage("Invalid grace period: " + tview.Escape(graceInput.GetText()))
+				return
+			}
+			opts.Grace = d
+		}
+		closeModal()
+		if len(remote) > 0 {
+			go v.killRemote(remote, force)
+		}
+		if len(roots) > 0 {
+			targets := roots
+			if tree {
+				if children == 0 {
+					_, all, _ = killTargets(local, true)
+				}
+				targets = all
+			}
+			v.runKill(label, targets, opts, nil)
+		}
+	})
+	form.AddButton("Cancel", closeModal)
+
+	title := " Kill " + label + " "
+	if force {
+		title = " Force kill (SIGKILL) " + label + " "
+	}
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow)
+	summaryLines := strings.Count(summary.String(), "\n")
+	if summaryLines > 0 {
+		summaryView := tview.NewTextView().SetDynamicColors(true).SetText(summary.String())
+		summaryView.SetBorderPadding(0, 0, 1, 1)
+		layout.AddItem(summaryView, summaryLines, 0, false)
+	}
+	layout.AddItem(form, 0, 1, true)
+	layout.SetBorder(true).SetTitle(title)
+
+	grid := tview.NewGrid().
+		SetColumns(0, 70, 0).
+		SetRows(0, summaryLines+form.GetFormItemCount()*2+5, 0).
+		AddItem(layout, 1, 1, 1, 1, 0, 0, true)
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("kill_confirm", grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
+
+// killTargets resolves the local processes to kill, with tree their
+// descendants too. roots holds the processes themselves and all every target,
+// children first.
+func killTargets(procs []process, tree bool) (roots, all []actions.KillTarget, errs []string) {
+	seen := make(map[int32]bool)
+	for _, p := range procs {
+		targets, err := actions.KillTargets(p.Pid, tree)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("PID %d: %v", p.Pid, err))
+			continue
+		}
+		roots = append(roots, targets[len(targets)-1])
+		for _, t := range targets {
+			if !seen[t.Pid] {
+				seen[t.Pid] = true
+				all = append(all, t)
+			}
+		}
+	}
+	return roots, all, errs
+}
+
+// killRemote asks each agent to kill its processes and reports the outcome.
+func (v *View) killRemote(procs []process, force bool) {
+	var errs []string
+	for _, p := range procs {
+		if err := v.app.killProcess(p.conn, force); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.label(), err))
+		}
+	}
+	v.app.tviewApp.QueueUpdateDraw(func() {
+		msg := fmt.Sprintf("Remote kill: %d of %d sent", len(procs)-len(errs), len(procs))
+		if len(errs) > 0 {
+			msg = "[red]" + msg + ": " + tview.Escape(strings.Join(errs, "; "))
+		}
+		v.SetStatusMessage(msg)
+	})
+	go v.app.loadData()
+}
+
+// runKill shows the progress of the kill and, when processes survive the
+// grace period without automatic escalation, offers SIGKILL. earlier is the
+// report of the SIGTERM that an offered SIGKILL follows, or nil.
+func (v *View) runKill(label string, targets []actions.KillTarget, opts actions.KillOptions, earlier *actions.KillReport) {
+	textView := tview.NewTextView().SetDynamicColors(true)
+	textView.SetBorder(true).SetTitle(" Killing " + label + " ")
+
+	grid := tview.NewGrid().
+		SetColumns(0, 60, 0).
+		SetRows(0, 7, 0).
+		AddItem(textView, 1, 1, 1, 1, 0, 0, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	closeModal := func() {
+		cancel()
+		v.pages.RemovePage("kill_progress")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("kill_progress", grid, true, true)
+	v.app.tviewApp.SetFocus(grid)
+
+	progress := func(p actions.KillProgress) {
+		v.app.tviewApp.QueueUpdateDraw(func() {
+			textView.SetText(killProgressText(p))
+		})
+	}
+
+	go func() {
+		report := actions.GracefulKill(ctx, targets, opts, progress)
+		if earlier != nil {
+			report = earlier.Merge(report)
+		}
+		v.app.tviewApp.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				// Esc already closed the dialog; report how far it got.
+				v.finishKill(label, report)
+				return
+			}
+			closeModal()
+			if len(report.Alive) > 0 && !opts.Force && !opts.Escalate && len(report.Errors) == 0 {
+				v.offerEscalation(label, report, opts)
+				return
+			}
+			v.finishKill(label, report)
+		})
+	}()
+}
+
+func (v *View) offerEscalation(label string, report actions.KillReport, opts actions.KillOptions) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("%d process(es) of %s still running after %s.\n\nSend SIGKILL?", len(report.Alive), label, opts.Grace)).
+		AddButtons([]string{"Send SIGKILL", "Leave running"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			v.pages.RemovePage("kill_escalate")
+			v.app.tviewApp.SetFocus(v.table)
+			if buttonLabel != "Send SIGKILL" {
+				v.finishKill(label, report)
+				return
+			}
+			opts.Force = true
+			v.runKill(label, report.Alive, opts, &report)
+		})
+	v.pages.AddPage("kill_escalate", modal, true, true)
+}
+
+func (v *View) finishKill(label string, report actions.KillReport) {
+	msg := fmt.Sprintf("Kill %s: %s", label, report.Summary())
+	if err := report.Err(); err != nil {
+		msg = "[red]" + msg + ": " + tview.Escape(err.Error())
+	}
+	v.SetStatusMessage(msg)
+	go v.app.loadData()
+}
+
+func killProgressText(p actions.KillProgress) string {
+	const width = 40
+	done := width
+	if p.Limit > 0 && p.Elapsed < p.Limit {
+		done = int(width * p.Elapsed / p.Limit)
+	}
+	bar := strings.Repeat("█", done) + strings.Repeat("░", width-done)
+	return fmt.Sprintf("[yellow]%s[white] sent to %d process(es)\n\n%s %.1fs / %.1fs\n\n%d still running   [gray]Esc stops waiting",
+		p.Stage, p.Total, bar, p.Elapsed.Seconds(), p.Limit.Seconds(), p.Alive)
+}
+
+// showRemoteKillModal asks the agent to kill the process; the agent only
+// offers a plain SIGTERM or SIGKILL.
+func (v *View) showRemoteKillModal(c models.Connection, force bool) {
+	actionText, signal := "kill", "SIGTERM"
+	if force {
+		actionText, signal = "forcefully kill", "SIGKILL"
+	}
+
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Are you sure you want to %s process '%s' (PID: %d)%s?", actionText, c.ProcessName, c.Pid, viaHost(c))).
+		AddButtons([]string{"Confirm", "Cancel"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			v.pages.RemovePage("kill_confirm").ShowPage("main")
+			v.app.tviewApp.SetFocus(v.table)
+			if buttonLabel != "Confirm" {
+				return
+			}
+			if err := v.app.killProcess(c, force); err != nil {
+				v.SetStatusMessage(fmt.Sprintf("[red]Kill %s%s failed: %s", processLabel(c), viaHost(c), tview.Escape(err.Error())))
+				return
+			}
+			v.SetStatusMessage(fmt.Sprintf("Sent %s to %s%s", signal, processLabel(c), viaHost(c)))
+			go v.app.loadData()
+		})
+	v.pages.AddPage("kill_confirm", modal, true, true)
+}
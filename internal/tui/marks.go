@@ -0,0 +1,121 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/rivo/tview"
+)
+
+// selection returns the marked connections, or the selected one when
+// nothing is marked. Batch-capable actions work on this set.
+func (v *View) selection() []models.Connection {
+	if marked := v.app.state.MarkedConnections(); len(marked) > 0 {
+		return marked
+	}
+	if c := v.GetSelectedConnection(); c != nil {
+		return []models.Connection{*c}
+	}
+	return nil
+}
+
+func (v *View) toggleMark() {
+	c := v.GetSelectedConnection()
+	if c == nil {
+		return
+	}
+	v.app.state.ToggleMark(*c)
+	row, _ := v.table.GetSelection()
+	v.Refresh()
+	if row+1 < v.table.GetRowCount() {
+		v.table.Select(row+1, 0)
+	}
+	v.SetStatusMessage(fmt.Sprintf("%d marked", v.app.state.MarkCount()))
+}
+
+func (v *View) markFiltered() {
+	v.app.state.MarkFiltered()
+	v.Refresh()
+	v.SetStatusMessage(fmt.Sprintf("%d marked", v.app.state.MarkCount()))
+}
+
+func (v *View) clearMarks() {
+	v.app.state.ClearMarks()
+	v.Refresh()
+	v.SetStatusMessage("Marks cleared")
+}
+
+// process is one unique process behind a set of connections.
+type process struct {
+	Host  string
+	Pid   int32
+	Name  string
+	Conns int
+	conn  models.Connection // A representative connection
+}
+
+func (p process) label() string {
+	label := fmt.Sprintf("'%s' (PID %d)", p.Name, p.Pid)
+	if p.Host != "" {
+		label += " on " + p.Host
+	}
+	return label
+}
+
+func uniqueProcesses(conns []models.Connection) []process {
+	index := make(map[string]int)
+	var procs []process
+	for _, c := range conns {
+		if c.Pid == 0 {
+			continue
+		}
+		key := c.Host + "|" + strconv.Itoa(int(c.Pid))
+		if i, ok := index[key]; ok {
+			procs[i].Conns++
+			continue
+		}
+		index[key] = len(procs)
+		procs = append(procs, process{Host: c.Host, Pid: c.Pid, Name: c.ProcessName, Conns: 1, conn: c})
+	}
+	return procs
+}
+
+// processSummary lists up to limit processes for a confirmation dialog.
+func processSummary(procs []process, limit int) string {
+	var b strings.Builder
+	for i, p := range procs {
+		if i == limit {
+			fmt.Fprintf(&b, "  ... and %d more\n", len(procs)-limit)
+			break
+		}
+		fmt.Fprintf(&b, "  %s, %d connection(s)\n", tview.Escape(p.label()), p.Conns)
+	}
+	return b.String()
+}
+
+// copySelection puts the marked (or selected) connections on the clipboard
+// as tab-separated lines, using OSC 52 so it also works over SSH.
+func (v *View) copySelection() {
+	conns := v.selection()
+	if len(conns) == 0 {
+		return
+	}
+	if v.app.screen == nil {
+		v.SetStatusMessage("Clipboard is not available.")
+		return
+	}
+
+	var b strings.Builder
+	for _, c := range conns {
+		fields := []string{c.ProcessName, strconv.Itoa(int(c.Pid)), c.Status, c.Type, c.Laddr, c.Raddr}
+		if c.Host != "" {
+			fields = append([]string{c.Host}, fields...)
+		}
+		b.WriteString(strings.Join(fields, "\t"))
+		b.WriteString("\n")
+	}
+	v.app.screen.SetClipboard([]byte(b.String()))
+	v.SetStatusMessage(fmt.Sprintf("Copied %d connection(s) to the clipboard", len(conns)))
+}
@@ -1,11 +1,10 @@
 package tui
 
 import (
-	"context"
+	"cmp"
 	"fmt"
 	"strings"
 
-	"github.com/MrBrooks89/BatStat/internal/actions"
 	"github.com/MrBrooks89/BatStat/internal/models"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -19,17 +18,46 @@ func (v *View) showHelpModal() {
 	builder.WriteString("[green]←/→      [white]Scroll table left/right\n")
 	builder.WriteString("[green]Enter    [white]Show detailed info for selection\n\n")
 	builder.WriteString("[::u]Actions[-:-]\n")
-	builder.WriteString("[green]k        [white]Kill selected process (Graceful)\n")
-	builder.WriteString("[green]K        [white]Force Kill selected process (SIGKILL)\n")
-	builder.WriteString("[green]p        [white]Ping remote address of selection\n")
-	builder.WriteString("[green]n        [white]Nslookup remote address of selection\n")
-	builder.WriteString("[green]t        [white]Traceroute to remote address of selection\n	")
-	builder.WriteString("[green]/        [white]Filter connections\n")
-	builder.WriteString("[green]e        [white]Export visible connections to CSV (with path selection)\n\n")
+	builder.WriteString("[green]k        [white]Kill selected process (SIGTERM, grace period, optional SIGKILL and tree)\n")
+	builder.WriteString("[green]K        [white]Force Kill selected process (SIGKILL, optional tree)\n")
+	builder.WriteString("[green]x        [white]Send any signal, renice or ionice the selected process\n")
+	builder.WriteString("[green]c        [white]Close the selected TCP connection only (SOCK_DESTROY)\n")
+	builder.WriteString("[green]b        [white]Block remote IP (or IP:port) with nftables/iptables\n")
+	builder.WriteString("[green]B        [white]List BatStat blocks ('u' unblocks)\n")
+	builder.WriteString("[green]p        [white]Ping remote address of selection (live stats and sparkline)\n")
+	builder.WriteString("[green]n        [white]DNS lookup of remote address (PTR, forward-confirmed A/AAAA)\n")
+	builder.WriteString("[green]t        [white]Traceroute to remote address (live ICMP/UDP/TCP hop table)\n")
+	builder.WriteString("[green]o        [white]TCP connect probe of remote endpoint, with optional TLS/HTTP checks\n")
+	builder.WriteString("[green]D        [white]Diagnostics menu (built-in and config-defined tools)\n")
+	builder.WriteString("[green]d        [white]History of diagnostics run against the remote address, with comparison\n")
+	builder.WriteString("[green]w        [white]Sweep every remote host in the view (ICMP or TCP connect, sortable, exportable)\n")
+	builder.WriteString("[green]I        [white]Network interfaces with addresses and live rates (Enter filters by interface)\n")
+	builder.WriteString("[green]R        [white]Routing tables and policy rules (Linux; the details pane shows the route in use)\n")
+	builder.WriteString("[green]A        [white]Neighbor table (ARP/NDP) with MAC, state and duplicate-MAC warnings\n")
+	builder.WriteString("[green]C        [white]Conntrack/NAT table with original and reply tuples, linked to local sockets\n")
+	for _, d := range v.app.diagnostics {
+		if d.Func == nil && d.Key != 0 {
+			builder.WriteString(fmt.Sprintf("[green]%-9c[white]%s\n", d.Key, tview.Escape(cmp.Or(d.Description, d.Name))))
+		}
+	}
+	builder.WriteString("[green]/        [white]Filter connections (e.g. state:listen !proc:sshd rport:443)\n")
+	builder.WriteString("[green]e        [white]Export visible connections (CSV, JSON, NDJSON, Markdown, HTML)\n\n")
+	builder.WriteString("[::u]Marking[-:-]\n")
+	builder.WriteString("[green]Space    [white]Mark/unmark selection and move down\n")
+	builder.WriteString("[green]m        [white]Mark every connection matching the filter\n")
+	builder.WriteString("[green]M        [white]Clear all marks\n")
+	builder.WriteString("[green]y        [white]Copy marked (or selected) connections to the clipboard\n")
+	builder.WriteString("[gray]         k/K/x act on every marked process, p/n/t on every marked\n")
+	builder.WriteString("[gray]         remote address, other diagnostics on every marked connection,\n")
+	builder.WriteString("[gray]         and e exports only the marked rows\n\n")
 	builder.WriteString("[::u]Sorting[-:-]\n")
 	builder.WriteString("[green]s        [white]Cycle through sortable columns\n")
 	builder.WriteString("[green]S        [white]Toggle sort order (ASC/DESC)\n\n")
 	builder.WriteString("[::u]Application[-:-]\n")
+	builder.WriteString("[green]a        [white]Show/Hide the alert pane\n")
+	builder.WriteString("[green]i        [white]Show/Hide the summary header (host, load, connection totals)\n")
+	builder.WriteString("[green]g        [white]Show/Hide connection trends (counts by state, protocol, process, host)\n")
+	builder.WriteString("[green]H        [white]Switch between hosts (with remote agents)\n")
 	builder.WriteString("[green]h        [white]Show/Hide this help panel\n")
 	builder.WriteString("[green]r        [white]Refresh connections manually\n")
 	builder.WriteString("[green]q        [white]Quit BatStat\n")
@@ -53,81 +81,42 @@ func (v *View) showHelpModal() {
 	v.pages.AddPage("help_modal", frame, true, true)
 }
 
-func (v *View) showPingModal() {
-	c := v.GetSelectedConnection()
-	if c == nil || c.Raddr == "" || strings.HasPrefix(c.Raddr, ":") {
-		return 
-	}
-	ip := strings.Split(c.Raddr, ":")[0]
-
-	textView := tview.NewTextView().
-		SetDynamicColors(true).
-		SetScrollable(true).
-		SetChangedFunc(func() { v.app.tviewApp.Draw() })
-
-	frame := tview.NewFrame(textView).
-		AddText(fmt.Sprintf("Pinging %s...", ip), true, tview.AlignCenter, tview.Styles.TitleColor).
-		AddText("Press Esc to close", false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	frame.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEscape {
-			cancel() 
-			v.pages.RemovePage("ping_modal")
-			v.app.tviewApp.SetFocus(v.table)
-			return nil
-		}
-		return event
-	})
-
-	outputChan := make(chan string)
-	go actions.Ping(ctx, ip, outputChan)
-
-	go func() {
-		for line := range outputChan {
-			v.app.tviewApp.QueueUpdateDraw(func() {
-				currentText := textView.GetText(false)
-				textView.SetText(currentText + line + "\n")
-				textView.ScrollToEnd()
-			})
-		}
-	}()
-
-	v.pages.AddPage("ping_modal", frame, true, true)
-}
-
-func (v *View) showKillConfirmationModal(force bool) {
+func (v *View) showCloseSocketModal() {
 	c := v.GetSelectedConnection()
-	if c == nil || c.Pid == 0 {
+	if c == nil {
 		return
 	}
-
-	actionText := "kill"
-	actionFunc := func() error { return actions.KillProcess(c.Pid) }
-	if force {
-		actionText = "forcefully kill"
-		actionFunc = func() error { return actions.ForceKillProcess(c.Pid) }
+	if c.Type != "TCP" || !c.HasRemote() {
+		v.SetStatusMessage("Only connected TCP sockets can be closed.")
+		return
 	}
 
 	modal := tview.NewModal().
-		SetText(fmt.Sprintf("Are you sure you want to %s process '%s' (PID: %d)?", actionText, c.ProcessName, c.Pid)).
-		AddButtons([]string{"Confirm", "Cancel"}).
+		SetText(fmt.Sprintf("Close the connection %s -> %s of '%s' (PID: %d)?\n\nThe process keeps running; the socket is destroyed as with 'ss -K'.",
+			c.Laddr, c.Raddr, c.ProcessName, c.Pid)).
+		AddButtons([]string{"Close connection", "Cancel"}).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-			if buttonLabel == "Confirm" {
-				if err := actionFunc(); err == nil {
-					go v.app.loadData()
-				}
-			}
-			v.pages.RemovePage("kill_confirm").ShowPage("main")
+			v.pages.RemovePage("close_confirm").ShowPage("main")
 			v.app.tviewApp.SetFocus(v.table)
+			if buttonLabel != "Close connection" {
+				return
+			}
+			if err := v.app.closeSocket(*c); err != nil {
+				v.SetStatusMessage("[red]Close failed: " + tview.Escape(err.Error()))
+				return
+			}
+			v.SetStatusMessage(fmt.Sprintf("Closed %s -> %s", c.Laddr, c.Raddr))
+			go v.app.loadData()
 		})
-	v.pages.AddPage("kill_confirm", modal, true, true)
+	v.pages.AddPage("close_confirm", modal, true, true)
 }
 
 func (v *View) showDetailsModal(c models.Connection) {
-	details := models.GetDetailedInfo(c.Pid)
+	details := v.app.detailsFor(c)
 	var builder strings.Builder
+	if c.Host != "" {
+		builder.WriteString(fmt.Sprintf("[yellow]Host:[white]       %s\n", c.Host))
+	}
 	builder.WriteString(fmt.Sprintf("[yellow]Process:[white]    %s\n", c.ProcessName))
 	builder.WriteString(fmt.Sprintf("[yellow]PID:[white]        %d\n", c.Pid))
 	builder.WriteString(fmt.Sprintf("[yellow]User:[white]       %s\n\n", details.Username))
@@ -155,90 +144,71 @@ func (v *View) showDetailsModal(c models.Connection) {
 	v.pages.AddPage("details_modal", frame, true, true)
 }
 
-func (v *View) showNslookupModal() {
-	c := v.GetSelectedConnection()
-	if c == nil || c.Raddr == "" || strings.HasPrefix(c.Raddr, ":") {
-		return 
+func (v *View) showHostSwitcher() {
+	hosts := v.app.state.Hosts()
+	switch {
+	case len(v.app.agentList) == 0:
+		v.SetStatusMessage("No remote agents configured.")
+		return
+	case len(hosts) < 2:
+		v.SetStatusMessage("Only " + tview.Escape(hosts[0]) + " is shown; there is no other host to switch to.")
+		return
 	}
-	host := strings.Split(c.Raddr, ":")[0]
 
-	textView := tview.NewTextView().
-		SetDynamicColors(true).
-		SetScrollable(true).
-		SetChangedFunc(func() { v.app.tviewApp.Draw() })
-
-	frame := tview.NewFrame(textView).
-		AddText(fmt.Sprintf("Nslookup for %s...", host), true, tview.AlignCenter, tview.Styles.TitleColor).
-		AddText("Press Esc to close", false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+	counts, total := v.app.state.HostCounts()
 
-	ctx, cancel := context.WithCancel(context.Background())
-
-	frame.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEscape {
-			cancel() 
-			v.pages.RemovePage("nslookup_modal")
-			v.app.tviewApp.SetFocus(v.table)
-			return nil
+	current := v.app.state.HostFilter()
+	closeModal := func() {
+		v.pages.RemovePage("host_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+	choose := func(host string) {
+		closeModal()
+		v.app.state.SetHostFilter(host)
+		v.Refresh()
+		if host == "" {
+			host = "all hosts"
 		}
-		return event
-	})
-
-	outputChan := make(chan string)
-	go actions.Nslookup(ctx, host, outputChan)
+		v.SetStatusMessage("Showing " + host)
+	}
 
-	go func() {
-		for line := range outputChan {
-			v.app.tviewApp.QueueUpdateDraw(func() {
-				currentText := textView.GetText(false)
-				textView.SetText(currentText + line + "\n")
-				textView.ScrollToEnd()
-			})
+	list := tview.NewList()
+	marker := func(host string) string {
+		if host == current {
+			return "* "
 		}
-	}()
-
-	v.pages.AddPage("nslookup_modal", frame, true, true)
-}
-
-func (v *View) showTracerouteModal() {
-	c := v.GetSelectedConnection()
-	if c == nil || c.Raddr == "" || strings.HasPrefix(c.Raddr, ":") {
-		return 
+		return "  "
 	}
-	host := strings.Split(c.Raddr, ":")[0]
-
-	textView := tview.NewTextView().
-		SetDynamicColors(true).
-		SetScrollable(true).
-		SetChangedFunc(func() { v.app.tviewApp.Draw() })
-
-	frame := tview.NewFrame(textView).
-		AddText(fmt.Sprintf("Traceroute to %s...", host), true, tview.AlignCenter, tview.Styles.TitleColor).
-		AddText("Press Esc to close", false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
-
-	ctx, cancel := context.WithCancel(context.Background())
+	list.AddItem(marker("")+"All hosts", fmt.Sprintf("  %d connections", total), 0, func() { choose("") })
+	for _, host := range hosts {
+		secondary := fmt.Sprintf("  %d connections", counts[host])
+		if err := v.app.state.HostError(host); err != nil {
+			secondary = "  [red]" + tview.Escape(err.Error())
+		}
+		list.AddItem(marker(host)+host, secondary, 0, func() { choose(host) })
+	}
+	list.SetBorder(true).SetTitle(" Hosts ")
 
-	frame.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEscape {
-			cancel() 
-			v.pages.RemovePage("traceroute_modal")
-			v.app.tviewApp.SetFocus(v.table)
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'H' {
+			closeModal()
 			return nil
 		}
 		return event
 	})
 
-	outputChan := make(chan string)
-	go actions.Traceroute(ctx, host, outputChan)
+	grid := tview.NewGrid().
+		SetColumns(0, 60, 0).
+		SetRows(0, min(2*len(hosts)+4, 24), 0).
+		AddItem(list, 1, 1, 1, 1, 0, 0, true)
 
-	go func() {
-		for line := range outputChan {
-			v.app.tviewApp.QueueUpdateDraw(func() {
-				currentText := textView.GetText(false)
-				textView.SetText(currentText + line + "\n")
-				textView.ScrollToEnd()
-			})
-		}
-	}()
+	v.pages.AddPage("host_modal", grid, true, true)
+	v.app.tviewApp.SetFocus(list)
+}
 
-	v.pages.AddPage("traceroute_modal", frame, true, true)
+func viaHost(c models.Connection) string {
+	if c.Host == "" {
+		return ""
+	}
+	return " on " + c.Host
 }
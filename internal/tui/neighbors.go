@@ -0,0 +1,182 @@
+package tui
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/neigh"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const neighborKeys = "Enter Show its connections  r Reload  Esc Close"
+
+var neighborColumns = []string{"Address", "MAC", "Interface", "State", "Family", "Conns", "Note"}
+
+func (v *View) showNeighborsModal() {
+	statusView := tview.NewTextView().SetDynamicColors(true)
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 1)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(statusView, 1, 0, false).
+		AddItem(table, 0, 1, true)
+	layout.SetBorderPadding(0, 0, 1, 1)
+
+	frame := tview.NewFrame(layout).
+		AddText("Neighbor table (ARP/NDP)", true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(neighborKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	var neighbors []neigh.Neighbor
+	load := func() {
+		var err error
+		if neighbors, err = neigh.List(); err != nil {
+			statusView.SetText("[red]" + tview.Escape(err.Error()))
+			return
+		}
+		neigh.Sort(neighbors)
+		dups := neigh.Duplicates(neighbors)
+		renderNeighbors(table, neighbors, dups, v.app.remoteCounts())
+
+		status := fmt.Sprintf("[yellow]Neighbors:[white] %d", len(neighbors))
+		if len(dups) > 0 {
+			status += fmt.Sprintf("  [red]%d MAC addresses answer for several IPv4 addresses (possible ARP spoofing)[white]", len(dups))
+		}
+		statusView.SetText(status)
+	}
+
+	closeModal := func() {
+		v.pages.RemovePage("neighbor_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+	table.SetSelectedFunc(func(row, column int) {
+		if row < 1 || row > len(neighbors) {
+			return
+		}
+		closeModal()
+		term := "raddr:" + neighbors[row-1].IP.String()
+		if v.app.state.MultiHost() {
+			term = "host:" + v.app.localHost + " " + term
+		}
+		v.filterInput.SetText(term)
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			closeModal()
+			return nil
+		case event.Rune() == 'r':
+			load()
+			return nil
+		}
+		return event
+	})
+
+	load()
+	v.pages.AddPage("neighbor_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(table)
+}
+
+func renderNeighbors(table *tview.Table, neighbors []neigh.Neighbor, dups map[string][]netip.Addr, conns map[netip.Addr]int) {
+	table.Clear()
+	for i, title := range neighborColumns {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false))
+	}
+
+	for r, n := range neighbors {
+		var notes []string
+		if n.Router {
+			notes = append(notes, "router")
+		}
+		others := neigh.DuplicateOf(dups, n)
+		if len(others) > 0 {
+			notes = append(notes, "duplicate MAC, also "+joinAddrs(others))
+		}
+		count := ""
+		if c := conns[n.IP]; c > 0 {
+			count = fmt.Sprint(c)
+		}
+
+		cells := []string{n.IP.String(), n.MAC, n.Iface, n.State, n.Family, count, strings.Join(notes, "; ")}
+		for c, text := range cells {
+			cell := tview.NewTableCell(tview.Escape(text))
+			switch c {
+			case 3:
+				cell.SetTextColor(neighborStateColor(n.State))
+			case 5:
+				cell.SetAlign(tview.AlignRight)
+			}
+			if len(others) > 0 && (c == 1 || c == 6) {
+				cell.SetTextColor(tcell.ColorRed)
+			}
+			table.SetCell(r+1, c, cell)
+		}
+	}
+}
+
+func neighborStateColor(state string) tcell.Color {
+	switch state {
+	case "REACHABLE":
+		return tcell.ColorGreen
+	case "STALE", "DELAY", "PROBE":
+		return tcell.ColorYellow
+	case "FAILED", "INCOMPLETE":
+		return tcell.ColorRed
+	}
+	return tview.Styles.PrimaryTextColor
+}
+
+func joinAddrs(addrs []netip.Addr) string {
+	s := make([]string, len(addrs))
+	for i, a := range addrs {
+		s[i] = a.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// remoteCounts counts this machine's connections per remote address.
+func (a *App) remoteCounts() map[netip.Addr]int {
+	counts := make(map[netip.Addr]int)
+	for _, c := range a.state.Connections() {
+		if _, ok := a.remote(c); ok || !c.HasRemote() {
+			continue
+		}
+		if addr, err := netip.ParseAddr(c.RemoteIP()); err == nil {
+			counts[addr.Unmap()]++
+		}
+	}
+	return counts
+}
+
+// neighborDetails shows the neighbor entry of an on-link peer for the
+// details pane, from the table read at the last refresh.
+func (a *App) neighborDetails(c models.Connection) string {
+	if _, ok := a.remote(c); ok || !c.HasRemote() {
+		return ""
+	}
+	ip, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return ""
+	}
+	n, ok := neigh.Find(a.neighbors, ip)
+	if !ok {
+		return ""
+	}
+
+	var b strings.Builder
+	mac := n.MAC
+	if mac == "" {
+		mac = "unresolved"
+	}
+	fmt.Fprintf(&b, "[yellow]Neighbor:[white]   %s on %s [%s]%s[white]\n",
+		mac, tview.Escape(n.Iface), colorTag(neighborStateColor(n.State)), n.State)
+	if others := neigh.DuplicateOf(a.neighborDups, n); len(others) > 0 {
+		fmt.Fprintf(&b, "[red::b]Duplicate MAC:[-::-] also %s, possible ARP spoofing\n", joinAddrs(others))
+	}
+	return b.String()
+}
@@ -0,0 +1,446 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	// outputScrollback is how many lines an output viewer keeps.
+	outputScrollback = 10000
+	// outputFlushDelay batches fast output into a few redraws per second.
+	outputFlushDelay = 50 * time.Millisecond
+)
+
+const outputKeys = "Esc Close  / Search  n/N Next/Prev  f Follow/Pause  s Save  y Copy"
+
+type outputLine struct {
+	text  string // Plain text, escaped when shown
+	color string // tview color name, empty for the default
+}
+
+// outputViewer shows streamed command output with bounded scrollback, a
+// follow/pause toggle, incremental search, saving and copying. print and
+// finish may be called from any goroutine; everything else runs on the UI
+// goroutine.
+type outputViewer struct {
+	*tview.Flex
+	v       *View
+	name    string // Base of the default file name
+	onClose func()
+
+	text   *tview.TextView
+	footer *tview.Pages
+	status *tview.TextView
+	search *tview.InputField
+
+	lines  []outputLine // Ring buffer, oldest at head once full
+	head   int
+	purged int // Lines dropped since the text view was last rebuilt
+
+	follow bool
+	unseen int // Lines added while paused
+
+	query   string
+	pattern *regexp.Regexp
+	next    int // Region ID of the next match
+	first   int // Region ID of the oldest match still shown
+	current int // Highlighted match, -1 for none
+
+	started time.Time
+	result  string
+
+	mu      sync.Mutex
+	pending []outputLine
+}
+
+func newOutputViewer(v *View, name string, onClose func()) *outputViewer {
+	o := &outputViewer{
+		v:       v,
+		name:    name,
+		onClose: onClose,
+		follow:  true,
+		current: -1,
+		started: time.Now(),
+	}
+
+	o.text = tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetScrollable(true)
+	o.text.SetBorderPadding(0, 0, 1, 1)
+
+	o.status = tview.NewTextView().SetDynamicColors(true)
+	o.status.SetBorderPadding(0, 0, 1, 1)
+	o.search = tview.NewInputField().
+		SetLabel("Search: ").
+		SetChangedFunc(o.setQuery)
+	o.search.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			o.search.SetText("")
+		}
+		o.footer.SwitchToPage("status")
+		o.v.app.tviewApp.SetFocus(o.text)
+		o.updateStatus()
+	})
+	o.footer = tview.NewPages().
+		AddPage("status", o.status, true, true).
+		AddPage("search", o.search, true, false)
+
+	o.Flex = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(o.text, 0, 1, true).
+		AddItem(o.footer, 1, 0, false)
+	o.SetInputCapture(o.handleKey)
+	o.updateStatus()
+	return o
+}
+
+func (o *outputViewer) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if o.search.HasFocus() {
+		return event
+	}
+	switch event.Key() {
+	case tcell.KeyEscape:
+		o.onClose()
+		return nil
+	case tcell.KeyUp, tcell.KeyPgUp, tcell.KeyHome, tcell.KeyCtrlB:
+		o.setFollow(false)
+		return event
+	case tcell.KeyEnd:
+		o.setFollow(true)
+		return event
+	}
+	switch event.Rune() {
+	case '/':
+		o.footer.SwitchToPage("search")
+		o.v.app.tviewApp.SetFocus(o.search)
+		return nil
+	case 'n':
+		o.jump(1)
+		return nil
+	case 'N':
+		o.jump(-1)
+		return nil
+	case 'f':
+		o.setFollow(!o.follow)
+		return nil
+	case 's':
+		o.showSaveForm()
+		return nil
+	case 'y':
+		o.copy()
+		return nil
+	case 'k', 'g':
+		o.setFollow(false)
+	case 'G':
+		o.setFollow(true)
+	}
+	return event
+}
+
+// print appends a line. Lines are handed to the UI goroutine in batches so
+// fast output does not redraw the screen for every line.
+func (o *outputViewer) print(color, text string) {
+	o.mu.Lock()
+	o.pending = append(o.pending, outputLine{text: text, color: color})
+	first := len(o.pending) == 1
+	o.mu.Unlock()
+	if first {
+		time.AfterFunc(outputFlushDelay, func() { o.v.app.tviewApp.QueueUpdateDraw(o.flush) })
+	}
+}
+
+// finish records how the run ended and how long it took.
+func (o *outputViewer) finish(result string, failed bool) {
+	o.finishAfter(result, failed, time.Since(o.started))
+}
+
+// finishAfter is finish for runs that did not start with the viewer, such as
+// those replayed from the history.
+func (o *outputViewer) finishAfter(result string, failed bool, elapsed time.Duration) {
+	elapsed = elapsed.Round(time.Millisecond)
+	color := "green"
+	if failed {
+		color = "red"
+	}
+	o.print(color, fmt.Sprintf("── %s after %s ──", result, elapsed))
+	time.AfterFunc(outputFlushDelay, func() {
+		o.v.app.tviewApp.QueueUpdateDraw(func() {
+			o.flush()
+			o.result = fmt.Sprintf("[%s]%s[white] after %s", color, tview.Escape(result), elapsed)
+			o.updateStatus()
+		})
+	})
+}
+
+func (o *outputViewer) flush() {
+	o.mu.Lock()
+	pending := o.pending
+	o.pending = nil
+	o.mu.Unlock()
+
+	for _, line := range pending {
+		o.add(line)
+	}
+	if o.purged > outputScrollback/10 {
+		o.render()
+	}
+	if o.follow {
+		o.text.ScrollToEnd()
+	}
+	o.updateStatus()
+}
+
+func (o *outputViewer) add(line outputLine) {
+	if len(o.lines) < outputScrollback {
+		o.lines = append(o.lines, line)
+	} else {
+		o.first += len(o.matchesIn(o.lines[o.head].text))
+		o.lines[o.head] = line
+		o.head = (o.head + 1) % outputScrollback
+		o.purged++
+	}
+	if !o.follow {
+		o.unseen++
+	}
+
+	prefix := "\n"
+	if len(o.lines) == 1 && o.purged == 0 {
+		prefix = ""
+	}
+	fmt.Fprint(o.text, prefix+o.format(line))
+}
+
+// all returns the buffered lines, oldest first.
+func (o *outputViewer) all() []outputLine {
+	if len(o.lines) < outputScrollback {
+		return o.lines
+	}
+	return append(append([]outputLine(nil), o.lines[o.head:]...), o.lines[:o.head]...)
+}
+
+// render rebuilds the text view from the buffer, renumbering the matches.
+func (o *outputViewer) render() {
+	if o.current >= 0 {
+		o.current -= o.first
+	}
+	o.next, o.first, o.purged = 0, 0, 0
+
+	var b strings.Builder
+	for i, line := range o.all() {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(o.format(line))
+	}
+	o.text.SetText(b.String())
+	if o.current < 0 || o.current >= o.next {
+		o.current = -1
+		o.text.Highlight()
+	} else {
+		o.text.Highlight(matchRegion(o.current))
+	}
+}
+
+func (o *outputViewer) format(line outputLine) string {
+	var b strings.Builder
+	if line.color != "" {
+		b.WriteString("[" + line.color + "]")
+	}
+	last := 0
+	for _, m := range o.matchesIn(line.text) {
+		b.WriteString(tview.Escape(line.text[last:m[0]]))
+		fmt.Fprintf(&b, `["%s"][:yellow]%s[:-][""]`, matchRegion(o.next), tview.Escape(line.text[m[0]:m[1]]))
+		o.next++
+		last = m[1]
+	}
+	b.WriteString(tview.Escape(line.text[last:]))
+	if line.color != "" {
+		b.WriteString("[-]")
+	}
+	return b.String()
+}
+
+func (o *outputViewer) matchesIn(text string) [][]int {
+	if o.pattern == nil {
+		return nil
+	}
+	return o.pattern.FindAllStringIndex(text, -1)
+}
+
+func matchRegion(id int) string {
+	return fmt.Sprintf("m%d", id)
+}
+
+// setQuery highlights every match of text and jumps to the first one.
+func (o *outputViewer) setQuery(text string) {
+	o.query, o.pattern = text, nil
+	if text != "" {
+		o.pattern = regexp.MustCompile("(?i)" + regexp.QuoteMeta(text))
+	}
+	o.current = -1
+	o.render()
+	if o.next > o.first {
+		o.jump(1)
+	}
+	o.updateStatus()
+}
+
+// jump highlights the next (dir 1) or previous (dir -1) match.
+func (o *outputViewer) jump(dir int) {
+	if o.next == o.first {
+		return
+	}
+	o.setFollow(false)
+	switch {
+	case o.current < o.first:
+		o.current = o.first
+		if dir < 0 {
+			o.current = o.next - 1
+		}
+	default:
+		o.current += dir
+		if o.current >= o.next {
+			o.current = o.first
+		}
+		if o.current < o.first {
+			o.current = o.next - 1
+		}
+	}
+	o.text.Highlight(matchRegion(o.current)).ScrollToHighlight()
+	o.updateStatus()
+}
+
+func (o *outputViewer) setFollow(follow bool) {
+	o.follow = follow
+	if follow {
+		o.unseen = 0
+		o.text.ScrollToEnd()
+	} else {
+		row, col := o.text.GetScrollOffset()
+		o.text.ScrollTo(row, col)
+	}
+	o.updateStatus()
+}
+
+func (o *outputViewer) updateStatus() {
+	var parts []string
+	if o.follow {
+		parts = append(parts, "[green]following[white]")
+	} else if o.unseen > 0 {
+		parts = append(parts, fmt.Sprintf("[yellow]paused, %d new[white]", o.unseen))
+	} else {
+		parts = append(parts, "[yellow]paused[white]")
+	}
+	parts = append(parts, fmt.Sprintf("%d lines", len(o.lines)))
+	if o.query != "" {
+		if n := o.next - o.first; n == 0 {
+			parts = append(parts, "[red]no match for "+tview.Escape(o.query)+"[white]")
+		} else if o.current >= o.first {
+			parts = append(parts, fmt.Sprintf("match %d/%d", o.current-o.first+1, n))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d matches", n))
+		}
+	}
+	if o.result != "" {
+		parts = append(parts, o.result)
+	} else {
+		parts = append(parts, "[gray]running...[white]")
+	}
+	o.status.SetText(strings.Join(parts, "  │  "))
+}
+
+func (o *outputViewer) plainLines() []string {
+	lines := make([]string, 0, len(o.lines))
+	for _, line := range o.all() {
+		lines = append(lines, line.text)
+	}
+	return lines
+}
+
+func (o *outputViewer) copy() {
+	o.status.SetText(o.v.copyLines(o.plainLines()))
+}
+
+func (o *outputViewer) showSaveForm() {
+	o.v.showSaveForm(o.name, o.plainLines, func(status string) {
+		o.v.app.tviewApp.SetFocus(o.text)
+		if status != "" {
+			o.status.SetText(status)
+		}
+	})
+}
+
+// copyLines puts lines on the clipboard with OSC 52, which also works over
+// SSH in terminals that support it, and describes the outcome.
+func (v *View) copyLines(lines []string) string {
+	if v.app.screen == nil {
+		return "[red]Clipboard is not available."
+	}
+	v.app.screen.SetClipboard([]byte(joinLines(lines)))
+	return fmt.Sprintf("Copied %d lines to the clipboard", len(lines))
+}
+
+func joinLines(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// showSaveForm asks for a file to write the lines returned by content to,
+// suggesting one named after name. done gets the outcome, or "" when the
+// form was cancelled, and restores the focus.
+func (v *View) showSaveForm(name string, content func() []string, done func(status string)) {
+	defaultPath := "batstat_" + unsafeFileChars.ReplaceAllString(name, "_") + ".txt"
+	closeForm := func(status string) {
+		v.pages.RemovePage("output_save")
+		done(status)
+	}
+
+	pathInput := tview.NewInputField().
+		SetLabel("File path: ").
+		SetPlaceholder("blank for " + defaultPath).
+		SetFieldWidth(40)
+	form := tview.NewForm().AddFormItem(pathInput)
+	form.AddButton("Save", func() {
+		path := strings.TrimSpace(pathInput.GetText())
+		if path == "" {
+			path = defaultPath
+		}
+		lines := content()
+		if err := os.WriteFile(path, []byte(joinLines(lines)), 0o644); err != nil {
+			closeForm("[red]Error saving: " + tview.Escape(err.Error()))
+			return
+		}
+		closeForm(fmt.Sprintf("Saved %d lines to %s", len(lines), tview.Escape(path)))
+	})
+	form.AddButton("Cancel", func() { closeForm("") })
+	form.SetBorder(true).SetTitle(" Save output ")
+
+	grid := tview.NewGrid().
+		SetColumns(0, 60, 0).
+		SetRows(0, 7, 0).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeForm("")
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("output_save", grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
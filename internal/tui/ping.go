@@ -0,0 +1,207 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/history"
+	"github.com/MrBrooks89/BatStat/internal/probe"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const pingHistory = 500
+
+func (v *View) showPingModal() {
+	c := v.GetSelectedConnection()
+	if c == nil || !c.HasRemote() {
+		return
+	}
+	if _, ok := v.app.remote(*c); ok {
+		if d, ok := v.builtin("ping"); ok {
+			v.showDiagModal(d, *c)
+		}
+		return
+	}
+	ip := c.RemoteIP()
+	opts := v.app.pingOptions
+
+	closeModal := func() {
+		v.pages.RemovePage("ping_settings")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	countInput := tview.NewInputField().
+		SetLabel("Count (0 = until Esc): ").
+		SetText(strconv.Itoa(opts.Count)).
+		SetFieldWidth(6).
+		SetAcceptanceFunc(tview.InputFieldInteger)
+	intervalInput := tview.NewInputField().
+		SetLabel("Interval: ").
+		SetText(opts.Interval.String()).
+		SetFieldWidth(8)
+	sizeInput := tview.NewInputField().
+		SetLabel("Payload size (bytes): ").
+		SetText(strconv.Itoa(opts.Size)).
+		SetFieldWidth(6).
+		SetAcceptanceFunc(tview.InputFieldInteger)
+
+	form := tview.NewForm().
+		AddFormItem(countInput).
+		AddFormItem(intervalInput).
+		AddFormItem(sizeInput)
+	form.AddButton("Start", func() {
+		count, err := strconv.Atoi(countInput.GetText())
+		if err != nil || count < 0 {
+			v.SetStatusMessage("[red]Count must be 0 or more")
+			return
+		}
+		interval, err := time.ParseDuration(strings.TrimSpace(intervalInput.GetText()))
+		if err != nil || interval <= 0 {
+			v.SetStatusMessage("[red]Invalid interval: " + tview.Escape(intervalInput.GetText()))
+			return
+		}
+		size, err := strconv.Atoi(sizeInput.GetText())
+		if err != nil || size < 0 || size > probe.MaxPingSize {
+			v.SetStatusMessage(fmt.Sprintf("[red]Size must be between 0 and %d bytes", probe.MaxPingSize))
+			return
+		}
+		opts.Count, opts.Interval, opts.Size = count, interval, size
+		v.app.pingOptions = opts
+		closeModal()
+		v.runPing(ip, opts)
+	})
+	form.AddButton("Cancel", closeModal)
+	form.SetBorder(true).SetTitle(" Ping " + ip + " ")
+	// Start is focused so Enter pings straight away with the last settings.
+	form.SetFocus(form.GetFormItemCount())
+
+	grid := tview.NewGrid().
+		SetColumns(0, 50, 0).
+		SetRows(0, 11, 0).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("ping_settings", grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
+
+// runPing shows live statistics, a latency sparkline and the replies of a
+// native ping until the count is reached or Esc is pressed.
+func (v *View) runPing(ip string, opts probe.PingOptions) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := newOutputViewer(v, "ping_"+ip, func() {
+		cancel()
+		v.pages.RemovePage("ping_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	})
+	statsView := tview.NewTextView().SetDynamicColors(true)
+	statsView.SetBorderPadding(0, 0, 1, 1)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(statsView, 4, 0, false).
+		AddItem(out, 0, 1, true)
+
+	frame := tview.NewFrame(layout).
+		AddText(fmt.Sprintf("Pinging %s...", ip), true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(outputKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	// Only touched on the UI goroutine.
+	var rtts []float64
+	var last time.Duration
+	showStats := func(stats probe.PingStats) {
+		_, _, width, _ := statsView.GetInnerRect()
+		statsView.SetText(latencyText(stats, last, rtts, width))
+	}
+
+	go func() {
+		start := time.Now()
+		var output []string
+		stats, err := probe.Ping(ctx, ip, opts, func(r probe.PingReply, stats probe.PingStats) {
+			output = append(output, r.String())
+			color := ""
+			if r.Lost {
+				color = "red"
+			}
+			out.print(color, r.String())
+			v.app.tviewApp.QueueUpdateDraw(func() {
+				if r.Lost {
+					rtts = append(rtts, -1)
+				} else {
+					last = r.RTT
+					rtts = append(rtts, float64(r.RTT))
+				}
+				if len(rtts) > pingHistory {
+					rtts = rtts[len(rtts)-pingHistory:]
+				}
+				showStats(stats)
+			})
+		})
+		if err == nil && stats.Sent > 0 {
+			output = append(output, "", stats.String(), stats.RTTSummary())
+			v.app.recordRun(history.Run{
+				Time:   start,
+				Target: ip,
+				Tool:   "ping",
+				Params: map[string]string{
+					"count":    strconv.Itoa(opts.Count),
+					"interval": opts.Interval.String(),
+					"size":     strconv.Itoa(opts.Size),
+				},
+				Elapsed: time.Since(start),
+				Ping:    &stats,
+				Output:  output,
+			})
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			out.finish("Ping failed: "+err.Error(), true)
+			return
+		}
+		v.app.tviewApp.QueueUpdateDraw(func() { showStats(stats) })
+		out.print("", "")
+		out.print("", fmt.Sprintf("--- %s ping statistics ---", ip))
+		out.print("", stats.String())
+		out.print("", stats.RTTSummary())
+		out.finish(fmt.Sprintf("%.1f%% packet loss", stats.Loss()), stats.Received == 0)
+	}()
+
+	v.pages.AddPage("ping_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(out)
+}
+
+// latencyText renders loss, round-trip statistics and a sparkline of rtts,
+// where lost probes are negative.
+func latencyText(stats probe.PingStats, last time.Duration, rtts []float64, width int) string {
+	lossColor := "green"
+	if stats.Received < stats.Sent {
+		lossColor = "red"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]Sent:[white] %d  [yellow]Received:[white] %d  [yellow]Loss:[%s] %.1f%%[white]\n",
+		stats.Sent, stats.Received, lossColor, stats.Loss())
+	if stats.Received > 0 {
+		fmt.Fprintf(&b, "[yellow]Last:[white] %s ms  [yellow]min/avg/max/mdev:[white] %s/%s/%s/%s ms\n",
+			formatRTT(last), formatRTT(stats.Min), formatRTT(stats.Avg), formatRTT(stats.Max), formatRTT(stats.Mdev))
+	} else {
+		b.WriteString("[gray]Waiting for replies...[white]\n")
+	}
+	b.WriteString(sparkline(rtts, width, "green"))
+	return b.String()
+}
+
+func formatRTT(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,261 @@
+package tui
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/route"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const routeKeys = "Tab Routes/Rules  l Local table  r Reload  Esc Close"
+
+var (
+	routeColumns = []string{"Family", "Table", "Destination", "Gateway", "Interface", "Source", "Metric", "Protocol", "Scope", "Type"}
+	ruleColumns  = []string{"Family", "Priority", "Selector", "Action"}
+)
+
+func (v *View) showRoutesModal() {
+	statusView := tview.NewTextView().SetDynamicColors(true)
+	routeTable := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	ruleTable := tview.NewTable().SetSelectable(false, false).SetFixed(1, 0)
+	routeTable.SetBorder(true).SetTitle(" Routes ")
+	ruleTable.SetBorder(true).SetTitle(" Policy rules ")
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(statusView, 1, 0, false).
+		AddItem(routeTable, 0, 2, true).
+		AddItem(ruleTable, 0, 1, false)
+	layout.SetBorderPadding(0, 0, 1, 1)
+
+	frame := tview.NewFrame(layout).
+		AddText("Routing tables", true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(routeKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	showLocal := false
+	load := func() {
+		routes, err := route.Routes()
+		if err != nil {
+			statusView.SetText("[red]" + tview.Escape(err.Error()))
+			return
+		}
+		rules, err := route.Rules()
+		if err != nil {
+			statusView.SetText("[red]" + tview.Escape(err.Error()))
+			return
+		}
+
+		hidden := 0
+		if !showLocal {
+			routes = slices.DeleteFunc(routes, func(r route.Route) bool {
+				if r.Table == route.TableLocal {
+					hidden++
+					return true
+				}
+				return false
+			})
+		}
+		slices.SortStableFunc(routes, compareRoutes)
+		slices.SortStableFunc(rules, func(a, b route.Rule) int {
+			return cmp.Or(cmp.Compare(a.Family, b.Family), cmp.Compare(a.Priority, b.Priority))
+		})
+		renderRoutes(routeTable, routes)
+		renderRules(ruleTable, rules)
+		routeTable.ScrollToBeginning()
+
+		status := fmt.Sprintf("[yellow]Routes:[white] %d  [yellow]Rules:[white] %d", len(routes), len(rules))
+		if hidden > 0 {
+			status += fmt.Sprintf("  [gray]%d local table entries hidden (l)[white]", hidden)
+		}
+		statusView.SetText(status)
+	}
+
+	closeModal := func() {
+		v.pages.RemovePage("routes_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+	capture := func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			closeModal()
+			return nil
+		case event.Key() == tcell.KeyTab || event.Key() == tcell.KeyBacktab:
+			focusRoutes := ruleTable.HasFocus()
+			routeTable.SetSelectable(focusRoutes, false)
+			ruleTable.SetSelectable(!focusRoutes, false)
+			if focusRoutes {
+				v.app.tviewApp.SetFocus(routeTable)
+			} else {
+				v.app.tviewApp.SetFocus(ruleTable)
+			}
+			return nil
+		case event.Rune() == 'l':
+			showLocal = !showLocal
+			load()
+			return nil
+		case event.Rune() == 'r':
+			load()
+			return nil
+		}
+		return event
+	}
+	routeTable.SetInputCapture(capture)
+	ruleTable.SetInputCapture(capture)
+
+	load()
+	v.pages.AddPage("routes_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(routeTable)
+}
+
+// compareRoutes orders by family, then table (main first, local last), then
+// the most specific destination first, then metric.
+func compareRoutes(a, b route.Route) int {
+	return cmp.Or(
+		cmp.Compare(a.Family, b.Family),
+		cmp.Compare(tableOrder(a.Table), tableOrder(b.Table)),
+		cmp.Compare(a.Table, b.Table),
+		cmp.Compare(b.Dst.Bits(), a.Dst.Bits()),
+		a.Dst.Addr().Compare(b.Dst.Addr()),
+		cmp.Compare(a.Metric, b.Metric),
+	)
+}
+
+func tableOrder(table uint32) int {
+	switch table {
+	case route.TableMain:
+		return 0
+	case route.TableDefault:
+		return 2
+	case route.TableLocal:
+		return 3
+	}
+	return 1
+}
+
+func renderRoutes(table *tview.Table, routes []route.Route) {
+	table.Clear()
+	for i, title := range routeColumns {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false))
+	}
+	for r, rt := range routes {
+		gateways, ifaces := routeHops(rt)
+		metric := ""
+		if rt.Metric != 0 {
+			metric = strconv.FormatUint(uint64(rt.Metric), 10)
+		}
+		color := tview.Styles.PrimaryTextColor
+		switch {
+		case rt.IsDefault():
+			color = tcell.ColorGreen
+		case rt.Type == "blackhole" || rt.Type == "unreachable" || rt.Type == "prohibit":
+			color = tcell.ColorRed
+		}
+		cells := []string{rt.Family, route.TableName(rt.Table), rt.Destination(), gateways, ifaces,
+			addrString(rt.Src), metric, rt.Protocol, rt.Scope, rt.Type}
+		for c, text := range cells {
+			cell := tview.NewTableCell(tview.Escape(text))
+			switch c {
+			case 2:
+				cell.SetTextColor(color)
+			case 6:
+				cell.SetAlign(tview.AlignRight)
+			}
+			table.SetCell(r+1, c, cell)
+		}
+	}
+}
+
+func renderRules(table *tview.Table, rules []route.Rule) {
+	table.Clear()
+	for i, title := range ruleColumns {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false))
+	}
+	for r, rule := range rules {
+		cells := []string{rule.Family, strconv.FormatUint(uint64(rule.Priority), 10), rule.Selector(), rule.Target()}
+		for c, text := range cells {
+			cell := tview.NewTableCell(tview.Escape(text))
+			if c == 1 {
+				cell.SetAlign(tview.AlignRight)
+			}
+			table.SetCell(r+1, c, cell)
+		}
+	}
+}
+
+// routeHops lists the gateways and interfaces of every path of rt.
+func routeHops(rt route.Route) (gateways, ifaces string) {
+	hops := rt.Nexthops
+	if len(hops) == 0 {
+		hops = []route.Nexthop{{Gateway: rt.Gateway, Iface: rt.Iface}}
+	}
+	var gws, names []string
+	for _, h := range hops {
+		if h.Gateway.IsValid() {
+			gws = append(gws, h.Gateway.String())
+		}
+		if h.Iface != "" && !slices.Contains(names, h.Iface) {
+			names = append(names, h.Iface)
+		}
+	}
+	return strings.Join(gws, ", "), strings.Join(names, ", ")
+}
+
+func addrString(a netip.Addr) string {
+	if !a.IsValid() {
+		return ""
+	}
+	return a.String()
+}
+
+// routeDetails describes the route the kernel would pick for c's remote
+// address, for the details pane. It is empty when there is nothing to look
+// up, such as for agent connections, or on platforms without route lookups.
+func (a *App) routeDetails(c models.Connection) string {
+	if _, ok := a.remote(c); ok || !c.HasRemote() || c.Family == "Unix" {
+		return ""
+	}
+	dst, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return ""
+	}
+	src, _ := netip.ParseAddr(c.LocalIP())
+
+	rt, err := route.Lookup(dst, src)
+	if errors.Is(err, route.ErrUnsupported) {
+		return ""
+	}
+	var b strings.Builder
+	if err != nil {
+		fmt.Fprintf(&b, "[yellow]Route:[white]      [red]%s[white]\n", tview.Escape(err.Error()))
+		return b.String()
+	}
+
+	dest := rt.Destination()
+	if rt.Type != "" && rt.Type != "unicast" {
+		dest = rt.Type + " " + dest
+	}
+	fmt.Fprintf(&b, "[yellow]Route:[white]      %s (table %s)\n", tview.Escape(dest), tview.Escape(route.TableName(rt.Table)))
+	path := rt
+	path.Nexthops = nil // Lookup sets Gateway and Iface to the path taken
+	gateways, ifaces := routeHops(path)
+	fmt.Fprintf(&b, "[yellow]Gateway:[white]    %s\n", tview.Escape(cmp.Or(gateways, "none, directly connected")))
+	if ifaces != "" {
+		fmt.Fprintf(&b, "[yellow]Interface:[white]  %s\n", tview.Escape(ifaces))
+	}
+	if rt.Src.IsValid() {
+		fmt.Fprintf(&b, "[yellow]Source:[white]     %s\n", rt.Src)
+	}
+	fmt.Fprintf(&b, "[yellow]Metric:[white]     %d\n", rt.Metric)
+	return b.String()
+}
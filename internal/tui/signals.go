@@ -0,0 +1,219 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// localProcesses returns the unique processes of the marked (or selected)
+// connections that can be controlled from here.
+func (v *View) localProcesses() []process {
+	var local []process
+	skipped := 0
+	for _, p := range uniqueProcesses(v.selection()) {
+		if _, ok := v.app.remote(p.conn); ok {
+			skipped++
+			continue
+		}
+		local = append(local, p)
+	}
+	if len(local) == 0 && skipped > 0 {
+		v.SetStatusMessage("Signals and priorities are only available for local processes.")
+	}
+	return local
+}
+
+func processLabel(c models.Connection) string {
+	return fmt.Sprintf("'%s' (PID %d)", c.ProcessName, c.Pid)
+}
+
+func processesLabel(procs []process) string {
+	if len(procs) == 1 {
+		return procs[0].label()
+	}
+	return fmt.Sprintf("%d processes", len(procs))
+}
+
+// applyToProcesses runs fn for every process and reports the outcome of
+// the whole batch in the status bar.
+func (v *View) applyToProcesses(procs []process, what string, fn func(pid int32) error) {
+	var errs []string
+	for _, p := range procs {
+		if err := fn(p.Pid); err != nil {
+			errs = append(errs, fmt.Sprintf("PID %d: %v", p.Pid, err))
+		}
+	}
+	if len(errs) > 0 {
+		v.SetStatusMessage(fmt.Sprintf("[red]%s on %s: %d failed: %s", what, processesLabel(procs), len(errs), tview.Escape(strings.Join(errs, "; "))))
+		return
+	}
+	v.SetStatusMessage(fmt.Sprintf("%s on %s", what, processesLabel(procs)))
+}
+
+func (v *View) showSignalModal() {
+	procs := v.localProcesses()
+	if len(procs) == 0 {
+		return
+	}
+
+	closeModal := func() {
+		v.pages.RemovePage("signal_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	list := tview.NewList()
+	for _, sig := range actions.Signals() {
+		list.AddItem(sig.String(), "  "+sig.Description, 0, func() {
+			closeModal()
+			send := func() {
+				v.applyToProcesses(procs, "Sent SIG"+sig.Name, func(pid int32) error {
+					return actions.SendSignal(pid, sig.Signal)
+				})
+				go v.app.loadData()
+			}
+			if len(procs) > 1 || disruptiveSignals[sig.Name] {
+				v.confirmSignal(procs, sig, send)
+				return
+			}
+			send()
+		})
+	}
+	list.AddItem("Renice...", "  Change CPU scheduling priority (nice)", 0, func() {
+		closeModal()
+		v.showReniceModal(procs)
+	})
+	list.AddItem("I/O priority...", "  Change I/O scheduling class (ionice)", 0, func() {
+		closeModal()
+		v.showIoniceModal(procs)
+	})
+	list.SetBorder(true).SetTitle(" Send signal to " + processesLabel(procs) + " ")
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'x' {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	grid := tview.NewGrid().
+		SetColumns(0, 70, 0).
+		SetRows(0, 30, 0).
+		AddItem(list, 1, 1, 1, 1, 0, 0, true)
+
+	v.pages.AddPage("signal_modal", grid, true, true)
+	v.app.tviewApp.SetFocus(list)
+}
+
+// disruptiveSignals stop or end a process, so they are confirmed even for a
+// single one.
+var disruptiveSignals = map[string]bool{"KILL": true, "TERM": true, "STOP": true}
+
+// confirmSignal asks before signalling, listing the processes like the
+// kill confirmation does.
+func (v *View) confirmSignal(procs []process, sig actions.Signal, send func()) {
+	text := fmt.Sprintf("Send %s to %s?", sig, tview.Escape(processesLabel(procs)))
+	if len(procs) > 1 {
+		text = fmt.Sprintf("Send %s to %d processes, %d connections?\n\n%s", sig, len(procs), len(v.selection()),
+			strings.TrimRight(processSummary(procs, 8), "\n"))
+	}
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"Send", "Cancel"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			v.pages.RemovePage("signal_confirm")
+			v.app.tviewApp.SetFocus(v.table)
+			if buttonLabel == "Send" {
+				send()
+			}
+		})
+	v.pages.AddPage("signal_confirm", modal, true, true)
+	v.app.tviewApp.SetFocus(modal)
+}
+
+func (v *View) showReniceModal(procs []process) {
+	current := ""
+	if nice, err := actions.Nice(procs[0].Pid); err == nil {
+		current = strconv.Itoa(nice)
+	}
+
+	niceInput := tview.NewInputField().
+		SetLabel("Nice (-20 to 19): ").
+		SetText(current).
+		SetFieldWidth(6).
+		SetAcceptanceFunc(func(text string, ch rune) bool {
+			return text == "-" || tview.InputFieldInteger(text, ch)
+		})
+
+	v.showProcessForm("renice_modal", "Renice "+processesLabel(procs), []tview.FormItem{niceInput}, func() {
+		nice, err := strconv.Atoi(strings.TrimSpace(niceInput.GetText()))
+		if err != nil || nice < -20 || nice > 19 {
+			v.SetStatusMessage("[red]Nice value must be between -20 and 19")
+			return
+		}
+		v.applyToProcesses(procs, fmt.Sprintf("Set nice %d", nice), func(pid int32) error {
+			return actions.Renice(pid, nice)
+		})
+	})
+}
+
+func (v *View) showIoniceModal(procs []process) {
+	class := 1
+	classDropDown := tview.NewDropDown().
+		SetLabel("Class: ").
+		SetOptions(actions.IOClasses, func(option string, index int) { class = index }).
+		SetCurrentOption(class)
+	levelInput := tview.NewInputField().
+		SetLabel("Level (0-7, 0 highest): ").
+		SetText("4").
+		SetFieldWidth(3).
+		SetAcceptanceFunc(tview.InputFieldInteger)
+
+	v.showProcessForm("ionice_modal", "I/O priority of "+processesLabel(procs), []tview.FormItem{classDropDown, levelInput}, func() {
+		level, _ := strconv.Atoi(levelInput.GetText())
+		name := actions.IOClasses[class]
+		v.applyToProcesses(procs, fmt.Sprintf("Set I/O class %s/%d", name, level), func(pid int32) error {
+			return actions.Ionice(pid, name, level)
+		})
+	})
+}
+
+func (v *View) showProcessForm(page, title string, items []tview.FormItem, apply func()) {
+	closeModal := func() {
+		v.pages.RemovePage(page)
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	form := tview.NewForm()
+	for _, item := range items {
+		form.AddFormItem(item)
+	}
+	form.AddButton("Apply", func() {
+		closeModal()
+		apply()
+	})
+	form.AddButton("Cancel", closeModal)
+	form.SetBorder(true).SetTitle(" " + title + " ")
+
+	grid := tview.NewGrid().
+		SetColumns(0, 60, 0).
+		SetRows(0, 2*len(items)+5, 0).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage(page, grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
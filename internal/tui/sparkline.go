@@ -0,0 +1,37 @@
+package tui
+
+import "strings"
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the last width values as block characters scaled from
+// zero to the largest value shown, in the given color. Negative values mark
+// gaps, such as lost pings, and are drawn as a red cross.
+func sparkline(values []float64, width int, color string) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+
+	hi := 0.0
+	for _, v := range values {
+		hi = max(hi, v)
+	}
+
+	var b strings.Builder
+	b.WriteString("[" + color + "]")
+	for _, v := range values {
+		switch {
+		case v < 0:
+			b.WriteString("[red]×[" + color + "]")
+		case hi == 0:
+			b.WriteRune(sparkBlocks[0])
+		default:
+			b.WriteRune(sparkBlocks[int(v/hi*float64(len(sparkBlocks)-1)+0.5)])
+		}
+	}
+	b.WriteString("[-]")
+	return b.String()
+}
@@ -1,10 +1,11 @@
 package tui
 
 import (
+	"slices"
 	"sort"
-	"strings"
 	"sync"
 
+	"github.com/MrBrooks89/BatStat/internal/filter"
 	"github.com/MrBrooks89/BatStat/internal/models"
 )
 
@@ -15,11 +16,17 @@ type AppState struct {
 	filterText          string
 	sortColumn          int
 	sortAsc             bool
+	hosts               []string                                 // Every configured source, local first
+	hostFilter          string                                   // Empty shows all hosts
+	hostErrors          map[string]error                         // Last fetch error per host
+	remoteDetails       map[string]map[int32]models.DetailedInfo // Process details reported by agents
+	highlights          map[string]string                        // Connection key -> alert rule
+	marks               map[string]bool                          // Marked connection keys
 }
 
 func NewAppState() *AppState {
 	return &AppState{
-		sortColumn: 0,
+		sortColumn: colNo,
 		sortAsc:    true,
 	}
 }
@@ -28,16 +35,211 @@ func (s *AppState) SetConnections(conns []models.Connection) {
 	s.Lock()
 	defer s.Unlock()
 	s.connections = conns
+	s.pruneMarks()
 	s.applySort()
 	s.applyFilter()
 }
 
+// Connections returns every connection of the last refresh, unfiltered.
+// The slice is never modified afterwards.
+func (s *AppState) Connections() []models.Connection {
+	s.RLock()
+	defer s.RUnlock()
+	return s.connections
+}
+
 func (s *AppState) GetFilteredConnections() []models.Connection {
 	s.RLock()
 	defer s.RUnlock()
 	return s.filteredConnections
 }
 
+func (s *AppState) GetFilterText() string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.filterText
+}
+
+func (s *AppState) SortDescription() string {
+	s.RLock()
+	defer s.RUnlock()
+	if columns[s.sortColumn].less == nil {
+		return ""
+	}
+	order := "ASC"
+	if !s.sortAsc {
+		order = "DESC"
+	}
+	return columns[s.sortColumn].title + " " + order
+}
+
+func (s *AppState) SetHighlights(highlights map[string]string) {
+	s.Lock()
+	defer s.Unlock()
+	s.highlights = highlights
+}
+
+func (s *AppState) Highlight(c models.Connection) string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.highlights[c.Key()]
+}
+
+func (s *AppState) ToggleMark(c models.Connection) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.marks == nil {
+		s.marks = make(map[string]bool)
+	}
+	key := c.Key()
+	if s.marks[key] {
+		delete(s.marks, key)
+		return false
+	}
+	s.marks[key] = true
+	return true
+}
+
+// MarkFiltered marks every connection that passes the current filter.
+func (s *AppState) MarkFiltered() int {
+	s.Lock()
+	defer s.Unlock()
+	if s.marks == nil {
+		s.marks = make(map[string]bool)
+	}
+	for _, c := range s.filteredConnections {
+		s.marks[c.Key()] = true
+	}
+	return len(s.filteredConnections)
+}
+
+func (s *AppState) ClearMarks() {
+	s.Lock()
+	defer s.Unlock()
+	s.marks = nil
+}
+
+func (s *AppState) Marked(c models.Connection) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.marks[c.Key()]
+}
+
+func (s *AppState) MarkCount() int {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.marks)
+}
+
+// MarkedConnections returns the marked connections in display order,
+// including ones hidden by the current filter.
+func (s *AppState) MarkedConnections() []models.Connection {
+	s.RLock()
+	defer s.RUnlock()
+	var marked []models.Connection
+	for _, c := range s.connections {
+		if s.marks[c.Key()] {
+			marked = append(marked, c)
+		}
+	}
+	return marked
+}
+
+func (s *AppState) SetHosts(hosts []string) {
+	s.Lock()
+	defer s.Unlock()
+	s.hosts = hosts
+}
+
+func (s *AppState) Hosts() []string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.hosts
+}
+
+func (s *AppState) MultiHost() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.hosts) > 1
+}
+
+func (s *AppState) SetHostFilter(host string) {
+	s.Lock()
+	defer s.Unlock()
+	s.hostFilter = host
+	s.applyFilter()
+}
+
+func (s *AppState) HostFilter() string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.hostFilter
+}
+
+func (s *AppState) HostCounts() (map[string]int, int) {
+	s.RLock()
+	defer s.RUnlock()
+	counts := make(map[string]int)
+	for _, c := range s.connections {
+		counts[c.Host]++
+	}
+	return counts, len(s.connections)
+}
+
+func (s *AppState) SetHostResult(host string, err error, details map[int32]models.DetailedInfo) {
+	s.Lock()
+	defer s.Unlock()
+	if s.hostErrors == nil {
+		s.hostErrors = make(map[string]error)
+		s.remoteDetails = make(map[string]map[int32]models.DetailedInfo)
+	}
+	s.hostErrors[host] = err
+	if details != nil {
+		s.remoteDetails[host] = details
+	}
+}
+
+func (s *AppState) HostError(host string) error {
+	s.RLock()
+	defer s.RUnlock()
+	return s.hostErrors[host]
+}
+
+func (s *AppState) RemoteDetails(host string, pid int32) (models.DetailedInfo, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	info, ok := s.remoteDetails[host][pid]
+	return info, ok
+}
+
+// VisibleColumns returns indexes into columns; Host only appears once more
+// than one source is configured.
+func (s *AppState) VisibleColumns() []int {
+	s.RLock()
+	defer s.RUnlock()
+	return s.visibleColumns()
+}
+
+func (s *AppState) visibleColumns() []int {
+	visible := make([]int, 0, len(columns))
+	for i := range columns {
+		if i == colHost && len(s.hosts) < 2 {
+			continue
+		}
+		if i == colMark && len(s.marks) == 0 {
+			continue
+		}
+		visible = append(visible, i)
+	}
+	return visible
+}
+
+func (s *AppState) SortState() (int, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.sortColumn, s.sortAsc
+}
+
 func (s *AppState) SetSort(column int, asc bool) {
 	s.Lock()
 	defer s.Unlock()
@@ -58,7 +260,21 @@ func (s *AppState) ToggleSortOrder() {
 func (s *AppState) CycleSortColumn() {
 	s.Lock()
 	defer s.Unlock()
-	s.sortColumn = (s.sortColumn % 7) + 1 
+	visible := s.visibleColumns()
+	next := -1
+	for _, i := range visible {
+		if columns[i].less == nil {
+			continue
+		}
+		if next == -1 {
+			next = i
+		}
+		if i > s.sortColumn {
+			next = i
+			break
+		}
+	}
+	s.sortColumn = max(next, colNo)
 	s.sortAsc = true
 	s.applySort()
 	s.applyFilter()
@@ -71,54 +287,47 @@ func (s *AppState) SetFilterText(text string) {
 	s.applyFilter()
 }
 
-func (s *AppState) applyFilter() {
-	s.filteredConnections = nil
-	normalizedFilter := strings.ToLower(s.filterText)
-
-	if normalizedFilter == "" {
-		s.filteredConnections = s.connections
+// pruneMarks drops marks of connections that have gone away.
+func (s *AppState) pruneMarks() {
+	if len(s.marks) == 0 {
 		return
 	}
-
+	live := make(map[string]bool, len(s.marks))
 	for _, c := range s.connections {
-		searchable := strings.ToLower(
-			c.ProcessName + " " +
-				c.Status + " " +
-				c.Family + " " +
-				c.Laddr + " " +
-				c.Raddr,
-		)
-		if strings.Contains(searchable, normalizedFilter) {
-			s.filteredConnections = append(s.filteredConnections, c)
+		if s.marks[c.Key()] {
+			live[c.Key()] = true
 		}
 	}
+	s.marks = live
 }
 
-func (s *AppState) applySort() {
-	sort.SliceStable(s.connections, func(i, j int) bool {
-		c1, c2 := s.connections[i], s.connections[j]
-		var less bool
-		switch s.sortColumn {
-		case 1:
-			less = strings.ToLower(c1.ProcessName) < strings.ToLower(c2.ProcessName)
-		case 2:
-			less = c1.Pid < c2.Pid
-		case 3:
-			less = c1.Status < c2.Status
-		case 4:
-			less = c1.Family < c2.Family
-		case 5:
-			less = c1.Type < c2.Type
-		case 6:
-			less = c1.Laddr < c2.Laddr
-		case 7:
-			less = c1.Raddr < c2.Raddr
-		default:
-			return i < j
+func (s *AppState) applyFilter() {
+	conns := s.connections
+	if s.hostFilter != "" {
+		conns = nil
+		for _, c := range s.connections {
+			if c.Host == s.hostFilter {
+				conns = append(conns, c)
+			}
 		}
+	}
+	s.filteredConnections = filter.Parse(s.filterText).Apply(conns)
+}
+
+// applySort sorts a copy of the connections. The slice passed to
+// SetConnections is still read by the refresh, and Connections hands the
+// current one out, so neither may be reordered in place.
+func (s *AppState) applySort() {
+	less := columns[s.sortColumn].less
+	if less == nil {
+		return
+	}
+	sorted := slices.Clone(s.connections)
+	sort.SliceStable(sorted, func(i, j int) bool {
 		if !s.sortAsc {
-			return !less
+			return less(sorted[j], sorted[i])
 		}
-		return less
+		return less(sorted[i], sorted[j])
 	})
+	s.connections = sorted
 }
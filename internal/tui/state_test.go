@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+func columnIndex(t *testing.T, title string) int {
+	t.Helper()
+	i := slices.IndexFunc(columns, func(c column) bool { return c.title == title })
+	if i < 0 {
+		t.Fatalf("no column %q", title)
+	}
+	return i
+}
+
+func pids(conns []models.Connection) []int32 {
+	var pids []int32
+	for _, c := range conns {
+		pids = append(pids, c.Pid)
+	}
+	return pids
+}
+
+// Sorting must not reorder the slice the refresh passed in and keeps
+// reading, nor one handed out by Connections; run with -race.
+func TestSortLeavesSharedSlicesAlone(t *testing.T) {
+	conns := []models.Connection{{Pid: 3}, {Pid: 1}, {Pid: 2}}
+	s := NewAppState()
+	s.SetSort(columnIndex(t, "PID"), true)
+	s.SetConnections(conns)
+	handedOut := s.Connections()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			_, _ = pids(conns), pids(handedOut)
+		}
+	}()
+	for range 100 {
+		s.ToggleSortOrder()
+	}
+	wg.Wait()
+
+	if got := pids(conns); !slices.Equal(got, []int32{3, 1, 2}) {
+		t.Errorf("input reordered to %v", got)
+	}
+	if got := pids(handedOut); !slices.Equal(got, []int32{1, 2, 3}) {
+		t.Errorf("handed-out slice reordered to %v", got)
+	}
+	if got := pids(s.Connections()); !slices.Equal(got, []int32{1, 2, 3}) {
+		t.Errorf("after an even number of toggles: %v", got)
+	}
+	s.ToggleSortOrder()
+	if got := pids(s.GetFilteredConnections()); !slices.Equal(got, []int32{3, 2, 1}) {
+		t.Errorf("descending: %v", got)
+	}
+}
@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/rivo/tview"
+)
+
+const summaryHeight = 3
+
+// connSummary totals a snapshot for the summary header.
+type connSummary struct {
+	total       int
+	tcpStates   map[string]int
+	protocols   map[string]int // TCP, UDP, Unix
+	families    map[string]int // IPv4, IPv6
+	remoteHosts int
+	listening   int // Distinct protocol/port pairs accepting connections
+}
+
+func summarize(conns []models.Connection) connSummary {
+	s := connSummary{
+		total:     len(conns),
+		tcpStates: make(map[string]int),
+		protocols: make(map[string]int),
+		families:  make(map[string]int),
+	}
+	remotes := make(map[string]bool)
+	listening := make(map[string]bool)
+	for _, c := range conns {
+		if c.Family == "Unix" {
+			s.protocols["Unix"]++
+			continue
+		}
+		s.protocols[c.Type]++
+		s.families[c.Family]++
+		if c.Type == "TCP" {
+			s.tcpStates[cmp.Or(c.Status, "NONE")]++
+		}
+		if c.HasRemote() {
+			remotes[c.RemoteIP()] = true
+		}
+		// Unconnected UDP sockets are the UDP equivalent of LISTEN.
+		if c.Status == "LISTEN" || (c.Type == "UDP" && !c.HasRemote()) {
+			listening[fmt.Sprintf("%s/%d", c.Type, c.LocalPort())] = true
+		}
+	}
+	s.remoteHosts, s.listening = len(remotes), len(listening)
+	return s
+}
+
+// renderSummary fills the header from the latest refresh. The filter counts
+// are taken from the state, so it also follows filter changes in between.
+func (v *View) renderSummary() {
+	if !v.showSummary {
+		return
+	}
+	a := v.app
+	info := a.hostInfo
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]Host[white] %s", tview.Escape(cmp.Or(info.Hostname, "unknown")))
+	if len(a.agentList) > 0 {
+		fmt.Fprintf(&b, " [gray]+ %d agents[white]", len(a.agentList))
+	}
+	if info.Kernel != "" {
+		fmt.Fprintf(&b, "  [yellow]Kernel[white] %s", tview.Escape(info.Kernel))
+	}
+	if info.Uptime > 0 {
+		fmt.Fprintf(&b, "  [yellow]Up[white] %s", formatUptime(info.Uptime))
+	}
+	if info.Load != nil {
+		fmt.Fprintf(&b, "  [yellow]Load[white] %.2f %.2f %.2f", info.Load.Load1, info.Load.Load5, info.Load.Load15)
+	}
+	if !a.refreshed.IsZero() {
+		fmt.Fprintf(&b, "  [yellow]Refreshed[white] %s [gray]in %s[white]",
+			a.refreshed.Format("15:04:05"), a.fetchTime.Round(time.Millisecond))
+	}
+	b.WriteString("\n")
+
+	all := a.state.Connections()
+	s := summarize(all)
+	shown := len(a.state.GetFilteredConnections())
+	// The filter counts come first so narrow terminals still show them.
+	fmt.Fprintf(&b, "[yellow]Shown[white] %d of %d", shown, s.total)
+	if hidden := s.total - shown; hidden > 0 {
+		fmt.Fprintf(&b, " [gray](%d filtered)[white]", hidden)
+	}
+	fmt.Fprintf(&b, " [gray]│[white] TCP %d UDP %d Unix %d [gray]│[white] IPv4 %d IPv6 %d [gray]│[white] Remote hosts %d [gray]│[white] Listening ports %d",
+		s.protocols["TCP"], s.protocols["UDP"], s.protocols["Unix"],
+		s.families["IPv4"], s.families["IPv6"], s.remoteHosts, s.listening)
+	b.WriteString("\n")
+
+	b.WriteString("[yellow]TCP[white]")
+	if len(s.tcpStates) == 0 {
+		b.WriteString(" [gray]none[white]")
+	}
+	states := slices.SortedFunc(maps.Keys(s.tcpStates), func(x, y string) int {
+		return cmp.Or(cmp.Compare(s.tcpStates[y], s.tcpStates[x]), cmp.Compare(x, y))
+	})
+	for _, state := range states {
+		fmt.Fprintf(&b, "  [%s]%s[white] %d", colorTag(getStatusColor(state)), state, s.tcpStates[state])
+	}
+
+	v.summaryView.SetText(b.String())
+}
+
+func (v *View) toggleSummary() {
+	v.showSummary = !v.showSummary
+	height := 0
+	if v.showSummary {
+		height = summaryHeight
+	}
+	v.layout.ResizeItem(v.summaryView, height, 0)
+	v.renderSummary()
+}
+
+// formatUptime keeps the two largest units, e.g. "3d 4h" or "4h 12m".
+func formatUptime(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
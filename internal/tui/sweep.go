@@ -0,0 +1,488 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/actions"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/probe"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// sweepRedrawDelay batches the results of many concurrent probes into a few
+// redraws per second.
+const sweepRedrawDelay = 100 * time.Millisecond
+
+const sweepKeys = "s/S Sort  e Export  Esc Close"
+
+// sweepTarget is one probed address with the connections that use it.
+type sweepTarget struct {
+	host      string
+	ports     []uint32
+	processes []string
+	conns     int
+	result    probe.SweepResult
+	started   bool
+}
+
+func (t *sweepTarget) addr(tcp bool) string {
+	if tcp {
+		return net.JoinHostPort(t.host, strconv.Itoa(int(t.ports[0])))
+	}
+	return t.host
+}
+
+func (t *sweepTarget) portList() string {
+	ports := make([]string, len(t.ports))
+	for i, p := range t.ports {
+		ports[i] = strconv.Itoa(int(p))
+	}
+	return strings.Join(ports, ", ")
+}
+
+// rtt is the average round-trip time; targets that never answered sort
+// as the slowest.
+func (t *sweepTarget) rtt() time.Duration {
+	if t.result.Stats.Received == 0 {
+		return math.MaxInt64
+	}
+	return t.result.Stats.Avg
+}
+
+// loss is the loss percentage, or -1 before the first probe.
+func (t *sweepTarget) loss() float64 {
+	if t.result.Stats.Sent == 0 {
+		return -1
+	}
+	return t.result.Stats.Loss()
+}
+
+// sweepColumns are the columns of the results table and how they sort.
+var sweepColumns = []struct {
+	title string
+	cmp   func(a, b *sweepTarget) int
+}{
+	{"Host", func(a, b *sweepTarget) int {
+		return cmp.Or(compareAddrs(a.host, b.host), cmp.Compare(a.ports[0], b.ports[0]))
+	}},
+	{"Port", func(a, b *sweepTarget) int { return cmp.Compare(a.ports[0], b.ports[0]) }},
+	{"Processes", func(a, b *sweepTarget) int {
+		return strings.Compare(strings.Join(a.processes, ","), strings.Join(b.processes, ","))
+	}},
+	{"Conns", func(a, b *sweepTarget) int { return cmp.Compare(a.conns, b.conns) }},
+	{"Sent", func(a, b *sweepTarget) int { return cmp.Compare(a.result.Stats.Sent, b.result.Stats.Sent) }},
+	{"Loss%", func(a, b *sweepTarget) int { return cmp.Compare(a.loss(), b.loss()) }},
+	{"Avg", func(a, b *sweepTarget) int { return cmp.Compare(a.rtt(), b.rtt()) }},
+	{"Max", func(a, b *sweepTarget) int { return cmp.Compare(a.result.Stats.Max, b.result.Stats.Max) }},
+	{"Last error", func(a, b *sweepTarget) int { return strings.Compare(a.result.Err, b.result.Err) }},
+}
+
+func compareAddrs(a, b string) int {
+	addrA, errA := netip.ParseAddr(a)
+	addrB, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return addrA.Compare(addrB)
+}
+
+// sweepTargets groups conns by remote address, or by remote address and port
+// for TCP connects, which skips everything but TCP. Connections seen through
+// agents are skipped, since probing them from here says nothing about the
+// agent's path.
+func (a *App) sweepTargets(conns []models.Connection, tcp bool) (targets []*sweepTarget, skipped int) {
+	byAddr := make(map[string]*sweepTarget)
+	for _, c := range conns {
+		if _, ok := a.remote(c); ok || !c.HasRemote() || (tcp && c.Type != "TCP") {
+			skipped++
+			continue
+		}
+		key := c.RemoteIP()
+		if tcp {
+			key = net.JoinHostPort(key, strconv.Itoa(int(c.RemotePort())))
+		}
+		t, ok := byAddr[key]
+		if !ok {
+			t = &sweepTarget{host: c.RemoteIP()}
+			byAddr[key] = t
+			targets = append(targets, t)
+		}
+		t.conns++
+		if !slices.Contains(t.ports, c.RemotePort()) {
+			t.ports = append(t.ports, c.RemotePort())
+		}
+		if c.ProcessName != "" && !slices.Contains(t.processes, c.ProcessName) {
+			t.processes = append(t.processes, c.ProcessName)
+		}
+	}
+	for _, t := range targets {
+		slices.Sort(t.ports)
+		slices.Sort(t.processes)
+	}
+	return targets, skipped
+}
+
+// showSweepModal sets up a reachability sweep of every remote address in
+// the view, or of the marked rows.
+func (v *View) showSweepModal() {
+	conns := v.app.state.GetFilteredConnections()
+	scope := "in the view"
+	if marked := v.app.state.MarkedConnections(); len(marked) > 0 {
+		conns, scope = marked, "marked"
+	}
+	opts := v.app.sweep
+
+	closeModal := func() {
+		v.pages.RemovePage("sweep_settings")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	methods := []string{"ICMP echo", "TCP connect"}
+	method := 0
+	if opts.TCP {
+		method = 1
+	}
+	methodDropDown := tview.NewDropDown().
+		SetLabel("Method: ").
+		SetOptions(methods, func(_ string, index int) { method = index }).
+		SetCurrentOption(method)
+	countInput := tview.NewInputField().
+		SetLabel("Probes per target: ").
+		SetText(strconv.Itoa(opts.Count)).
+		SetFieldWidth(6).
+		SetAcceptanceFunc(tview.InputFieldInteger)
+	intervalInput := tview.NewInputField().
+		SetLabel("Interval: ").
+		SetText(opts.Interval.String()).
+		SetFieldWidth(8)
+	parallelInput := tview.NewInputField().
+		SetLabel("Targets at once: ").
+		SetText(strconv.Itoa(opts.Parallel)).
+		SetFieldWidth(6).
+		SetAcceptanceFunc(tview.InputFieldInteger)
+
+	form := tview.NewForm().
+		AddFormItem(methodDropDown).
+		AddFormItem(countInput).
+		AddFormItem(intervalInput).
+		AddFormItem(parallelInput)
+	form.AddButton("Start", func() {
+		count, err := strconv.Atoi(countInput.GetText())
+		if err != nil || count < 1 {
+			v.SetStatusMessage("[red]Probes per target must be 1 or more")
+			return
+		}
+		interval, err := time.ParseDuration(strings.TrimSpace(intervalInput.GetText()))
+		if err != nil || interval <= 0 {
+			v.SetStatusMessage("[red]Invalid interval: " + tview.Escape(intervalInput.GetText()))
+			return
+		}
+		parallel, err := strconv.Atoi(parallelInput.GetText())
+		if err != nil || parallel < 1 {
+			v.SetStatusMessage("[red]Targets at once must be 1 or more")
+			return
+		}
+		opts.TCP, opts.Count, opts.Interval, opts.Parallel = method == 1, count, interval, parallel
+
+		targets, skipped := v.app.sweepTargets(conns, opts.TCP)
+		if len(targets) == 0 {
+			v.SetStatusMessage(fmt.Sprintf("No connection %s can be swept with %s.", scope, methods[method]))
+			return
+		}
+		v.app.sweep = opts
+		closeModal()
+		v.runSweep(targets, skipped, opts)
+	})
+	form.AddButton("Cancel", closeModal)
+	form.SetBorder(true).SetTitle(fmt.Sprintf(" Sweep remote hosts %s ", scope))
+	form.SetFocus(form.GetFormItemCount())
+
+	grid := tview.NewGrid().
+		SetColumns(0, 50, 0).
+		SetRows(0, 13, 0).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("sweep_settings", grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
+
+// runSweep probes the targets and shows their results in a sortable table
+// that fills in as probes complete.
+func (v *View) runSweep(targets []*sweepTarget, skipped int, opts probe.SweepOptions) {
+	statusView := tview.NewTextView().SetDynamicColors(true)
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 1)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(statusView, 1, 0, false).
+		AddItem(table, 0, 1, true)
+	layout.SetBorderPadding(0, 0, 1, 1)
+
+	method := "ICMP echo"
+	if opts.TCP {
+		method = "TCP connect"
+	}
+	frame := tview.NewFrame(layout).
+		AddText(fmt.Sprintf("Sweep of %d remote hosts (%s, %d probes each)", len(targets), method, opts.Count),
+			true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(sweepKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	var elapsed time.Duration
+
+	// Only touched on the UI goroutine.
+	rows := slices.Clone(targets)
+	sortColumn, sortAsc := 0, true
+	redrawPending := false
+	render := func() {
+		redrawPending = false
+		var selected *sweepTarget
+		if row, _ := table.GetSelection(); row >= 1 && row <= len(rows) {
+			selected = rows[row-1]
+		}
+		slices.SortStableFunc(rows, func(a, b *sweepTarget) int {
+			c := sweepColumns[sortColumn].cmp(a, b)
+			if !sortAsc {
+				return -c
+			}
+			return c
+		})
+		renderSweep(table, rows, sortColumn, sortAsc)
+		if i := slices.Index(rows, selected); i >= 0 {
+			table.Select(i+1, 0)
+		}
+		statusView.SetText(sweepStatus(targets, skipped, elapsed))
+	}
+	scheduleRender := func() {
+		if !redrawPending {
+			redrawPending = true
+			time.AfterFunc(sweepRedrawDelay, func() { v.app.tviewApp.QueueUpdateDraw(render) })
+		}
+	}
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			cancel()
+			v.pages.RemovePage("sweep_modal")
+			v.app.tviewApp.SetFocus(v.table)
+			return nil
+		case event.Rune() == 's':
+			sortColumn = (sortColumn + 1) % len(sweepColumns)
+			render()
+			return nil
+		case event.Rune() == 'S':
+			sortAsc = !sortAsc
+			render()
+			return nil
+		case event.Rune() == 'e':
+			order := "ASC"
+			if !sortAsc {
+				order = "DESC"
+			}
+			meta := actions.NewExportMeta(v.app.state.GetFilterText(), sweepColumns[sortColumn].title+" "+order)
+			v.showExportModal(" Export sweep results ", "batstat_sweep", func() actions.Dataset {
+				return sweepDataset(rows, opts)
+			}, meta)
+			return nil
+		}
+		return event
+	})
+
+	addrs := make([]string, len(targets))
+	for i, t := range targets {
+		addrs[i] = t.addr(opts.TCP)
+	}
+	go func() {
+		probe.Sweep(ctx, addrs, opts, func(i int, r probe.SweepResult) {
+			v.app.tviewApp.QueueUpdate(func() {
+				targets[i].result, targets[i].started = r, true
+				scheduleRender()
+			})
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		v.app.tviewApp.QueueUpdateDraw(func() {
+			elapsed = time.Since(start)
+			render()
+		})
+	}()
+
+	render()
+	v.pages.AddPage("sweep_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(table)
+}
+
+func sweepStatus(targets []*sweepTarget, skipped int, elapsed time.Duration) string {
+	done, reachable, lossy, unreachable := 0, 0, 0, 0
+	for _, t := range targets {
+		if !t.result.Done {
+			continue
+		}
+		done++
+		switch stats := t.result.Stats; {
+		case stats.Received == 0:
+			unreachable++
+		case stats.Received < stats.Sent:
+			lossy++
+		default:
+			reachable++
+		}
+	}
+	status := fmt.Sprintf("[yellow]Done:[white] %d/%d  [green]Reachable:[white] %d  [yellow]Loss:[white] %d  [red]Unreachable:[white] %d",
+		done, len(targets), reachable, lossy, unreachable)
+	if skipped > 0 {
+		status += fmt.Sprintf("  [gray]%d connections skipped (agent, no remote address or not TCP)[white]", skipped)
+	}
+	if elapsed > 0 {
+		status += fmt.Sprintf("  [gray]finished in %s", elapsed.Round(time.Millisecond))
+	}
+	return status
+}
+
+func renderSweep(table *tview.Table, rows []*sweepTarget, sortColumn int, sortAsc bool) {
+	table.Clear()
+	for i, col := range sweepColumns {
+		title := col.title
+		if i == sortColumn {
+			title += " [yellow]▲"
+			if !sortAsc {
+				title = col.title + " [yellow]▼"
+			}
+		}
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false))
+	}
+
+	for r, t := range rows {
+		stats := t.result.Stats
+		color := tcell.ColorGray
+		loss, avg, max := "", "", ""
+		if stats.Sent > 0 {
+			loss = fmt.Sprintf("%.0f", stats.Loss())
+			switch {
+			case stats.Received == 0:
+				color = tcell.ColorRed
+			case stats.Received < stats.Sent:
+				color = tcell.ColorYellow
+			default:
+				color = tcell.ColorGreen
+			}
+		}
+		if stats.Received > 0 {
+			avg, max = formatRTT(stats.Avg), formatRTT(stats.Max)
+		}
+		sent := ""
+		if t.started {
+			sent = strconv.Itoa(stats.Sent)
+		}
+		cells := []string{t.host, t.portList(), strings.Join(t.processes, ", "), strconv.Itoa(t.conns),
+			sent, loss, avg, max, t.result.Err}
+		for c, text := range cells {
+			cell := tview.NewTableCell(tview.Escape(text))
+			switch c {
+			case 0:
+				cell.SetTextColor(color)
+			case 1, 2:
+				cell.SetMaxWidth(30)
+			case 3, 4, 5, 6, 7:
+				cell.SetAlign(tview.AlignRight)
+			case 8:
+				cell.SetTextColor(tcell.ColorRed).SetExpansion(1)
+			}
+			table.SetCell(r+1, c, cell)
+		}
+	}
+}
+
+func sweepDataset(rows []*sweepTarget, opts probe.SweepOptions) actions.Dataset {
+	ds := actions.Dataset{
+		Name:  "sweep",
+		Title: "BatStat reachability sweep",
+		Columns: []actions.Column{
+			{Key: "host", Title: "Host"},
+			{Key: "ports", Title: "Ports"},
+			{Key: "processes", Title: "Processes"},
+			{Key: "connections", Title: "Connections"},
+			{Key: "method", Title: "Method"},
+			{Key: "sent", Title: "Sent"},
+			{Key: "received", Title: "Received"},
+			{Key: "loss_pct", Title: "Loss%"},
+			{Key: "avg_ms", Title: "AvgMs"},
+			{Key: "min_ms", Title: "MinMs"},
+			{Key: "max_ms", Title: "MaxMs"},
+			{Key: "error", Title: "LastError"},
+		},
+	}
+	method := "icmp"
+	if opts.TCP {
+		method = "tcp"
+	}
+
+	reachable, lossy, unreachable, pending := 0, 0, 0, 0
+	for _, t := range rows {
+		stats := t.result.Stats
+		var loss, avg, minRTT, maxRTT any
+		if stats.Sent > 0 {
+			loss = round1(stats.Loss())
+		}
+		if stats.Received > 0 {
+			avg, minRTT, maxRTT = millisValue(stats.Avg), millisValue(stats.Min), millisValue(stats.Max)
+		}
+		ds.Rows = append(ds.Rows, []any{
+			t.host, t.portList(), strings.Join(t.processes, ", "), t.conns, method,
+			stats.Sent, stats.Received, loss, avg, minRTT, maxRTT, t.result.Err,
+		})
+
+		switch {
+		case !t.result.Done:
+			pending++
+		case stats.Received == 0:
+			unreachable++
+		case stats.Received < stats.Sent:
+			lossy++
+		default:
+			reachable++
+		}
+	}
+
+	counts := []actions.SummaryCount{{Label: "Reachable", Count: reachable}, {Label: "With loss", Count: lossy}, {Label: "Unreachable", Count: unreachable}}
+	if pending > 0 {
+		counts = append(counts, actions.SummaryCount{Label: "Not finished", Count: pending})
+	}
+	ds.Summary = []actions.SummaryGroup{
+		{Title: "Total", Counts: []actions.SummaryCount{{Label: "Hosts", Count: len(rows)}}},
+		{Title: "By result", Counts: counts},
+	}
+	return ds
+}
+
+func millisValue(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
@@ -2,21 +2,19 @@ package tui
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
-	"github.com/MrBrooks89/BatStat/internal/models"
 )
 
 func (v *View) populateTable() {
 	connections := v.app.state.GetFilteredConnections()
+	visible := v.app.state.VisibleColumns()
 	v.table.Clear()
 
-	headers := []string{"No", "Process", "PID", "Status", "Family", "Type", "Local Addr", "Remote Addr"}
-	for i, h := range headers {
-		cell := tview.NewTableCell(h).
+	for i, col := range visible {
+		cell := tview.NewTableCell(columns[col].title).
 			SetTextColor(tview.Styles.SecondaryTextColor).
 			SetAlign(tview.AlignCenter).
 			SetSelectable(false)
@@ -24,37 +22,42 @@ func (v *View) populateTable() {
 	}
 
 	for r, conn := range connections {
-		rowItems := []string{
-			strconv.Itoa(r + 1),
-			conn.ProcessName,
-			strconv.Itoa(int(conn.Pid)),
-			conn.Status,
-			conn.Family,
-			conn.Type,
-			conn.Laddr,
-			conn.Raddr,
-		}
-		for c, item := range rowItems {
-			cell := tview.NewTableCell(truncate(item, 30)).
+		highlighted := v.app.state.Highlight(conn) != ""
+		marked := v.app.state.Marked(conn)
+		for c, col := range visible {
+			text := columns[col].value(r+1, conn)
+			if col == colMark && marked {
+				text = "●"
+			}
+			cell := tview.NewTableCell(truncate(text, 30)).
 				SetExpansion(1).
 				SetTextColor(getStatusColor(conn.Status))
+			if col == colMark {
+				cell.SetExpansion(0).SetTextColor(tcell.ColorYellow)
+			}
+			if conn.IOC != "" {
+				cell.SetTextColor(tcell.ColorWhite).SetBackgroundColor(tcell.ColorDarkMagenta)
+			}
+			if highlighted {
+				cell.SetTextColor(tcell.ColorWhite).SetBackgroundColor(tcell.ColorDarkRed)
+			}
 			v.table.SetCell(r+1, c, cell)
 		}
 	}
 }
 
 func (v *View) updateHeaderIndicator() {
-	headers := []string{"No", "Process", "PID", "Status", "Family", "Type", "Local Addr", "Remote Addr"}
-	for i, h := range headers {
+	sortColumn, sortAsc := v.app.state.SortState()
+	for i, col := range v.app.state.VisibleColumns() {
 		indicator := ""
-		if i == v.app.state.sortColumn {
+		if col == sortColumn {
 			indicator = " [yellow]▲"
-			if !v.app.state.sortAsc {
+			if !sortAsc {
 				indicator = " [yellow]▼"
 			}
 		}
 		if cell := v.table.GetCell(0, i); cell != nil {
-			cell.SetText(h + indicator)
+			cell.SetText(columns[col].title + indicator)
 		}
 	}
 }
@@ -65,15 +68,32 @@ func (v *View) updateDetailsView(row int) {
 		v.detailsView.Clear().SetText(" [gray]No connection selected")
 		return
 	}
-	details := models.GetDetailedInfo(c.Pid)
+	details := v.app.detailsFor(*c)
 
 	var builder strings.Builder
+	if c.Host != "" {
+		builder.WriteString(fmt.Sprintf("[yellow]Host:[white]       %s\n", c.Host))
+	}
+	if rule := v.app.state.Highlight(*c); rule != "" {
+		builder.WriteString(fmt.Sprintf("[red::b]ALERT:[-::-]      %s\n", tview.Escape(rule)))
+	}
+	if c.IOC != "" {
+		builder.WriteString(fmt.Sprintf("[white:purple:b] IOC [-:-:-]       %s\n", tview.Escape(c.IOC)))
+	}
 	builder.WriteString(fmt.Sprintf("[yellow]Process:[white]    %s\n", c.ProcessName))
 	builder.WriteString(fmt.Sprintf("[yellow]PID:[white]        %d\n", c.Pid))
 	builder.WriteString(fmt.Sprintf("[yellow]User:[white]       %s\n\n", details.Username))
 	builder.WriteString(fmt.Sprintf("[yellow]Status:[white]     %s\n", c.Status))
 	builder.WriteString(fmt.Sprintf("[yellow]Local Addr:[white] %s\n", c.Laddr))
-	builder.WriteString(fmt.Sprintf("[yellow]Remote Addr:[white] %s\n\n", c.Raddr))
+	builder.WriteString(fmt.Sprintf("[yellow]Remote Addr:[white] %s\n", c.Raddr))
+	if n := v.app.historyCount(*c); n > 0 {
+		builder.WriteString(fmt.Sprintf("[yellow]History:[white]    %d runs (d)\n", n))
+	}
+	if routeInfo := v.app.routeDetails(*c); routeInfo != "" {
+		builder.WriteString("\n" + routeInfo)
+	}
+	builder.WriteString(v.app.neighborDetails(*c))
+	builder.WriteString("\n")
 	builder.WriteString(fmt.Sprintf("[yellow]Command:[white]\n%s\n", details.Cmdline))
 
 	v.detailsView.SetText(builder.String())
@@ -0,0 +1,285 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/history"
+	"github.com/MrBrooks89/BatStat/internal/probe"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// showTCPProbeModal sets up repeated TCP connects to the selected remote
+// endpoint, optionally followed by a TLS handshake and an HTTP HEAD.
+func (v *View) showTCPProbeModal() {
+	c := v.GetSelectedConnection()
+	if c == nil || !c.HasRemote() {
+		return
+	}
+	if c.Type != "TCP" {
+		v.SetStatusMessage("TCP probes need a TCP connection.")
+		return
+	}
+	if _, ok := v.app.remote(*c); ok {
+		v.SetStatusMessage("TCP probes only run for local connections.")
+		return
+	}
+	addr := net.JoinHostPort(c.RemoteIP(), strconv.Itoa(int(c.RemotePort())))
+	port := int(c.RemotePort())
+	opts := v.app.tcping
+	checkTLS, checkHTTP := probe.TLSPorts[port], probe.HTTPPorts[port]
+
+	closeModal := func() {
+		v.pages.RemovePage("tcping_settings")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	countInput := tview.NewInputField().
+		SetLabel("Count (0 = until Esc): ").
+		SetText(strconv.Itoa(opts.Count)).
+		SetFieldWidth(6).
+		SetAcceptanceFunc(tview.InputFieldInteger)
+	intervalInput := tview.NewInputField().
+		SetLabel("Interval: ").
+		SetText(opts.Interval.String()).
+		SetFieldWidth(8)
+	nameInput := tview.NewInputField().
+		SetLabel("Server name (SNI/Host): ").
+		SetPlaceholder("none").
+		SetFieldWidth(30)
+
+	form := tview.NewForm().
+		AddFormItem(countInput).
+		AddFormItem(intervalInput).
+		AddFormItem(nameInput).
+		AddCheckbox("TLS handshake: ", checkTLS, func(checked bool) { checkTLS = checked }).
+		AddCheckbox("HTTP HEAD: ", checkHTTP, func(checked bool) { checkHTTP = checked })
+	form.AddButton("Start", func() {
+		count, err := strconv.Atoi(countInput.GetText())
+		if err != nil || count < 0 {
+			v.SetStatusMessage("[red]Count must be 0 or more")
+			return
+		}
+		interval, err := time.ParseDuration(strings.TrimSpace(intervalInput.GetText()))
+		if err != nil || interval <= 0 {
+			v.SetStatusMessage("[red]Invalid interval: " + tview.Escape(intervalInput.GetText()))
+			return
+		}
+		opts.Count, opts.Interval = count, interval
+		v.app.tcping = opts
+		closeModal()
+		endpoint := probe.EndpointOptions{ServerName: strings.TrimSpace(nameInput.GetText())}
+		v.runTCPProbe(addr, opts, endpoint, checkTLS, checkHTTP)
+	})
+	form.AddButton("Cancel", closeModal)
+	form.SetBorder(true).SetTitle(" Probe " + addr + " ")
+	form.SetFocus(form.GetFormItemCount())
+
+	grid := tview.NewGrid().
+		SetColumns(0, 60, 0).
+		SetRows(0, 15, 0).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("tcping_settings", grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
+
+// runTCPProbe shows connect statistics and results, and the TLS and HTTP
+// reports when they were asked for, until the count is reached or Esc is
+// pressed.
+func (v *View) runTCPProbe(addr string, opts probe.PingOptions, endpoint probe.EndpointOptions, checkTLS, checkHTTP bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := newOutputViewer(v, "tcping_"+addr, func() {
+		cancel()
+		v.pages.RemovePage("tcping_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	})
+	statsView := tview.NewTextView().SetDynamicColors(true)
+	reportView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+	for _, tv := range []*tview.TextView{statsView, reportView} {
+		tv.SetBorderPadding(0, 0, 1, 1)
+	}
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(statsView, 4, 0, false)
+	if checkTLS || checkHTTP {
+		layout.AddItem(reportView, 0, 1, false)
+	}
+	layout.AddItem(out, 0, 1, true)
+
+	frame := tview.NewFrame(layout).
+		AddText(fmt.Sprintf("TCP probe %s...", addr), true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(outputKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	// Only touched on the UI goroutine.
+	var rtts []float64
+	var last time.Duration
+	showStats := func(stats probe.PingStats) {
+		_, _, width, _ := statsView.GetInnerRect()
+		statsView.SetText(latencyText(stats, last, rtts, width))
+	}
+
+	go func() {
+		start := time.Now()
+		var output []string
+		stats, err := probe.TCPing(ctx, addr, opts, func(r probe.ConnectResult, stats probe.PingStats) {
+			output = append(output, r.String())
+			color := ""
+			if r.Err != nil {
+				color = "red"
+			}
+			out.print(color, r.String())
+			v.app.tviewApp.QueueUpdateDraw(func() {
+				if r.Err != nil {
+					rtts = append(rtts, -1)
+				} else {
+					last = r.RTT
+					rtts = append(rtts, float64(r.RTT))
+				}
+				if len(rtts) > pingHistory {
+					rtts = rtts[len(rtts)-pingHistory:]
+				}
+				showStats(stats)
+			})
+		})
+		if err == nil && stats.Sent > 0 {
+			host, port, _ := net.SplitHostPort(addr)
+			output = append(output, "", fmt.Sprintf("%d connects, %d succeeded, %.1f%% failed", stats.Sent, stats.Received, stats.Loss()), stats.RTTSummary())
+			v.app.recordRun(history.Run{
+				Time:   start,
+				Target: host,
+				Tool:   "tcping",
+				Params: map[string]string{
+					"port":     port,
+					"count":    strconv.Itoa(opts.Count),
+					"interval": opts.Interval.String(),
+				},
+				Elapsed: time.Since(start),
+				Ping:    &stats,
+				Output:  output,
+			})
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			out.finish("Probe failed: "+err.Error(), true)
+			return
+		}
+		v.app.tviewApp.QueueUpdateDraw(func() { showStats(stats) })
+		out.print("", fmt.Sprintf("--- %s: %d connects, %d succeeded, %.1f%% failed ---", addr, stats.Sent, stats.Received, stats.Loss()))
+		out.finish(fmt.Sprintf("%.1f%% failed", stats.Loss()), stats.Received == 0)
+	}()
+
+	if checkTLS || checkHTTP {
+		reportView.SetText("[gray]Checking...")
+		go func() {
+			var b strings.Builder
+			if checkTLS {
+				report, err := probe.CheckTLS(ctx, addr, endpoint)
+				writeTLSReport(&b, report, err)
+			}
+			if checkHTTP {
+				report, err := probe.CheckHTTP(ctx, addr, checkTLS, endpoint)
+				writeHTTPReport(&b, report, err)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			v.app.tviewApp.QueueUpdateDraw(func() {
+				reportView.SetText(b.String())
+			})
+		}()
+	}
+
+	v.pages.AddPage("tcping_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(out)
+}
+
+func writeTLSReport(b *strings.Builder, r probe.TLSReport, err error) {
+	b.WriteString("[yellow::b]TLS[-::-]")
+	if err != nil {
+		b.WriteString("  [red]" + tview.Escape(err.Error()) + "[white]\n\n")
+		return
+	}
+	fmt.Fprintf(b, "  %s  %s", r.Version, r.CipherSuite)
+	if r.ALPN != "" {
+		fmt.Fprintf(b, "  ALPN %s", tview.Escape(r.ALPN))
+	}
+	fmt.Fprintf(b, "  [gray]connect %s ms, handshake %s ms[white]\n", formatRTT(r.Connect), formatRTT(r.Handshake))
+
+	name := r.ServerName
+	if name == "" {
+		name, _, _ = net.SplitHostPort(r.Addr)
+	}
+	if r.VerifyErr != nil {
+		fmt.Fprintf(b, "  [red]✗ not trusted for %s: %s[white]\n", tview.Escape(name), tview.Escape(r.VerifyErr.Error()))
+	} else {
+		fmt.Fprintf(b, "  [green]✓ trusted for %s[white]\n", tview.Escape(name))
+	}
+
+	now := time.Now()
+	for i, cert := range r.Chain {
+		color := "green"
+		switch left := cert.NotAfter.Sub(now); {
+		case left < 14*24*time.Hour || now.Before(cert.NotBefore):
+			color = "red"
+		case left < 30*24*time.Hour:
+			color = "yellow"
+		}
+		fmt.Fprintf(b, "  #%d %s\n", i, tview.Escape(cert.Subject))
+		fmt.Fprintf(b, "     [gray]issuer[white] %s\n", tview.Escape(cert.Issuer))
+		fmt.Fprintf(b, "     [gray]expires[white] %s [%s](%s)[white]\n", cert.NotAfter.Format("2006-01-02"), color, cert.ExpiresIn(now))
+		if len(cert.SANs) > 0 {
+			fmt.Fprintf(b, "     [gray]SANs[white] %s\n", tview.Escape(joinLimited(cert.SANs, 6)))
+		}
+	}
+	b.WriteString("\n")
+}
+
+func writeHTTPReport(b *strings.Builder, r probe.HTTPReport, err error) {
+	fmt.Fprintf(b, "[yellow::b]HTTP[-::-]  HEAD %s", tview.Escape(r.URL))
+	if err != nil {
+		b.WriteString("  [red]" + tview.Escape(err.Error()) + "[white]\n")
+		return
+	}
+	color := "green"
+	if !strings.HasPrefix(r.Status, "2") && !strings.HasPrefix(r.Status, "3") {
+		color = "red"
+	}
+	fmt.Fprintf(b, " → %s [%s]%s[white]", r.Proto, color, tview.Escape(r.Status))
+	if r.Server != "" {
+		fmt.Fprintf(b, "  [gray]Server[white] %s", tview.Escape(r.Server))
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(b, "  [gray]connect[white] %s ms", formatRTT(r.Connect))
+	if r.TLS > 0 {
+		fmt.Fprintf(b, "  [gray]tls[white] %s ms", formatRTT(r.TLS))
+	}
+	fmt.Fprintf(b, "  [gray]first byte[white] %s ms  [gray]total[white] %s ms\n", formatRTT(r.FirstByte), formatRTT(r.Total))
+	if r.Location != "" {
+		fmt.Fprintf(b, "  [gray]Location[white] %s\n", tview.Escape(r.Location))
+	}
+}
+
+func joinLimited(items []string, limit int) string {
+	if len(items) > limit {
+		return strings.Join(items[:limit], ", ") + fmt.Sprintf(" (+%d more)", len(items)-limit)
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,406 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/history"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/MrBrooks89/BatStat/internal/probe"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func (v *View) showTracerouteModal() {
+	c := v.GetSelectedConnection()
+	if c == nil || !c.HasRemote() {
+		return
+	}
+	if _, ok := v.app.remote(*c); ok {
+		if d, ok := v.builtin("traceroute"); ok {
+			v.showDiagModal(d, *c)
+		}
+		return
+	}
+	opts := v.app.trace
+	asn := v.app.traceASN
+
+	closeModal := func() {
+		v.pages.RemovePage("traceroute_settings")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	modes := []string{"ICMP echo", "UDP", "TCP SYN to port " + strconv.Itoa(int(c.RemotePort()))}
+	mode := 0
+	for i, m := range probe.TraceModes {
+		if m == opts.Mode {
+			mode = i
+		}
+	}
+	modeDropDown := tview.NewDropDown().
+		SetLabel("Probes: ").
+		SetOptions(modes, func(_ string, index int) { mode = index }).
+		SetCurrentOption(mode)
+	hopsInput := tview.NewInputField().
+		SetLabel("Max hops: ").
+		SetText(strconv.Itoa(opts.MaxHops)).
+		SetFieldWidth(4).
+		SetAcceptanceFunc(tview.InputFieldInteger)
+	roundsInput := tview.NewInputField().
+		SetLabel("Rounds (0 = until Esc): ").
+		SetText(strconv.Itoa(opts.Rounds)).
+		SetFieldWidth(4).
+		SetAcceptanceFunc(tview.InputFieldInteger)
+
+	form := tview.NewForm().
+		AddFormItem(modeDropDown).
+		AddFormItem(hopsInput).
+		AddFormItem(roundsInput).
+		AddCheckbox("Look up ASNs (Team Cymru DNS): ", asn, func(checked bool) { asn = checked })
+	form.AddButton("Start", func() {
+		hops, err := strconv.Atoi(hopsInput.GetText())
+		if err != nil || hops < 1 || hops > 255 {
+			v.SetStatusMessage("[red]Max hops must be between 1 and 255")
+			return
+		}
+		rounds, err := strconv.Atoi(roundsInput.GetText())
+		if err != nil || rounds < 0 {
+			v.SetStatusMessage("[red]Rounds must be 0 or more")
+			return
+		}
+		opts.Mode, opts.MaxHops, opts.Rounds = probe.TraceModes[mode], hops, rounds
+		v.app.trace, v.app.traceASN = opts, asn
+		closeModal()
+		opts.Port = int(c.RemotePort())
+		v.runTraceroute(c, opts, asn)
+	})
+	form.AddButton("Cancel", closeModal)
+	form.SetBorder(true).SetTitle(" Traceroute to " + c.RemoteIP() + " ")
+	form.SetFocus(form.GetFormItemCount())
+
+	grid := tview.NewGrid().
+		SetColumns(0, 60, 0).
+		SetRows(0, 13, 0).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("traceroute_settings", grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
+
+// runTraceroute shows an mtr-style hop table that updates with every reply.
+// Like the output viewer it can be paused, searched, saved and copied.
+func (v *View) runTraceroute(c *models.Connection, opts probe.TraceOptions, asn bool) {
+	ip := c.RemoteIP()
+	statusView := tview.NewTextView().SetDynamicColors(true)
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	footerStatus := tview.NewTextView().SetDynamicColors(true)
+	search := tview.NewInputField().SetLabel("Search: ")
+	footer := tview.NewPages().
+		AddPage("status", footerStatus, true, true).
+		AddPage("search", search, true, false)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(statusView, 1, 0, false).
+		AddItem(table, 0, 1, true).
+		AddItem(footer, 1, 0, false)
+	layout.SetBorderPadding(0, 0, 1, 1)
+
+	mode := strings.ToUpper(opts.Mode)
+	if opts.Mode == probe.TraceTCP {
+		mode += fmt.Sprintf(" port %d", opts.Port)
+	}
+	frame := tview.NewFrame(layout).
+		AddText(fmt.Sprintf("Traceroute to %s (%s)", ip, mode), true, tview.AlignCenter, tview.Styles.TitleColor).
+		AddText(outputKeys, false, tview.AlignCenter, tview.Styles.SecondaryTextColor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	closeModal := func() {
+		cancel()
+		v.pages.RemovePage("traceroute_modal")
+		v.app.tviewApp.SetFocus(v.table)
+	}
+
+	// Only touched on the UI goroutine.
+	names := make(map[string]string)
+	asns := make(map[string]string)
+	var last probe.Trace
+	paused, unseen := false, 0
+	var query, result string
+	var matches []int // Rows matching query
+	current := -1     // Index into matches, -1 for none
+
+	updateFooter := func() {
+		parts := []string{"[green]following[white]"}
+		if paused && unseen > 0 {
+			parts[0] = fmt.Sprintf("[yellow]paused, %d updates[white]", unseen)
+		} else if paused {
+			parts[0] = "[yellow]paused[white]"
+		}
+		switch {
+		case query == "":
+		case len(matches) == 0:
+			parts = append(parts, "[red]no match for "+tview.Escape(query)+"[white]")
+		case current >= 0:
+			parts = append(parts, fmt.Sprintf("match %d/%d", current+1, len(matches)))
+		default:
+			parts = append(parts, fmt.Sprintf("%d matches", len(matches)))
+		}
+		parts = append(parts, cmp.Or(result, "[gray]running...[white]"))
+		footerStatus.SetText(strings.Join(parts, "  │  "))
+	}
+	findMatches := func() {
+		matches = matches[:0]
+		if query != "" {
+			needle := strings.ToLower(query)
+			for row := 1; row < table.GetRowCount(); row++ {
+				var text []string
+				for col := 0; col < table.GetColumnCount(); col++ {
+					if cell := table.GetCell(row, col); cell != nil {
+						text = append(text, cell.Text)
+					}
+				}
+				if strings.Contains(strings.ToLower(strings.Join(text, " ")), needle) {
+					matches = append(matches, row)
+				}
+			}
+		}
+		current = min(current, len(matches)-1)
+	}
+	jump := func(dir int) {
+		if len(matches) == 0 {
+			return
+		}
+		switch {
+		case current < 0 && dir < 0:
+			current = len(matches) - 1
+		case current < 0:
+			current = 0
+		default:
+			current = (current + dir + len(matches)) % len(matches)
+		}
+		table.Select(matches[current], 0)
+		updateFooter()
+	}
+	render := func() {
+		if paused {
+			unseen++
+		} else {
+			renderTrace(table, last, names, asns, asn)
+			findMatches()
+		}
+		updateFooter()
+	}
+	setPaused := func(p bool) {
+		paused, unseen = p, 0
+		render()
+	}
+
+	search.SetChangedFunc(func(text string) {
+		query, current = text, -1
+		findMatches()
+		jump(1)
+		updateFooter()
+	})
+	search.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			search.SetText("")
+		}
+		footer.SwitchToPage("status")
+		v.app.tviewApp.SetFocus(table)
+	})
+	lines := func() []string { return last.Lines() }
+
+	frame.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if search.HasFocus() {
+			return event
+		}
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		switch event.Rune() {
+		case '/':
+			footer.SwitchToPage("search")
+			v.app.tviewApp.SetFocus(search)
+		case 'n':
+			jump(1)
+		case 'N':
+			jump(-1)
+		case 'f':
+			setPaused(!paused)
+		case 's':
+			v.showSaveForm("traceroute_"+ip, lines, func(status string) {
+				v.app.tviewApp.SetFocus(table)
+				if status != "" {
+					footerStatus.SetText(status)
+				}
+			})
+		case 'y':
+			footerStatus.SetText(v.copyLines(lines()))
+		default:
+			return event
+		}
+		return nil
+	})
+
+	// lookup resolves each new hop address once, in the background.
+	lookup := func(addr string) {
+		if _, ok := names[addr]; ok || addr == "" {
+			return
+		}
+		names[addr] = ""
+		go func() {
+			var name string
+			if ptrs, err := net.DefaultResolver.LookupAddr(ctx, addr); err == nil && len(ptrs) > 0 {
+				name = strings.TrimSuffix(ptrs[0], ".")
+			}
+			var as string
+			if asn {
+				as, _ = probe.LookupASN(ctx, addr)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			v.app.tviewApp.QueueUpdateDraw(func() {
+				names[addr], asns[addr] = name, as
+				render()
+			})
+		}()
+	}
+
+	go func() {
+		start := time.Now()
+		trace, err := probe.Traceroute(ctx, ip, opts, func(t probe.Trace) {
+			v.app.tviewApp.QueueUpdateDraw(func() {
+				last = t
+				for _, h := range t.Hops {
+					lookup(h.Addr)
+				}
+				statusView.SetText(traceStatus(t))
+				render()
+			})
+		})
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err == nil && len(trace.Hops) > 0 {
+			params := map[string]string{
+				"mode":     opts.Mode,
+				"max_hops": strconv.Itoa(opts.MaxHops),
+				"rounds":   strconv.Itoa(opts.Rounds),
+			}
+			if opts.Mode == probe.TraceTCP {
+				params["port"] = strconv.Itoa(opts.Port)
+			}
+			v.app.recordRun(history.Run{
+				Time:    start,
+				Target:  ip,
+				Tool:    "traceroute",
+				Params:  params,
+				Elapsed: time.Since(start),
+				Trace:   &trace,
+				Output:  trace.Lines(),
+			})
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		v.app.tviewApp.QueueUpdateDraw(func() {
+			if errors.Is(err, probe.ErrNotPermitted) || errors.Is(err, probe.ErrTraceUnsupported) {
+				closeModal()
+				v.SetStatusMessage(tview.Escape(err.Error()) + "; using the system traceroute")
+				if d, ok := v.builtin("traceroute"); ok {
+					v.showDiagModal(d, *c)
+				}
+				return
+			}
+			if err != nil {
+				result = fmt.Sprintf("[red]%s[white] after %s", tview.Escape(err.Error()), elapsed)
+				statusView.SetText("[red]Traceroute failed: " + tview.Escape(err.Error()))
+				updateFooter()
+				return
+			}
+			last = trace
+			result = fmt.Sprintf("[green]done[white] after %s", elapsed)
+			statusView.SetText(traceStatus(trace))
+			render()
+		})
+	}()
+
+	render()
+	v.pages.AddPage("traceroute_modal", frame, true, true)
+	v.app.tviewApp.SetFocus(table)
+}
+
+func traceStatus(t probe.Trace) string {
+	status := fmt.Sprintf("[yellow]Round:[white] %d  [yellow]Hops:[white] %d", t.Round, len(t.Hops))
+	if t.Reached {
+		return status + "  [green]target reached"
+	}
+	if n := len(t.Hops); n > 0 && t.Hops[n-1].End {
+		return status + "  [red]target unreachable"
+	}
+	return status
+}
+
+func renderTrace(table *tview.Table, t probe.Trace, names, asns map[string]string, asn bool) {
+	titles := []string{"Hop", "Address", "Name", "Loss%", "Sent", "Last", "Avg", "Best", "Worst"}
+	if asn {
+		titles = []string{"Hop", "Address", "Name", "ASN", "Loss%", "Sent", "Last", "Avg", "Best", "Worst"}
+	}
+	table.Clear()
+	for i, title := range titles {
+		table.SetCell(0, i, tview.NewTableCell(title).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false).
+			SetExpansion(1))
+	}
+
+	for r, h := range t.Hops {
+		addr := h.Addr
+		if addr == "" {
+			addr = "???"
+		}
+		row := []string{strconv.Itoa(h.TTL), addr, names[h.Addr]}
+		if asn {
+			row = append(row, asns[h.Addr])
+		}
+		row = append(row, fmt.Sprintf("%.1f", h.Loss()), strconv.Itoa(h.Sent))
+		if h.Received > 0 {
+			row = append(row, formatRTT(h.Last), formatRTT(h.Avg), formatRTT(h.Best), formatRTT(h.Worst))
+		} else {
+			row = append(row, "", "", "", "")
+		}
+
+		color := tcell.ColorWhite
+		switch {
+		case h.End && h.Addr == t.Addr:
+			color = tcell.ColorGreen
+		case h.End:
+			color = tcell.ColorRed
+		case h.Received == 0:
+			color = tcell.ColorGray
+		}
+		for col, text := range row {
+			cell := tview.NewTableCell(tview.Escape(text)).SetTextColor(color).SetExpansion(1)
+			if col == 0 || col >= len(row)-6 {
+				cell.SetAlign(tview.AlignRight)
+			}
+			table.SetCell(r+1, col, cell)
+		}
+	}
+}
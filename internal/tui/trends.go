@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/timeseries"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// DefaultTrendTop is how many keys each column of the trends pane shows.
+const DefaultTrendTop = 5
+
+var trendDimensions = []timeseries.Dimension{
+	timeseries.ByState, timeseries.ByProtocol, timeseries.ByProcess, timeseries.ByRemote,
+}
+
+func newTrendPane() (*tview.Flex, []*tview.TextView) {
+	pane := tview.NewFlex()
+	pane.SetBorder(true).SetTitle(" Trends ")
+	columns := make([]*tview.TextView, len(trendDimensions))
+	for i := range columns {
+		columns[i] = tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+		columns[i].SetBorderPadding(0, 0, 1, 1)
+		pane.AddItem(columns[i], 0, 1, false)
+	}
+	return pane, columns
+}
+
+func (v *View) trendPaneHeight() int {
+	// Border, column titles and the total above the states.
+	return v.app.trendTop + 4
+}
+
+func (v *View) trendPaneVisible() bool {
+	_, _, _, height := v.trendView.GetRect()
+	return height > 0
+}
+
+func (v *View) toggleTrendPane() {
+	height := 0
+	if !v.trendPaneVisible() {
+		height = v.trendPaneHeight()
+	}
+	v.layout.ResizeItem(v.trendView, height, 0)
+	v.renderTrendPane()
+}
+
+// renderTrendPane draws a sparkline per key, scaled over the whole window,
+// with the current count and the change since the oldest sample.
+func (v *View) renderTrendPane() {
+	samples := v.app.trends.Samples()
+	title := fmt.Sprintf(" Trends, last %s ", formatWindow(v.app.trends.Window()))
+	if len(samples) > 1 {
+		title = fmt.Sprintf(" Trends, last %s (%d samples since %s) ",
+			formatWindow(v.app.trends.Window()), len(samples), samples[0].Time.Format("15:04:05"))
+	}
+	v.trendView.SetTitle(title)
+
+	// The columns are only laid out once drawn, so size them from the
+	// screen: border, then one padding column on each side.
+	_, _, screenWidth, _ := v.layout.GetRect()
+	width := (screenWidth-2)/len(trendDimensions) - 2
+
+	for i, d := range trendDimensions {
+		column := v.trendCols[i]
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "[::u]%s[::-]\n", d)
+		if d == timeseries.ByState {
+			writeTrend(&b, timeseries.Total(samples), width, "white")
+		}
+		series := timeseries.Top(samples, d, v.app.trendTop)
+		if len(series) == 0 {
+			b.WriteString("[gray]No data yet[-]\n")
+		}
+		for _, s := range series {
+			color := "aqua"
+			if d == timeseries.ByState {
+				color = colorTag(getStatusColor(s.Key))
+			}
+			writeTrend(&b, s, width, color)
+		}
+		column.SetText(b.String())
+	}
+}
+
+// writeTrend writes one row: label, current count, sparkline and, when the
+// column has room, the change over the window.
+func writeTrend(b *strings.Builder, s timeseries.Series, width int, color string) {
+	labelWidth := min(18, max(width/3, 6))
+	sparkWidth := width - labelWidth - 7
+	showDelta := width >= 30
+	if showDelta {
+		sparkWidth -= 7
+	}
+	sparkWidth = max(sparkWidth, 0)
+
+	fmt.Fprintf(b, "%s %5.0f %s",
+		tview.Escape(padRight(truncate(s.Key, labelWidth), labelWidth)), s.Last(),
+		sparkline(downsample(s.Values, sparkWidth), sparkWidth, color))
+	if showDelta && len(s.Values) > 1 {
+		if d := s.Last() - s.Values[0]; d != 0 {
+			fmt.Fprintf(b, " [gray]%+6.0f[-]", d)
+		}
+	}
+	b.WriteString("\n")
+}
+
+// downsample shrinks values to at most width points, keeping the peak of
+// each bucket so short spikes stay visible.
+func downsample(values []float64, width int) []float64 {
+	if width <= 0 || len(values) <= width {
+		return values
+	}
+	out := make([]float64, width)
+	for i := range out {
+		from, to := i*len(values)/width, (i+1)*len(values)/width
+		for _, v := range values[from:to] {
+			out[i] = max(out[i], v)
+		}
+	}
+	return out
+}
+
+func padRight(s string, width int) string {
+	if n := len([]rune(s)); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func colorTag(c tcell.Color) string {
+	return fmt.Sprintf("#%06x", c.Hex())
+}
+
+// formatWindow drops the zero units of d, e.g. "1h" rather than "1h0m0s".
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -2,10 +2,12 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/MrBrooks89/BatStat/internal/actions"
 	"github.com/MrBrooks89/BatStat/internal/models"
 )
 
@@ -15,6 +17,14 @@ type View struct {
 	detailsView *tview.TextView
 	filterInput *tview.InputField
 	hintView    *tview.TextView
+	hintText    string
+	status      string
+	alertView   *tview.TextView
+	trendView   *tview.Flex
+	trendCols   []*tview.TextView
+	summaryView *tview.TextView
+	showSummary bool
+	layout      *tview.Flex
 	pages       *tview.Pages
 }
 
@@ -40,9 +50,26 @@ func NewView(app *App) *View {
 
 	hint := tview.NewTextView()
 	hint.SetDynamicColors(true)
-	hint.SetText("[::b]Keys:[-:-] [yellow]/[white]Filter [yellow]s/S[white]Sort [yellow]k/K[white]Kill [yellow]p[white]Ping [yellow]t[white]Traceroute [yellow]n[white]Nslookup [yellow]e[white]Export [yellow]h[white]Help [yellow]q[white]Quit")
+	hintText := "[::b]Keys:[-:-] [yellow]/[white]Filter [yellow]s/S[white]Sort [yellow]k/K[white]Kill [yellow]p[white]Ping [yellow]t[white]Traceroute [yellow]n[white]DNS [yellow]e[white]Export [yellow]Space[white]Mark "
+	if app.state.MultiHost() {
+		hintText += "[yellow]H[white]Hosts "
+	}
+	v.hintText = hintText + "[yellow]h[white]Help [yellow]q[white]Quit"
+	hint.SetText(v.hintText)
 	v.hintView = hint
 
+	alertView := tview.NewTextView()
+	alertView.SetDynamicColors(true)
+	alertView.SetScrollable(true)
+	alertView.SetBorder(true)
+	alertView.SetTitle(" Alerts ")
+	v.alertView = alertView
+
+	v.trendView, v.trendCols = newTrendPane()
+
+	v.summaryView = tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	v.showSummary = true
+
 	v.pages = tview.NewPages()
 
 	return v
@@ -55,9 +82,13 @@ func (v *View) Init() {
 
 	layout := tview.NewFlex().
 		SetDirection(tview.FlexRow).
+		AddItem(v.summaryView, summaryHeight, 0, false).
 		AddItem(mainFlex, 0, 1, true).
+		AddItem(v.alertView, 0, 0, false).
+		AddItem(v.trendView, 0, 0, false).
 		AddItem(v.filterInput, 1, 0, false).
 		AddItem(v.hintView, 1, 0, false)
+	v.layout = layout
 
 	v.pages.AddPage("main", layout, true, true)
 	v.app.tviewApp.SetRoot(v.pages, true).EnableMouse(true)
@@ -68,6 +99,7 @@ func (v *View) Refresh() {
 	v.updateHeaderIndicator()
 	selectedRow, _ := v.table.GetSelection()
 	v.updateDetailsView(selectedRow)
+	v.renderSummary()
 }
 
 func (v *View) GetSelectedConnection() *models.Connection {
@@ -102,13 +134,18 @@ func (v *View) ShowInfoModal(message string, duration int) {
 }
 
 func (v *View) SetStatusMessage(message string) {
-	originalText := v.hintView.GetText(false)
-	v.hintView.SetText(fmt.Sprintf("[yellow]Status: [white]%s", message))
+	status := fmt.Sprintf("[yellow]Status: [white]%s", message)
+	v.status = status
+	v.hintView.SetText(status)
 
 	go func() {
-		<-time.After(3 * time.Second) 
+		<-time.After(3 * time.Second)
 		v.app.tviewApp.QueueUpdateDraw(func() {
-			v.hintView.SetText(originalText)
+			// A newer message keeps the bar until its own timeout.
+			if v.status == status {
+				v.status = ""
+				v.hintView.SetText(v.hintText)
+			}
 		})
 	}()
 }
@@ -151,3 +188,92 @@ func (v *View) showInputModal(title, label string, callback func(string)) {
 
 	v.app.tviewApp.SetFocus(inputField)
 }
+
+// showExportModal asks for a format and path, then writes the dataset built
+// by build. The format follows the path's extension when it has a known one.
+func (v *View) showExportModal(title, defaultBase string, build func() actions.Dataset, meta actions.ExportMeta) {
+	exporters := actions.Exporters()
+	formats := make([]string, len(exporters))
+	for i, e := range exporters {
+		formats[i] = e.Format()
+	}
+
+	selected := 0
+	formatDropDown := tview.NewDropDown().
+		SetLabel("Format: ").
+		SetOptions(formats, func(option string, index int) { selected = index }).
+		SetCurrentOption(0)
+
+	pathInput := tview.NewInputField().
+		SetLabel("File path: ").
+		SetPlaceholder("blank for " + defaultBase + exporters[0].Extension()).
+		SetFieldWidth(40)
+	pathInput.SetChangedFunc(func(text string) {
+		if e, ok := actions.ExporterForPath(text); ok {
+			for i, f := range formats {
+				if f == e.Format() && i != selected {
+					formatDropDown.SetCurrentOption(i)
+				}
+			}
+		}
+	})
+	formatDropDown.SetSelectedFunc(func(option string, index int) {
+		selected = index
+		pathInput.SetPlaceholder("blank for " + defaultBase + exporters[index].Extension())
+	})
+
+	// Exports can start from other modals, such as the sweep results.
+	back := v.app.tviewApp.GetFocus()
+	closeModal := func() {
+		v.pages.RemovePage("export_modal")
+		v.app.tviewApp.SetFocus(back)
+	}
+
+	form := tview.NewForm().
+		AddFormItem(formatDropDown).
+		AddFormItem(pathInput).
+		AddButton("Export", func() {
+			closeModal()
+			path := strings.TrimSpace(pathInput.GetText())
+			if path == "" {
+				path = defaultBase
+			}
+			// A known extension picks the format, whatever the dropdown says.
+			format := formats[selected]
+			if e, ok := actions.ExporterForPath(path); ok {
+				format = e.Format()
+			}
+			filename, err := actions.Export(build(), meta, path, format)
+			if err != nil {
+				v.SetStatusMessage("Error exporting: " + err.Error())
+				return
+			}
+			if format == "csv" {
+				v.SetStatusMessage("Exported to " + filename + ", metadata in " + actions.MetaPath(filename))
+				return
+			}
+			v.SetStatusMessage("Exported to " + filename)
+		}).
+		AddButton("Cancel", func() {
+			closeModal()
+			v.SetStatusMessage("Export canceled.")
+		})
+
+	form.SetBorder(true).SetTitle(title)
+
+	grid := tview.NewGrid().
+		SetColumns(0, 60, 0).
+		SetRows(0, 9, 0).
+		AddItem(form, 1, 1, 1, 1, 0, 0, true)
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	v.pages.AddPage("export_modal", grid, true, true)
+	v.app.tviewApp.SetFocus(form)
+}
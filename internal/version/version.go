@@ -0,0 +1,17 @@
+package version
+
+import "runtime/debug"
+
+// Version is overridden at build time with
+// -ldflags "-X github.com/MrBrooks89/BatStat/internal/version.Version=v0.x.y".
+var Version = ""
+
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
@@ -0,0 +1,190 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/MrBrooks89/BatStat/internal/conn"
+	"github.com/MrBrooks89/BatStat/internal/filter"
+	"github.com/MrBrooks89/BatStat/internal/models"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	EventOpen     = "open"
+	EventClose    = "close"
+	EventState    = "state"
+	EventSnapshot = "snapshot"
+)
+
+type Event struct {
+	Time       time.Time `json:"ts"`
+	Type       string    `json:"event"`
+	Proto      string    `json:"proto"`
+	Family     string    `json:"family"`
+	LocalIP    string    `json:"local_ip"`
+	LocalPort  uint32    `json:"local_port"`
+	RemoteIP   string    `json:"remote_ip"`
+	RemotePort uint32    `json:"remote_port"`
+	Pid        int32     `json:"pid"`
+	Process    string    `json:"process"`
+	User       string    `json:"user"`
+	PrevState  string    `json:"prev_state,omitempty"`
+	State      string    `json:"state,omitempty"`
+}
+
+type Options struct {
+	Interval time.Duration
+	Filter   filter.Query
+	// Initial emits a snapshot event for every connection present at start.
+	Initial bool
+	Output  io.Writer
+}
+
+type Watcher struct {
+	opts       Options
+	fetch      func() ([]models.Connection, error)
+	lookupUser func(pid int32) string
+	prev       map[string]models.Connection
+	users      map[int32]string // Resolved when a PID first appears
+	enc        *json.Encoder
+}
+
+func New(opts Options) *Watcher {
+	if opts.Interval <= 0 {
+		opts.Interval = 2 * time.Second
+	}
+	return &Watcher{
+		opts:       opts,
+		fetch:      conn.FetchConnections,
+		lookupUser: processUser,
+		users:      make(map[int32]string),
+		enc:        json.NewEncoder(opts.Output),
+	}
+}
+
+// Run polls until ctx is done, writing one JSON event per line.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.poll(w.opts.Initial); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := w.poll(true); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *Watcher) poll(emit bool) error {
+	conns, err := w.fetch()
+	if err != nil {
+		return fmt.Errorf("fetching connections: %w", err)
+	}
+
+	now := time.Now()
+	current := make(map[string]models.Connection, len(conns))
+	pids := make(map[int32]bool)
+	for _, c := range conns {
+		current[c.Key()] = c
+		pids[c.Pid] = true
+		// Looked up now, while the process exists, so that events emitted
+		// after it exits, such as its closes, still name the user.
+		if _, known := w.users[c.Pid]; !known && c.Pid != 0 {
+			w.users[c.Pid] = w.lookupUser(c.Pid)
+		}
+	}
+
+	if emit {
+		first := w.prev == nil
+		for key, c := range current {
+			old, seen := w.prev[key]
+			switch {
+			case first:
+				if err := w.emit(now, EventSnapshot, c, nil); err != nil {
+					return err
+				}
+			case !seen:
+				if err := w.emit(now, EventOpen, c, nil); err != nil {
+					return err
+				}
+			case old.Status != c.Status:
+				if err := w.emit(now, EventState, c, &old); err != nil {
+					return err
+				}
+			}
+		}
+		for key, old := range w.prev {
+			if _, ok := current[key]; !ok {
+				if err := w.emit(now, EventClose, old, nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for pid := range w.users {
+		if !pids[pid] {
+			delete(w.users, pid)
+		}
+	}
+	w.prev = current
+	return nil
+}
+
+// emit writes an event for c, whose previous state old is known for state
+// changes. A change is written when either state matches the filter, so
+// "state:listen" also shows a socket leaving LISTEN.
+func (w *Watcher) emit(now time.Time, kind string, c models.Connection, old *models.Connection) error {
+	if !w.opts.Filter.Match(c) && (old == nil || !w.opts.Filter.Match(*old)) {
+		return nil
+	}
+
+	state, prevState := c.Status, ""
+	if old != nil {
+		prevState = old.Status
+	}
+	if kind == EventClose {
+		state, prevState = "", c.Status
+	}
+
+	return w.enc.Encode(Event{
+		Time:       now,
+		Type:       kind,
+		Proto:      c.Type,
+		Family:     c.Family,
+		LocalIP:    c.LocalIP(),
+		LocalPort:  c.LocalPort(),
+		RemoteIP:   c.RemoteIP(),
+		RemotePort: c.RemotePort(),
+		Pid:        c.Pid,
+		Process:    c.ProcessName,
+		User:       w.users[c.Pid],
+		PrevState:  prevState,
+		State:      state,
+	})
+}
+
+// processUser returns the user running pid, or "" when that cannot be told.
+func processUser(pid int32) string {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return ""
+	}
+	name, err := p.Username()
+	if err != nil {
+		return ""
+	}
+	return name
+}
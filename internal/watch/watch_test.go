@@ -0,0 +1,134 @@
+package watch
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/MrBrooks89/BatStat/internal/filter"
+	"github.com/MrBrooks89/BatStat/internal/models"
+)
+
+// fakeWatcher returns a Watcher whose connections are whatever *conns holds
+// at each poll, with users from the map, and the buffer its events go to.
+func fakeWatcher(opts Options, conns *[]models.Connection, users map[int32]string) (*Watcher, *bytes.Buffer) {
+	var out bytes.Buffer
+	opts.Output = &out
+	w := New(opts)
+	w.fetch = func() ([]models.Connection, error) { return *conns, nil }
+	w.lookupUser = func(pid int32) string { return users[pid] }
+	return w, &out
+}
+
+// events decodes the events written since the last call as
+// "type prev->state remote user" strings, sorted since a poll emits them in
+// map order.
+func events(t *testing.T, out *bytes.Buffer) []string {
+	t.Helper()
+	var got []string
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e.Type+" "+e.PrevState+"->"+e.State+" "+e.RemoteIP+" "+e.User)
+	}
+	out.Reset()
+	slices.Sort(got)
+	return got
+}
+
+func tcp(pid int32, raddr, status string) models.Connection {
+	return models.Connection{Family: "IPv4", Type: "TCP", Pid: pid, ProcessName: "app",
+		Laddr: "10.0.0.1:5000", Raddr: raddr, Status: status}
+}
+
+func TestPollDiffs(t *testing.T) {
+	var conns []models.Connection
+	users := map[int32]string{100: "alice", 200: "bob"}
+	w, out := fakeWatcher(Options{Initial: true}, &conns, users)
+
+	steps := []struct {
+		name  string
+		conns []models.Connection
+		want  []string
+	}{
+		{"snapshot", []models.Connection{tcp(100, "10.0.0.2:443", "ESTABLISHED")},
+			[]string{"snapshot ->ESTABLISHED 10.0.0.2 alice"}},
+		{"unchanged", []models.Connection{tcp(100, "10.0.0.2:443", "ESTABLISHED")}, nil},
+		{"open and state", []models.Connection{
+			tcp(100, "10.0.0.2:443", "CLOSE_WAIT"),
+			tcp(200, "10.0.0.3:443", "SYN_SENT"),
+		}, []string{
+			"open ->SYN_SENT 10.0.0.3 bob",
+			"state ESTABLISHED->CLOSE_WAIT 10.0.0.2 alice",
+		}},
+		// bob's process has exited and can no longer be looked up, but
+		// its user was resolved when it appeared.
+		{"close", []models.Connection{tcp(100, "10.0.0.2:443", "CLOSE_WAIT")},
+			[]string{"close SYN_SENT-> 10.0.0.3 bob"}},
+	}
+	for i, step := range steps {
+		conns = step.conns
+		if step.name == "close" {
+			delete(users, 200)
+		}
+		// As in Run, the first poll only emits with Initial set.
+		if err := w.poll(i > 0 || w.opts.Initial); err != nil {
+			t.Fatal(err)
+		}
+		got := events(t, out)
+		if strings.Join(got, "\n") != strings.Join(step.want, "\n") {
+			t.Errorf("%s:\ngot  %q\nwant %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestNoSnapshotWithoutInitial(t *testing.T) {
+	conns := []models.Connection{tcp(100, "10.0.0.2:443", "ESTABLISHED")}
+	w, out := fakeWatcher(Options{}, &conns, nil)
+	if err := w.poll(w.opts.Initial); err != nil {
+		t.Fatal(err)
+	}
+	if got := events(t, out); len(got) != 0 {
+		t.Errorf("events without -initial: %q", got)
+	}
+}
+
+func TestUnknownUserIsEmpty(t *testing.T) {
+	conns := []models.Connection{tcp(0, "10.0.0.2:443", "ESTABLISHED"), tcp(300, "10.0.0.3:443", "ESTABLISHED")}
+	w, out := fakeWatcher(Options{Initial: true}, &conns, nil)
+	w.lookupUser = processUser // PID 300 is not expected to exist
+	if err := w.poll(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events(t, out) {
+		if !strings.HasSuffix(e, " ") {
+			t.Errorf("event %q names a user", e)
+		}
+	}
+}
+
+// A state change is emitted when either state matches the filter.
+func TestFilterOnEitherState(t *testing.T) {
+	conns := []models.Connection{tcp(100, "10.0.0.2:443", "ESTABLISHED")}
+	w, out := fakeWatcher(Options{Filter: filter.Parse("state:established")}, &conns, nil)
+
+	for i, status := range []string{"ESTABLISHED", "CLOSE_WAIT", "LAST_ACK"} {
+		conns = []models.Connection{tcp(100, "10.0.0.2:443", status)}
+		if err := w.poll(i > 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conns = nil
+	if err := w.poll(true); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"state ESTABLISHED->CLOSE_WAIT 10.0.0.2 "}
+	if got := events(t, out); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}